@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+
+	"workmateTestProject/internal/service"
+)
+
+// poolResponse - настройки и текущая загрузка пула обработчиков
+type poolResponse struct {
+	service.PoolSettings
+	Stats service.QueueStats `json:"stats"`
+}
+
+// writePool отвечает текущим состоянием пула
+func writePool(w http.ResponseWriter, proc *service.Processor) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(poolResponse{PoolSettings: proc.Settings(), Stats: proc.Stats()}); err != nil {
+		errorResponse(w, http.StatusInternalServerError, "Ошибка кодирования ответа")
+	}
+}
+
+// persistPool сохраняет настройки пула, чтобы они пережили перезапуск; пустой путь отключает сохранение
+func persistPool(w http.ResponseWriter, proc *service.Processor, path string) bool {
+	if path == "" {
+		return true
+	}
+	if err := proc.SaveSettings(path); err != nil {
+		log.Printf("Ошибка сохранения настроек пула в %s: %v", path, err)
+		errorResponse(w, http.StatusInternalServerError, "Настройки применены, но не сохранены")
+		return false
+	}
+	return true
+}
+
+// getPoolHandler возвращает настройки и загрузку пула
+func getPoolHandler(proc *service.Processor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writePool(w, proc)
+	}
+}
+
+// setConcurrencyHandler меняет размер пула без перезапуска
+func setConcurrencyHandler(proc *service.Processor, settingsFile string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			MaxConcurrent int `json:"max_concurrent"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			errorResponse(w, http.StatusBadRequest, "Неверный JSON")
+			return
+		}
+		if err := proc.SetMaxConcurrent(req.MaxConcurrent); err != nil {
+			errorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		log.Printf("Размер пула изменён на %d", req.MaxConcurrent)
+		if persistPool(w, proc, settingsFile) {
+			writePool(w, proc)
+		}
+	}
+}
+
+// pauseHandler приостанавливает (pause == true) или возобновляет запуск задач.
+// Тело запроса необязательно: {"type": "report"} ограничивает действие одним типом.
+func pauseHandler(proc *service.Processor, settingsFile string, pause bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Type string `json:"type"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			errorResponse(w, http.StatusBadRequest, "Неверный JSON")
+			return
+		}
+		scope := req.Type
+		if scope == "" {
+			scope = "все типы"
+		}
+		if pause {
+			proc.Pause(req.Type)
+			log.Printf("Запуск задач приостановлен: %s", scope)
+		} else {
+			proc.Resume(req.Type)
+			log.Printf("Запуск задач возобновлён: %s", scope)
+		}
+		if persistPool(w, proc, settingsFile) {
+			writePool(w, proc)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+
+	"workmateTestProject/internal/archive"
+	"workmateTestProject/internal/model"
+	"workmateTestProject/internal/service"
+	"workmateTestProject/internal/storage"
+)
+
+// Ограничения размера выборки из архива
+const (
+	defaultArchiveLimit = 100
+	maxArchiveLimit     = 1000
+)
+
+// archiveTaskData переносит задачи в архив перед удалением из хранилища.
+// Артефакты сохраняются, чтобы восстановленная задача осталась полной; журналы и история удаляются.
+func archiveTaskData(proc *service.Processor, a *archive.Archive) func(ctx context.Context, tasks []*model.Task) error {
+	return func(ctx context.Context, tasks []*model.Task) error {
+		if err := a.Append(tasks); err != nil {
+			return err
+		}
+		for _, task := range tasks {
+			if err := proc.DeleteLogs(task.ID); err != nil {
+				log.Printf("Ошибка удаления журнала архивированной задачи %s: %v", task.ID, err)
+			}
+			proc.DeleteHistory(task.ID)
+		}
+		return nil
+	}
+}
+
+// searchArchiveHandler ищет задачи арендатора в архиве.
+// Параметры: from и to (RFC 3339) ограничивают время создания, id - конкретная задача,
+// limit - размер выборки, format=ndjson - потоковая выгрузка по строке на запись.
+func searchArchiveHandler(a *archive.Archive) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := r.URL.Query()
+		q := archive.Query{Tenant: principal(r).Tenant, Limit: defaultArchiveLimit}
+		var err error
+		for name, dst := range map[string]*time.Time{"from": &q.From, "to": &q.To} {
+			if v := params.Get(name); v != "" {
+				if *dst, err = time.Parse(time.RFC3339, v); err != nil {
+					errorResponse(w, http.StatusBadRequest, name+" должен быть в формате RFC 3339")
+					return
+				}
+			}
+		}
+		if v := params.Get("id"); v != "" {
+			if q.ID, err = uuid.Parse(v); err != nil {
+				errorResponse(w, http.StatusBadRequest, "Неверный UUID")
+				return
+			}
+		}
+		if v := params.Get("limit"); v != "" {
+			if q.Limit, err = strconv.Atoi(v); err != nil || q.Limit <= 0 || q.Limit > maxArchiveLimit {
+				errorResponse(w, http.StatusBadRequest, "limit должен быть от 1 до "+strconv.Itoa(maxArchiveLimit))
+				return
+			}
+		}
+
+		records, err := a.Search(q)
+		if err != nil {
+			log.Printf("Ошибка поиска в архиве: %v", err)
+			errorResponse(w, http.StatusInternalServerError, "Ошибка чтения архива")
+			return
+		}
+		if params.Get("format") == "ndjson" {
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			enc := json.NewEncoder(w)
+			for _, rec := range records {
+				if enc.Encode(rec) != nil {
+					return
+				}
+			}
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(records); err != nil {
+			errorResponse(w, http.StatusInternalServerError, "Ошибка кодирования ответа")
+		}
+	}
+}
+
+// restoreArchivedHandler возвращает архивную задачу в хранилище
+func restoreArchivedHandler(proc *service.Processor, a *archive.Archive, store storage.TaskStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(mux.Vars(r)["id"])
+		if err != nil {
+			errorResponse(w, http.StatusBadRequest, "Неверный UUID")
+			return
+		}
+		// Проверка и создание под блокировкой задачи, иначе два восстановления или восстановление
+		// и импорт с тем же ID заменят задачу друг друга
+		defer lockTask(id)()
+		rec, err := a.Get(id, principal(r).Tenant)
+		if errors.Is(err, archive.ErrNotFound) {
+			errorResponse(w, http.StatusNotFound, "Задача не найдена в архиве")
+			return
+		}
+		if err != nil {
+			log.Printf("Ошибка чтения архива: %v", err)
+			errorResponse(w, http.StatusInternalServerError, "Ошибка чтения архива")
+			return
+		}
+		// Проверяем всё хранилище: ID уникален для всех арендаторов
+		if _, exists := store.Get(id); exists {
+			errorResponse(w, http.StatusConflict, "Задача уже есть в хранилище")
+			return
+		}
+		// Срок хранения восстановленной задачи отсчитывается заново
+		now := proc.Now()
+		rec.Task.RestoredAt = &now
+		rec.Task.DeletedAt = nil
+		proc.RecordSnapshot(rec.Task, model.EventRestored, principal(r).Name, "восстановлена из архива")
+		store.Create(rec.Task)
+		task := proc.Snapshot(rec.Task)
+
+		setTaskETag(w, task)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		if err := json.NewEncoder(w).Encode(newTaskResponse(task)); err != nil {
+			log.Printf("Ошибка кодирования ответа: %v", err)
+		}
+	}
+}
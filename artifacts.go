@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+
+	"workmateTestProject/internal/blob"
+	"workmateTestProject/internal/service"
+	"workmateTestProject/internal/storage"
+)
+
+// loadArtifactStore создаёт хранилище артефактов из ARTIFACT_S3_* или ARTIFACT_DIR.
+// Возвращает nil, если хранилище не настроено.
+func loadArtifactStore() (blob.Store, error) {
+	if endpoint := os.Getenv("ARTIFACT_S3_ENDPOINT"); endpoint != "" {
+		bucket := os.Getenv("ARTIFACT_S3_BUCKET")
+		if bucket == "" {
+			return nil, errors.New("ARTIFACT_S3_BUCKET не задан")
+		}
+		return blob.NewS3Store(blob.S3Config{
+			Endpoint:  endpoint,
+			Bucket:    bucket,
+			Region:    os.Getenv("ARTIFACT_S3_REGION"),
+			AccessKey: os.Getenv("ARTIFACT_S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("ARTIFACT_S3_SECRET_KEY"),
+		}), nil
+	}
+	if dir := os.Getenv("ARTIFACT_DIR"); dir != "" {
+		return blob.NewFSStore(dir)
+	}
+	return nil, nil
+}
+
+// getArtifactHandler отдаёт артефакт задачи с поддержкой Range и условных запросов.
+// Контрольная сумма передаётся в ETag и Repr-Digest.
+func getArtifactHandler(proc *service.Processor, store storage.TaskStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		id, err := uuid.Parse(vars["id"])
+		if err != nil {
+			errorResponse(w, http.StatusBadRequest, "Неверный UUID")
+			return
+		}
+		task, ok := tenantStore(r, proc, store).Get(id)
+		if !ok {
+			errorResponse(w, http.StatusNotFound, "Задача не найдена")
+			return
+		}
+		a, obj, err := proc.OpenArtifact(r.Context(), task, vars["name"])
+		switch {
+		case errors.Is(err, blob.ErrNotFound):
+			errorResponse(w, http.StatusNotFound, "Артефакт не найден")
+			return
+		case errors.Is(err, service.ErrNoArtifactStore):
+			errorResponse(w, http.StatusNotFound, "Хранилище артефактов не настроено")
+			return
+		case err != nil:
+			log.Printf("Ошибка открытия артефакта %s задачи %s: %v", vars["name"], id, err)
+			errorResponse(w, http.StatusBadGateway, "Хранилище артефактов недоступно")
+			return
+		}
+		defer obj.Close()
+
+		if a.ContentType != "" {
+			w.Header().Set("Content-Type", a.ContentType)
+		} else {
+			w.Header().Set("Content-Type", "application/octet-stream")
+		}
+		w.Header().Set("ETag", `"`+a.SHA256+`"`)
+		if sum, err := hex.DecodeString(a.SHA256); err == nil {
+			w.Header().Set("Repr-Digest", "sha-256=:"+base64.StdEncoding.EncodeToString(sum)+":")
+		}
+		w.Header().Set("Content-Disposition", `attachment; filename="`+a.Name+`"`)
+		http.ServeContent(w, r, a.Name, a.CreatedAt, obj)
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+
+	"workmateTestProject/internal/audit"
+	"workmateTestProject/internal/auth"
+)
+
+// Ограничения размера выборки из журнала аудита
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+// auditState собирает сведения о запросе, известные только внутренним обработчикам
+type auditState struct {
+	target string
+	// principal - субъект, установленный authMiddleware; nil, если запрос не прошёл аутентификацию
+	principal *auth.Principal
+}
+
+type auditKey struct{}
+
+// setAuditTarget задаёт объект операции, если он не следует из пути (например, ID созданной задачи)
+func setAuditTarget(r *http.Request, target string) {
+	if s, ok := r.Context().Value(auditKey{}).(*auditState); ok {
+		s.target = target
+	}
+}
+
+// setAuditPrincipal сообщает аудиту субъекта запроса: auditMiddleware выполняется раньше
+// аутентификации и не видит контекст, который создаёт authMiddleware
+func setAuditPrincipal(r *http.Request, p *auth.Principal) {
+	if s, ok := r.Context().Value(auditKey{}).(*auditState); ok {
+		s.principal = p
+	}
+}
+
+// statusRecorder запоминает код ответа
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	if s.status == 0 {
+		s.status = code
+	}
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	return s.ResponseWriter.Write(b)
+}
+
+// requestID возвращает идентификатор запроса из X-Request-ID или создаёт новый
+func requestID(r *http.Request) string {
+	if id := r.Header.Get("X-Request-ID"); id != "" && len(id) <= 128 {
+		return id
+	}
+	return uuid.NewString()
+}
+
+// requestIDMiddleware возвращает клиенту идентификатор запроса в заголовке X-Request-ID
+func requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-ID", requestID(r))
+		next.ServeHTTP(w, r)
+	})
+}
+
+// auditMiddleware записывает в журнал аудита каждый изменяющий запрос: субъекта, действие,
+// объект, ID запроса, адрес клиента и исход. Выполняется до ограничения частоты и authMiddleware,
+// чтобы отклонённые с 401 и 429 запросы тоже попадали в журнал; у запросов без действительного
+// ключа субъект не указывается, а запись относится к арендатору по умолчанию.
+func auditMiddleware(l *audit.Log) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		if l == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			state := &auditState{target: mux.Vars(r)["id"]}
+			rec := &statusRecorder{ResponseWriter: w}
+			next.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), auditKey{}, state)))
+			if rec.status == 0 {
+				rec.status = http.StatusOK
+			}
+
+			e := audit.Entry{
+				Tenant:       auth.DefaultTenant,
+				Action:       r.Method + " " + r.URL.Path,
+				Target:       state.target,
+				RequestID:    w.Header().Get("X-Request-ID"),
+				SourceIP:     r.RemoteAddr,
+				ForwardedFor: r.Header.Get("X-Forwarded-For"),
+				Status:       rec.status,
+			}
+			if p := state.principal; p != nil {
+				e.Principal, e.Tenant = p.Name, p.Tenant
+			}
+			if route := mux.CurrentRoute(r); route != nil {
+				if tpl, err := route.GetPathTemplate(); err == nil {
+					e.Action = r.Method + " " + tpl
+				}
+			}
+			if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+				e.SourceIP = host
+			}
+			switch {
+			case rec.status == http.StatusUnauthorized || rec.status == http.StatusForbidden ||
+				rec.status == http.StatusTooManyRequests:
+				e.Outcome = audit.OutcomeDenied
+			case rec.status >= 400:
+				e.Outcome = audit.OutcomeFailure
+			default:
+				e.Outcome = audit.OutcomeSuccess
+			}
+			if _, err := l.Append(e); err != nil {
+				log.Printf("Ошибка записи в журнал аудита (%s %s, запрос %s): %v", e.Action, e.Target, e.RequestID, err)
+			}
+		})
+	}
+}
+
+// auditHandler возвращает записи журнала аудита арендатора администратора.
+// Параметры: principal, action, target, from и to (RFC 3339), since (номер записи), limit.
+func auditHandler(l *audit.Log) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := r.URL.Query()
+		q := audit.Query{
+			Tenants:   []string{principal(r).Tenant},
+			Principal: params.Get("principal"),
+			Action:    params.Get("action"),
+			Target:    params.Get("target"),
+			Limit:     defaultAuditLimit,
+		}
+		var err error
+		for name, dst := range map[string]*time.Time{"from": &q.From, "to": &q.To} {
+			if v := params.Get(name); v != "" {
+				if *dst, err = time.Parse(time.RFC3339, v); err != nil {
+					errorResponse(w, http.StatusBadRequest, name+" должен быть в формате RFC 3339")
+					return
+				}
+			}
+		}
+		if v := params.Get("since"); v != "" {
+			if q.AfterSeq, err = strconv.ParseInt(v, 10, 64); err != nil {
+				errorResponse(w, http.StatusBadRequest, "since должен быть номером записи")
+				return
+			}
+		}
+		if v := params.Get("limit"); v != "" {
+			if q.Limit, err = strconv.Atoi(v); err != nil || q.Limit <= 0 || q.Limit > maxAuditLimit {
+				errorResponse(w, http.StatusBadRequest, "limit должен быть от 1 до "+strconv.Itoa(maxAuditLimit))
+				return
+			}
+		}
+
+		entries, err := l.Query(q)
+		if err != nil {
+			log.Printf("Ошибка чтения журнала аудита: %v", err)
+			errorResponse(w, http.StatusInternalServerError, "Ошибка чтения журнала аудита")
+			return
+		}
+		if entries == nil {
+			entries = []audit.Entry{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(entries); err != nil {
+			errorResponse(w, http.StatusInternalServerError, "Ошибка кодирования ответа")
+		}
+	}
+}
+
+// auditVerifyHandler проверяет целостность цепочки хэшей журнала аудита
+func auditVerifyHandler(l *audit.Log) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		n, err := l.Verify()
+		resp := struct {
+			Valid   bool   `json:"valid"`
+			Entries int    `json:"entries"`
+			Error   string `json:"error,omitempty"`
+		}{Valid: err == nil, Entries: n}
+		if err != nil {
+			resp.Error = err.Error()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			errorResponse(w, http.StatusInternalServerError, "Ошибка кодирования ответа")
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"workmateTestProject/internal/auth"
+	"workmateTestProject/internal/service"
+	"workmateTestProject/internal/storage"
+)
+
+// authMiddleware проверяет заголовок Authorization: Bearer <токен> (API-ключ или JWT)
+// и кладёт субъекта в контекст запроса.
+// Если authn == nil, аутентификация отключена и все запросы выполняются от имени auth.Anonymous.
+func authMiddleware(authn auth.Authenticator) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if authn == nil {
+				setAuditPrincipal(r, auth.Anonymous)
+				next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), auth.Anonymous)))
+				return
+			}
+			token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || token == "" {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="tasks"`)
+				errorResponse(w, http.StatusUnauthorized, "Требуется API-ключ или токен")
+				return
+			}
+			p, ok := authn.Authenticate(r.Context(), token)
+			if !ok {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="tasks", error="invalid_token"`)
+				errorResponse(w, http.StatusUnauthorized, "Неверный API-ключ или токен")
+				return
+			}
+			setAuditPrincipal(r, p)
+			next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), p)))
+		})
+	}
+}
+
+// requireScope пропускает запрос к h, только если у субъекта есть право scope
+func requireScope(scope auth.Scope, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, ok := auth.FromContext(r.Context())
+		if !ok {
+			errorResponse(w, http.StatusUnauthorized, "Требуется API-ключ или токен")
+			return
+		}
+		if !p.HasScope(scope) {
+			errorResponse(w, http.StatusForbidden, "Недостаточно прав: требуется "+string(scope))
+			return
+		}
+		h(w, r)
+	}
+}
+
+// principal возвращает субъекта запроса; без аутентификации - auth.Anonymous
+func principal(r *http.Request) *auth.Principal {
+	if p, ok := auth.FromContext(r.Context()); ok {
+		return p
+	}
+	return auth.Anonymous
+}
+
+// tenantStore возвращает представление хранилища, ограниченное арендатором субъекта запроса.
+// Мягко удалённые задачи в нём не видны; признак удаления читается через обработчик proc.
+func tenantStore(r *http.Request, proc *service.Processor, store storage.TaskStore) storage.TaskStore {
+	return storage.ForTenant(storage.WithoutDeleted(store, proc.Deleted), principal(r).Tenant)
+}
+
+// listKeysHandler возвращает метаданные всех API-ключей (без самих ключей)
+func listKeysHandler(keys *auth.KeyStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		list := keys.List()
+		// Хэши ключей наружу не отдаём
+		for i := range list {
+			list[i].Hash = ""
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(list); err != nil {
+			errorResponse(w, http.StatusInternalServerError, "Ошибка кодирования ответа")
+		}
+	}
+}
+
+// createKeyHandler выпускает новый API-ключ; открытое значение возвращается только в этом ответе
+func createKeyHandler(keys *auth.KeyStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Name   string       `json:"name"`
+			Tenant string       `json:"tenant"`
+			Scopes []auth.Scope `json:"scopes"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			errorResponse(w, http.StatusBadRequest, "Неверный JSON")
+			return
+		}
+		if req.Name == "" || len(req.Scopes) == 0 {
+			errorResponse(w, http.StatusBadRequest, "Поля name и scopes обязательны")
+			return
+		}
+		key, meta, err := keys.Generate(req.Name, req.Tenant, req.Scopes)
+		if errors.Is(err, auth.ErrUnknownScope) {
+			errorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err != nil {
+			log.Printf("Ошибка создания API-ключа: %v", err)
+			errorResponse(w, http.StatusInternalServerError, "Не удалось создать ключ")
+			return
+		}
+
+		setAuditTarget(r, meta.ID)
+		meta.Hash = ""
+		type respT struct {
+			auth.APIKey
+			Key string `json:"key"`
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		if err := json.NewEncoder(w).Encode(respT{APIKey: meta, Key: key}); err != nil {
+			errorResponse(w, http.StatusInternalServerError, "Ошибка кодирования ответа")
+		}
+	}
+}
+
+// revokeKeyHandler отзывает API-ключ по ID
+func revokeKeyHandler(keys *auth.KeyStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ok, err := keys.Revoke(mux.Vars(r)["id"])
+		if err != nil {
+			log.Printf("Ошибка отзыва API-ключа: %v", err)
+			errorResponse(w, http.StatusInternalServerError, "Не удалось отозвать ключ")
+			return
+		}
+		if !ok {
+			errorResponse(w, http.StatusNotFound, "Ключ не найден")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"workmateTestProject/internal/bench"
+	"workmateTestProject/internal/model"
+	"workmateTestProject/internal/service"
+	"workmateTestProject/internal/storage"
+)
+
+// benchStores - хранилища задач, на которых bench может запустить сервер в процессе.
+// Сейчас у сервиса одно хранилище - в памяти. Представления WithoutDeleted и ForTenant
+// регистрировать не нужно: обработчики API накладывают их на каждый запрос, как и в рабочем сервере.
+var benchStores = map[string]func() storage.TaskStore{
+	"memory": storage.NewInMemoryTaskStore,
+}
+
+// runBench выполняет подкоманду bench: нагрузку на API удалённого сервера (-url) или сервера,
+// запущенного в процессе с каждым из выбранных хранилищ задач. Отчёт выводится таблицей,
+// с -out сохраняется в JSON, а с -compare сравнивается с сохранённым ранее.
+// Отмена ctx прерывает испытание; отчёт по выполненным операциям при этом всё равно выводится.
+func runBench(ctx context.Context, args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("bench", flag.ContinueOnError)
+	url := fs.String("url", "", "адрес сервера; без него сервер запускается в процессе")
+	token := fs.String("token", os.Getenv("BENCH_TOKEN"), "API-ключ или JWT (по умолчанию BENCH_TOKEN)")
+	store := fs.String("store", "memory", "хранилища для сервера в процессе через запятую или all: "+strings.Join(benchStoreNames(), ", "))
+	rate := fs.Float64("rate", 100, "операций в секунду")
+	duration := fs.Duration("duration", 10*time.Second, "длительность нагрузки")
+	workers := fs.Int("workers", 64, "одновременных запросов")
+	mixSpec := fs.String("mix", bench.DefaultMix.String(), "веса операций create, get, list и cancel")
+	taskType := fs.String("type", "bench", "тип создаваемых задач")
+	work := fs.Duration("work", 0, "длительность симулируемой обработки задачи (профиль simulation в payload)")
+	concurrency := fs.Int("concurrency", 0, "размер пула обработчика сервера в процессе (по умолчанию MAX_CONCURRENT_TASKS)")
+	drain := fs.Duration("drain", 30*time.Second, "сколько ждать, пока обработчик разберёт очередь; 0 - не ждать")
+	seed := fs.Int64("seed", 1, "начальное значение выбора операций")
+	out := fs.String("out", "", "файл для отчёта в JSON")
+	compare := fs.String("compare", "", "отчёт в JSON, с которым сравнить результат")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	mix, err := bench.ParseMix(*mixSpec)
+	if err != nil {
+		return fmt.Errorf("-mix: %w", err)
+	}
+	var base []*bench.Report
+	if *compare != "" {
+		if base, err = bench.ReadFile(*compare); err != nil {
+			return err
+		}
+	}
+	// Длительность обработки задаётся профилем симулятора в payload, поэтому работает и для удалённого сервера
+	payload, _ := json.Marshal(map[string]any{"simulation": service.SimulationProfile{
+		Duration: service.DurationDist{Dist: service.DistFixed, Value: model.Duration(*work)},
+	}})
+	cfg := bench.Config{
+		URL: *url, Token: *token, Rate: *rate, Duration: *duration, Workers: *workers, Mix: mix,
+		TaskType: *taskType, Payload: payload, Drain: *drain, Seed: *seed,
+	}
+
+	var (
+		reports []*bench.Report
+		runErr  error
+	)
+	if *url != "" {
+		var rep *bench.Report
+		if rep, runErr = bench.Run(ctx, cfg); rep != nil {
+			reports = append(reports, rep)
+		}
+	} else {
+		stores, err := parseBenchStores(*store)
+		if err != nil {
+			return err
+		}
+		if *concurrency > 0 {
+			if err := service.SetMaxConcurrent(*concurrency); err != nil {
+				return err
+			}
+		}
+		for _, name := range stores {
+			rep, err := benchInProcess(ctx, cfg, name)
+			if rep != nil {
+				reports = append(reports, rep)
+			}
+			if err != nil {
+				runErr = fmt.Errorf("%s: %w", name, err)
+				break
+			}
+		}
+	}
+
+	// Прерванное испытание всё равно выводит и сохраняет отчёт по выполненным операциям
+	if runErr != nil && len(reports) > 0 {
+		fmt.Fprintf(stdout, "Испытание прервано (%v), отчёт неполный\n\n", runErr)
+	}
+	for _, rep := range reports {
+		bench.WriteText(stdout, rep)
+		fmt.Fprintln(stdout)
+	}
+	if base != nil && len(reports) > 0 {
+		bench.Compare(stdout, base, reports)
+	}
+	if *out != "" && len(reports) > 0 {
+		if err := bench.WriteFile(*out, reports); err != nil {
+			return errors.Join(runErr, err)
+		}
+	}
+	return runErr
+}
+
+// benchInProcess запускает API с хранилищем name на свободном локальном порту и нагружает его.
+// Обработчик задач общий для всех запусков, поэтому каждый запуск ждёт, пока он разберёт очередь.
+// Вместе с ошибкой прерванного испытания возвращается неполный отчёт.
+func benchInProcess(ctx context.Context, cfg bench.Config, name string) (*bench.Report, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	srv := &http.Server{Handler: newRouter(apiDeps{store: benchStores[name]()})}
+	go srv.Serve(ln)
+	defer srv.Close()
+
+	cfg.URL = "http://" + ln.Addr().String()
+	rep, err := bench.Run(ctx, cfg)
+	if rep != nil {
+		rep.Target, rep.Store = "in-process", name
+	}
+	return rep, err
+}
+
+// parseBenchStores разбирает список хранилищ; all - все доступные
+func parseBenchStores(spec string) ([]string, error) {
+	if spec == "all" {
+		return benchStoreNames(), nil
+	}
+	var names []string
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if _, ok := benchStores[name]; !ok {
+			return nil, fmt.Errorf("неизвестное хранилище %q, доступны: %s", name, strings.Join(benchStoreNames(), ", "))
+		}
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return nil, errors.New("не выбрано ни одного хранилища")
+	}
+	return names, nil
+}
+
+func benchStoreNames() []string {
+	names := make([]string, 0, len(benchStores))
+	for name := range benchStores {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,74 @@
+// Эталонный внешний исполнитель: пример реализации протокола внешних исполнителей
+// (internal/executor/protocol.go) и объект проверки набора тестов совместимости.
+//
+// Типы задач:
+//   - echo - возвращает payload как результат;
+//   - sleep - payload {"duration": "2s", "steps": 4, "fail": "сообщение"}: ждёт duration,
+//     сообщая о ходе обработки steps раз, и завершается ошибкой fail, если она задана.
+//
+// Запуск в сервисе: PROCESS_EXECUTORS="echo=/usr/local/bin/reference-executor,sleep=/usr/local/bin/reference-executor"
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"workmateTestProject/internal/executor"
+	"workmateTestProject/internal/model"
+)
+
+func main() {
+	log.SetFlags(0)
+	if err := executor.Serve(os.Stdin, os.Stdout, []string{"echo", "sleep"}, handle); err != nil {
+		log.Fatalf("чтение stdin: %v", err)
+	}
+}
+
+// handle выполняет задачу по её типу
+func handle(ctx context.Context, task *executor.TaskSpec, r *executor.Reporter) (string, error) {
+	switch task.Type {
+	case "echo":
+		r.Logf("payload: %d байт", len(task.Payload))
+		return string(task.Payload), nil
+	case "sleep":
+		return sleep(ctx, task, r)
+	default:
+		return "", fmt.Errorf("неизвестный тип задачи %q", task.Type)
+	}
+}
+
+// sleep ждёт заданное время, сообщая о ходе обработки
+func sleep(ctx context.Context, task *executor.TaskSpec, r *executor.Reporter) (string, error) {
+	var spec struct {
+		Duration model.Duration `json:"duration"`
+		Steps    int            `json:"steps"`
+		Fail     string         `json:"fail"`
+	}
+	if len(task.Payload) > 0 {
+		if err := json.Unmarshal(task.Payload, &spec); err != nil {
+			return "", fmt.Errorf("payload: %v", err)
+		}
+	}
+	if spec.Steps <= 0 {
+		spec.Steps = 1
+	}
+	step := time.Duration(spec.Duration) / time.Duration(spec.Steps)
+	for i := 1; i <= spec.Steps; i++ {
+		select {
+		case <-ctx.Done():
+			r.Logf("Отменено на шаге %d из %d", i, spec.Steps)
+			return "", errors.New("обработка отменена")
+		case <-time.After(step):
+		}
+		r.Progressf("Шаг %d из %d", i, spec.Steps)
+	}
+	if spec.Fail != "" {
+		return "", errors.New(spec.Fail)
+	}
+	return fmt.Sprintf("Ожидание %s завершено", time.Duration(spec.Duration)), nil
+}
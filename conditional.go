@@ -0,0 +1,93 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+	"workmateTestProject/internal/model"
+)
+
+// taskLocks сериализуют изменяющие запросы к одной задаче, чтобы проверка If-Match
+// и само изменение выполнялись без вмешательства другого запроса
+var taskLocks [64]sync.Mutex
+
+// lockTask блокирует изменения задачи id и возвращает функцию разблокировки
+func lockTask(id uuid.UUID) func() {
+	m := &taskLocks[id[0]%byte(len(taskLocks))]
+	m.Lock()
+	return m.Unlock
+}
+
+// lockTasks блокирует изменения задач ids и возвращает функцию разблокировки.
+// Блокировки захватываются по возрастанию номера, поэтому одновременные вызовы
+// не блокируют друг друга навечно.
+func lockTasks(ids []uuid.UUID) func() {
+	var used [len(taskLocks)]bool
+	for _, id := range ids {
+		used[id[0]%byte(len(taskLocks))] = true
+	}
+	for i := range used {
+		if used[i] {
+			taskLocks[i].Lock()
+		}
+	}
+	return func() {
+		for i := range used {
+			if used[i] {
+				taskLocks[i].Unlock()
+			}
+		}
+	}
+}
+
+// taskETag возвращает ETag задачи по её версии
+func taskETag(task *model.Task) string {
+	return `"` + strconv.FormatInt(task.Version, 10) + `"`
+}
+
+// setTaskETag передаёт клиенту текущую версию задачи
+func setTaskETag(w http.ResponseWriter, task *model.Task) {
+	w.Header().Set("ETag", taskETag(task))
+}
+
+// etagListMatch сообщает, есть ли etag в списке заголовка If-Match или If-None-Match.
+// "*" совпадает с любой версией; weak допускает слабые метки W/"...".
+func etagListMatch(header, etag string, weak bool) bool {
+	for _, v := range strings.Split(header, ",") {
+		v = strings.TrimSpace(v)
+		if weak {
+			v = strings.TrimPrefix(v, "W/")
+		}
+		if v == "*" || v == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// checkIfMatch проверяет заголовок If-Match изменяющего запроса.
+// При несовпадении версии отвечает 412 с текущим ETag и возвращает false.
+func checkIfMatch(w http.ResponseWriter, r *http.Request, task *model.Task) bool {
+	header := r.Header.Get("If-Match")
+	if header == "" || etagListMatch(header, taskETag(task), false) {
+		return true
+	}
+	setTaskETag(w, task)
+	errorResponse(w, http.StatusPreconditionFailed, "Задача изменилась: версия не совпадает с If-Match")
+	return false
+}
+
+// notModified проверяет заголовок If-None-Match запроса чтения.
+// Если версия клиента актуальна, отвечает 304 и возвращает true.
+func notModified(w http.ResponseWriter, r *http.Request, task *model.Task) bool {
+	header := r.Header.Get("If-None-Match")
+	if header == "" || !etagListMatch(header, taskETag(task), true) {
+		return false
+	}
+	setTaskETag(w, task)
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"workmateTestProject/internal/executor"
+	"workmateTestProject/internal/service"
+)
+
+// loadExecutors назначает встроенных и внешних исполнителей типам задач обработчика proc по настройкам окружения.
+// Задачи остальных типов выполняет симулятор. Возвращает функцию остановки процессов
+// внешних исполнителей, которую нужно вызвать после завершения задач.
+func loadExecutors(proc *service.Processor) (func(), error) {
+	// Профили симулятора для нагрузочных испытаний
+	if path := os.Getenv("SIMULATOR_PROFILES_FILE"); path != "" {
+		if err := proc.LoadSimulationProfiles(path); err != nil {
+			return nil, fmt.Errorf("профили симулятора: %w", err)
+		}
+		log.Printf("Профили симулятора загружены из %s", path)
+	}
+
+	// HTTP-запросы к внутренним сервисам: без списка разрешённых узлов исполнитель отключён
+	if hosts := executor.ParseHosts(os.Getenv("HTTP_EXECUTOR_ALLOWED_HOSTS")); len(hosts) > 0 {
+		maxBody, _ := strconv.ParseInt(os.Getenv("HTTP_EXECUTOR_MAX_BODY"), 10, 64)
+		proc.RegisterExecutor("http", executor.NewHTTP(executor.HTTPConfig{AllowedHosts: hosts, MaxBody: maxBody}))
+		log.Printf("HTTP-исполнитель включён для узлов: %v", hosts)
+	}
+
+	// Команды из списка шаблонов, который ведёт администратор
+	if path := os.Getenv("SHELL_EXECUTOR_COMMANDS"); path != "" {
+		commands, err := executor.LoadShellCommands(path)
+		if err != nil {
+			return nil, fmt.Errorf("шаблоны команд: %w", err)
+		}
+		shell, err := executor.NewShell(executor.ShellConfig{
+			Commands:  commands,
+			KillGrace: envDuration("SHELL_EXECUTOR_KILL_GRACE", 0),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("шаблоны команд: %w", err)
+		}
+		proc.RegisterExecutor("shell", shell)
+		log.Printf("Shell-исполнитель включён для команд: %v", shell.Commands())
+	}
+
+	// Внешние исполнители: типы с одинаковой командой обслуживает один процесс
+	var processes []*executor.Process
+	stop := func() {
+		for _, p := range processes {
+			p.Close()
+		}
+	}
+	specs, err := executor.ParseProcesses(os.Getenv("PROCESS_EXECUTORS"))
+	if err != nil {
+		return nil, fmt.Errorf("PROCESS_EXECUTORS: %w", err)
+	}
+	typesByCommand := make(map[string][]string)
+	for typ, argv := range specs {
+		key := strings.Join(argv, "\x00")
+		typesByCommand[key] = append(typesByCommand[key], typ)
+	}
+	byCommand := make(map[string]*executor.Process)
+	for typ, argv := range specs {
+		key := strings.Join(argv, "\x00")
+		p, ok := byCommand[key]
+		if !ok {
+			p, err = executor.NewProcess(executor.ProcessConfig{
+				Argv:         argv,
+				Types:        typesByCommand[key],
+				StartTimeout: envDuration("PROCESS_EXECUTOR_START_TIMEOUT", 0),
+				CancelGrace:  envDuration("PROCESS_EXECUTOR_CANCEL_GRACE", 0),
+			})
+			if err != nil {
+				stop()
+				return nil, fmt.Errorf("PROCESS_EXECUTORS: %s: %w", typ, err)
+			}
+			byCommand[key] = p
+			processes = append(processes, p)
+		}
+		proc.RegisterExecutor(typ, p)
+		log.Printf("Задачи типа %s выполняет внешний исполнитель %s", typ, argv[0])
+	}
+	// Процессы запускаются сразу: исполнитель, не объявивший назначенный ему тип, - ошибка настройки
+	for _, p := range processes {
+		if err := p.Start(context.Background()); err != nil {
+			stop()
+			return nil, fmt.Errorf("PROCESS_EXECUTORS: %w", err)
+		}
+	}
+	return stop, nil
+}
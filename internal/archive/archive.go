@@ -0,0 +1,191 @@
+package archive
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"workmateTestProject/internal/model"
+)
+
+// ErrNotFound возвращается, если задачи нет в архиве
+var ErrNotFound = errors.New("задача не найдена в архиве")
+
+// segmentLayout - формат даты в имени файла сегмента
+const segmentLayout = "2006-01-02"
+
+// Record - запись архива: задача и момент её архивирования
+type Record struct {
+	ArchivedAt time.Time   `json:"archived_at"`
+	Task       *model.Task `json:"task"`
+}
+
+// Query - условия поиска в архиве. Нулевые поля не ограничивают выборку.
+type Query struct {
+	// From и To ограничивают время создания задачи: From <= created_at < To
+	From, To time.Time
+	ID       uuid.UUID
+	Tenant   string
+	// Limit - максимальное число записей в ответе
+	Limit int
+}
+
+func (q Query) match(task *model.Task) bool {
+	return (q.ID == uuid.Nil || task.ID == q.ID) &&
+		(q.Tenant == "" || task.Tenant == q.Tenant) &&
+		(q.From.IsZero() || !task.CreatedAt.Before(q.From)) &&
+		(q.To.IsZero() || task.CreatedAt.Before(q.To))
+}
+
+// Archive хранит задачи в сжатых NDJSON-сегментах, по одному файлу на день архивирования.
+// Каждый вызов Append дописывает в сегмент отдельный gzip-поток; многопоточный gzip
+// читается стандартным gzip.Reader как единое целое.
+type Archive struct {
+	mu  sync.Mutex
+	dir string
+	now func() time.Time
+}
+
+// New создаёт архив в каталоге dir, создавая его при необходимости
+func New(dir string) (*Archive, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Archive{dir: dir, now: time.Now}, nil
+}
+
+// Append добавляет задачи в сегмент текущего дня
+func (a *Archive) Append(tasks []*model.Task) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	now := a.now().UTC()
+	f, err := os.OpenFile(filepath.Join(a.dir, "tasks-"+now.Format(segmentLayout)+".ndjson.gz"),
+		os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(f)
+	enc := json.NewEncoder(zw)
+	for _, task := range tasks {
+		if err = enc.Encode(Record{ArchivedAt: now, Task: task}); err != nil {
+			break
+		}
+	}
+	if cerr := zw.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Search возвращает подходящие записи, отсортированные по времени создания задачи.
+// Если задача архивировалась несколько раз, возвращается последняя запись.
+func (a *Archive) Search(q Query) ([]Record, error) {
+	segments, err := a.segments(q.From)
+	if err != nil {
+		return nil, err
+	}
+	latest := make(map[uuid.UUID]Record)
+	for _, path := range segments {
+		err := readSegment(path, func(rec Record) {
+			if rec.Task != nil && q.match(rec.Task) && !rec.ArchivedAt.Before(latest[rec.Task.ID].ArchivedAt) {
+				latest[rec.Task.ID] = rec
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	records := make([]Record, 0, len(latest))
+	for _, rec := range latest {
+		records = append(records, rec)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if !records[i].Task.CreatedAt.Equal(records[j].Task.CreatedAt) {
+			return records[i].Task.CreatedAt.Before(records[j].Task.CreatedAt)
+		}
+		return records[i].Task.ID.String() < records[j].Task.ID.String()
+	})
+	if q.Limit > 0 && len(records) > q.Limit {
+		records = records[:q.Limit]
+	}
+	return records, nil
+}
+
+// Get возвращает последнюю архивную запись задачи арендатора tenant (пустой tenant - любого)
+func (a *Archive) Get(id uuid.UUID, tenant string) (Record, error) {
+	records, err := a.Search(Query{ID: id, Tenant: tenant})
+	if err != nil {
+		return Record{}, err
+	}
+	if len(records) == 0 {
+		return Record{}, ErrNotFound
+	}
+	return records[0], nil
+}
+
+// segments возвращает файлы сегментов, которые могут содержать задачи, созданные не раньше from.
+// Задача архивируется не раньше своего создания, поэтому сегменты за более ранние дни пропускаются.
+func (a *Archive) segments(from time.Time) ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(a.dir, "tasks-*.ndjson.gz"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	if from.IsZero() {
+		return paths, nil
+	}
+	fromDay := from.UTC().Format(segmentLayout)
+	out := paths[:0]
+	for _, path := range paths {
+		day := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(path), "tasks-"), ".ndjson.gz")
+		if day >= fromDay {
+			out = append(out, path)
+		}
+	}
+	return out, nil
+}
+
+// readSegment читает записи сегмента; повреждённый хвост (например, после сбоя при записи) пропускается
+func readSegment(path string, fn func(Record)) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil
+	}
+	defer zr.Close()
+	sc := bufio.NewScanner(zr)
+	sc.Buffer(make([]byte, 64*1024), 16<<20)
+	for sc.Scan() {
+		var rec Record
+		if json.Unmarshal(sc.Bytes(), &rec) == nil {
+			fn(rec)
+		}
+	}
+	if err := sc.Err(); err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, gzip.ErrChecksum) {
+		return err
+	}
+	return nil
+}
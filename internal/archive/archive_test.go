@@ -0,0 +1,69 @@
+package archive
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"workmateTestProject/internal/model"
+)
+
+// TestArchive_AppendSearch проверяет ротацию сегментов по дням, поиск по времени, ID и арендатору
+// и выбор последней записи для задачи, архивированной повторно.
+func TestArchive_AppendSearch(t *testing.T) {
+	dir := t.TempDir()
+	a, err := New(dir)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	day1 := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	day2 := day1.AddDate(0, 0, 1)
+	task := func(tenant string, created time.Time) *model.Task {
+		return &model.Task{ID: uuid.New(), Tenant: tenant, Status: model.StatusCompleted, CreatedAt: created}
+	}
+	old := task("a", day1.Add(-time.Hour))
+	other := task("b", day1.Add(-time.Minute))
+	recent := task("a", day2.Add(-time.Hour))
+
+	a.now = func() time.Time { return day1 }
+	if err := a.Append([]*model.Task{old, other}); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	a.now = func() time.Time { return day2 }
+	if err := a.Append([]*model.Task{recent}); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	// Повторное архивирование той же задачи в тот же день дописывает новый gzip-поток
+	old.Result = "обновлено"
+	if err := a.Append([]*model.Task{old}); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "*.ndjson.gz")); len(files) != 2 {
+		t.Errorf("ожидалось 2 сегмента, получили %v", files)
+	}
+
+	all, err := a.Search(Query{Tenant: "a"})
+	if err != nil || len(all) != 2 || all[0].Task.ID != old.ID || all[0].Task.Result != "обновлено" {
+		t.Fatalf("неверный результат поиска по арендатору: %+v (%v)", all, err)
+	}
+	ranged, _ := a.Search(Query{From: day1, To: day2})
+	if len(ranged) != 1 || ranged[0].Task.ID != recent.ID {
+		t.Errorf("ожидалась только задача из диапазона, получили %+v", ranged)
+	}
+	if _, err := a.Get(other.ID, "a"); err != ErrNotFound {
+		t.Errorf("задача чужого арендатора не должна находиться, получили %v", err)
+	}
+	if rec, err := a.Get(other.ID, ""); err != nil || rec.Task.Tenant != "b" {
+		t.Errorf("Get: %+v (%v)", rec, err)
+	}
+
+	// Обрыв записи не должен ломать чтение уже записанного
+	f, _ := os.OpenFile(filepath.Join(dir, "tasks-2025-06-02.ndjson.gz"), os.O_WRONLY|os.O_APPEND, 0)
+	f.Write([]byte{0x1f, 0x8b, 0x08})
+	f.Close()
+	if recs, err := a.Search(Query{}); err != nil || len(recs) != 3 {
+		t.Errorf("после повреждённого хвоста ожидалось 3 записи, получили %d (%v)", len(recs), err)
+	}
+}
@@ -0,0 +1,293 @@
+package audit
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// ErrChainBroken возвращается, если цепочка хэшей журнала нарушена - записи изменены или удалены
+var ErrChainBroken = errors.New("цепочка хэшей журнала аудита нарушена")
+
+// Исходы операций
+const (
+	OutcomeSuccess = "success"
+	OutcomeDenied  = "denied"
+	OutcomeFailure = "failure"
+)
+
+// Entry - запись журнала аудита. Hash вычисляется по всем остальным полям, включая PrevHash,
+// поэтому изменение или удаление любой записи обнаруживается при проверке цепочки.
+type Entry struct {
+	Seq          int64     `json:"seq"`
+	Time         time.Time `json:"time"`
+	Principal    string    `json:"principal,omitempty"`
+	Tenant       string    `json:"tenant,omitempty"`
+	Action       string    `json:"action"`
+	Target       string    `json:"target,omitempty"`
+	RequestID    string    `json:"request_id"`
+	SourceIP     string    `json:"source_ip"`
+	ForwardedFor string    `json:"forwarded_for,omitempty"`
+	Status       int       `json:"status"`
+	Outcome      string    `json:"outcome"`
+	PrevHash     string    `json:"prev_hash"`
+	Hash         string    `json:"hash"`
+}
+
+// computeHash вычисляет хэш записи без учёта поля Hash
+func (e Entry) computeHash() string {
+	e.Hash = ""
+	data, _ := json.Marshal(e)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Query - условия выборки записей. Нулевые поля не ограничивают выборку.
+type Query struct {
+	// Tenants - арендаторы, записи которых видны; пустой список - все
+	Tenants   []string
+	Principal string
+	Action    string
+	Target    string
+	From, To  time.Time
+	// AfterSeq - вернуть записи с номером больше указанного
+	AfterSeq int64
+	Limit    int
+}
+
+func (q Query) match(e *Entry) bool {
+	if len(q.Tenants) > 0 {
+		found := false
+		for _, t := range q.Tenants {
+			if e.Tenant == t {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return (q.Principal == "" || e.Principal == q.Principal) &&
+		(q.Action == "" || e.Action == q.Action) &&
+		(q.Target == "" || e.Target == q.Target) &&
+		(q.From.IsZero() || !e.Time.Before(q.From)) &&
+		(q.To.IsZero() || e.Time.Before(q.To)) &&
+		e.Seq > q.AfterSeq
+}
+
+// memoryEntries - сколько последних записей хранит в памяти журнал без файла
+const memoryEntries = 10000
+
+// Log - журнал аудита, в который можно только дописывать. Если задан файл, записи дописываются
+// в него в формате NDJSON и выборки читают файл; в памяти остаётся только голова цепочки.
+// Без файла в памяти хранятся последние memoryEntries записей.
+type Log struct {
+	mu sync.Mutex
+	// seq и last - номер и хэш последней записи
+	seq  int64
+	last string
+
+	path string
+	file *os.File
+	// size - размер записанной части файла; выборки читают файл до этой границы
+	size int64
+
+	// recent - последние записи по кругу для журнала без файла, next - место следующей записи
+	recent   []Entry
+	next     int
+	capacity int
+}
+
+// chain проверяет цепочку хэшей записей, переданных по порядку
+type chain struct {
+	n    int
+	seq  int64
+	last string
+	// partial - цепочка может начинаться не с первой записи (старые записи вытеснены из памяти)
+	partial bool
+}
+
+func (c *chain) check(e *Entry) error {
+	linked := e.Seq == c.seq+1 && e.PrevHash == c.last
+	if c.partial && c.n == 0 {
+		linked = true
+	}
+	if !linked || e.computeHash() != e.Hash {
+		return fmt.Errorf("%w на записи %d", ErrChainBroken, c.n+1)
+	}
+	c.n++
+	c.seq, c.last = e.Seq, e.Hash
+	return nil
+}
+
+// scanFile передаёт fn записи из первых size байт файла path (size < 0 - весь файл)
+func scanFile(path string, size int64, fn func(e *Entry) (bool, error)) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if size >= 0 {
+		r = io.LimitReader(f, size)
+	}
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1<<20)
+	for n := 1; sc.Scan(); n++ {
+		var e Entry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			return fmt.Errorf("%w: запись %d не читается: %v", ErrChainBroken, n, err)
+		}
+		if more, err := fn(&e); err != nil || !more {
+			return err
+		}
+	}
+	return sc.Err()
+}
+
+// Open открывает журнал. Непустой path - файл журнала: существующие записи проверяются,
+// новые дописываются в конец. Нарушенная цепочка возвращает ErrChainBroken.
+func Open(path string) (*Log, error) {
+	l := &Log{capacity: memoryEntries}
+	if path == "" {
+		return l, nil
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	var c chain
+	err = scanFile(path, -1, func(e *Entry) (bool, error) { return true, c.check(e) })
+	if err == nil {
+		var info os.FileInfo
+		if info, err = f.Stat(); err == nil {
+			l.size = info.Size()
+		}
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	l.path, l.file = path, f
+	l.seq, l.last = c.seq, c.last
+	return l, nil
+}
+
+// Append дописывает запись, заполняя Seq, PrevHash и Hash, и возвращает её
+func (l *Log) Append(e Entry) (Entry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	e.Seq = l.seq + 1
+	e.PrevHash = l.last
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	e.Time = e.Time.UTC()
+	e.Hash = e.computeHash()
+
+	if l.file != nil {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return Entry{}, err
+		}
+		n, err := l.file.Write(append(data, '\n'))
+		l.size += int64(n)
+		if err != nil {
+			return Entry{}, err
+		}
+	} else if len(l.recent) < l.capacity {
+		l.recent = append(l.recent, e)
+	} else {
+		l.recent[l.next] = e
+		l.next = (l.next + 1) % l.capacity
+	}
+	l.seq, l.last = e.Seq, e.Hash
+	return e, nil
+}
+
+// snapshot возвращает записи журнала без файла в порядке добавления
+func (l *Log) snapshot() []Entry {
+	out := make([]Entry, 0, len(l.recent))
+	out = append(out, l.recent[l.next:]...)
+	return append(out, l.recent[:l.next]...)
+}
+
+// Query возвращает подходящие записи в порядке добавления
+func (l *Log) Query(q Query) ([]Entry, error) {
+	l.mu.Lock()
+	if l.file == nil {
+		entries := l.snapshot()
+		l.mu.Unlock()
+		var out []Entry
+		for i := range entries {
+			if q.match(&entries[i]) {
+				out = append(out, entries[i])
+				if q.Limit > 0 && len(out) >= q.Limit {
+					break
+				}
+			}
+		}
+		return out, nil
+	}
+	path, size := l.path, l.size
+	l.mu.Unlock()
+
+	var out []Entry
+	err := scanFile(path, size, func(e *Entry) (bool, error) {
+		if q.match(e) {
+			out = append(out, *e)
+		}
+		return q.Limit <= 0 || len(out) < q.Limit, nil
+	})
+	return out, err
+}
+
+// Verify проверяет цепочку хэшей и возвращает число проверенных записей.
+// При нарушении ошибка содержит номер первой неверной записи. Журнал без файла
+// проверяется по записям, оставшимся в памяти.
+func (l *Log) Verify() (int, error) {
+	l.mu.Lock()
+	if l.file == nil {
+		entries := l.snapshot()
+		l.mu.Unlock()
+		c := chain{partial: true}
+		for i := range entries {
+			if err := c.check(&entries[i]); err != nil {
+				return c.n, err
+			}
+		}
+		return c.n, nil
+	}
+	path, size, seq, last := l.path, l.size, l.seq, l.last
+	l.mu.Unlock()
+
+	var c chain
+	if err := scanFile(path, size, func(e *Entry) (bool, error) { return true, c.check(e) }); err != nil {
+		return c.n, err
+	}
+	// Удаление записей с конца файла не ломает цепочку, но расходится с её головой в памяти
+	if c.seq != seq || c.last != last {
+		return c.n, fmt.Errorf("%w: после записи %d", ErrChainBroken, c.n)
+	}
+	return c.n, nil
+}
+
+// Close закрывает файл журнала
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
@@ -0,0 +1,92 @@
+package audit
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestLog_ChainAndTamper проверяет цепочку хэшей, выборку записей, загрузку из файла
+// и обнаружение изменённой записи.
+func TestLog_ChainAndTamper(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	l, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	for _, e := range []Entry{
+		{Principal: "alice", Tenant: "a", Action: "POST /tasks", Target: "t1", Status: 201, Outcome: OutcomeSuccess},
+		{Principal: "bob", Tenant: "b", Action: "DELETE /tasks/{id}", Target: "t2", Status: 204, Outcome: OutcomeSuccess},
+		{Principal: "alice", Tenant: "a", Action: "DELETE /tasks/{id}", Target: "t1", Status: 204, Outcome: OutcomeSuccess},
+	} {
+		if _, err := l.Append(e); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+	if got, err := l.Query(Query{Tenants: []string{"a"}, Action: "DELETE /tasks/{id}"}); err != nil || len(got) != 1 || got[0].Seq != 3 {
+		t.Errorf("неверная выборка: %+v, %v", got, err)
+	}
+	if got, err := l.Query(Query{AfterSeq: 1, Limit: 1}); err != nil || len(got) != 1 || got[0].Seq != 2 {
+		t.Errorf("неверная выборка с AfterSeq и Limit: %+v, %v", got, err)
+	}
+	l.Close()
+
+	// Журнал продолжается после повторного открытия
+	l, err = Open(path)
+	if err != nil {
+		t.Fatalf("повторный Open: %v", err)
+	}
+	e, _ := l.Append(Entry{Action: "POST /tasks", Outcome: OutcomeDenied, Status: 401})
+	if e.Seq != 4 {
+		t.Errorf("ожидался номер 4, получили %d", e.Seq)
+	}
+	if n, err := l.Verify(); err != nil || n != 4 {
+		t.Errorf("Verify: %d, %v", n, err)
+	}
+	l.Close()
+
+	// Удалённая с конца файла запись расходится с головой цепочки открытого журнала
+	l, _ = Open(path)
+	data, _ := os.ReadFile(path)
+	lines := strings.SplitAfter(strings.TrimSuffix(string(data), "\n"), "\n")
+	os.WriteFile(path, []byte(strings.Join(lines[:len(lines)-1], "")), 0o600)
+	if _, err := l.Verify(); !errors.Is(err, ErrChainBroken) {
+		t.Errorf("ожидалась ErrChainBroken после удаления последней записи, получили %v", err)
+	}
+	l.Close()
+	os.WriteFile(path, data, 0o600)
+
+	// Подменяем исполнителя удаления - цепочка должна сломаться
+	os.WriteFile(path, []byte(strings.Replace(string(data), `"principal":"bob"`, `"principal":"eve"`, 1)), 0o600)
+	if _, err := Open(path); !errors.Is(err, ErrChainBroken) {
+		t.Errorf("ожидалась ErrChainBroken, получили %v", err)
+	}
+}
+
+// TestLog_MemoryBounded проверяет, что журнал без файла хранит только последние записи,
+// а цепочка продолжается после вытеснения старых.
+func TestLog_MemoryBounded(t *testing.T) {
+	l, _ := Open("")
+	l.capacity = 3
+	for i := 0; i < 5; i++ {
+		if _, err := l.Append(Entry{Action: "POST /tasks", Outcome: OutcomeSuccess}); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+	got, err := l.Query(Query{})
+	if err != nil || len(got) != 3 || got[0].Seq != 3 || got[2].Seq != 5 {
+		t.Fatalf("ожидались записи 3-5, получили %+v, %v", got, err)
+	}
+	if len(l.recent) != 3 {
+		t.Errorf("в памяти %d записей, ожидалось 3", len(l.recent))
+	}
+	if n, err := l.Verify(); err != nil || n != 3 {
+		t.Errorf("Verify: %d, %v", n, err)
+	}
+	l.recent[1].Principal = "eve"
+	if _, err := l.Verify(); !errors.Is(err, ErrChainBroken) {
+		t.Errorf("ожидалась ErrChainBroken, получили %v", err)
+	}
+}
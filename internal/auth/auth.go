@@ -0,0 +1,248 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Scope описывает право доступа, выдаваемое API-ключу
+type Scope string
+
+const (
+	ScopeTasksRead  Scope = "tasks:read"
+	ScopeTasksWrite Scope = "tasks:write"
+	// ScopeTasksAdmin включает все остальные права и доступ к административным эндпоинтам
+	ScopeTasksAdmin Scope = "tasks:admin"
+)
+
+// ErrUnknownScope возвращается при попытке выдать несуществующее право
+var ErrUnknownScope = errors.New("неизвестный scope")
+
+// ValidScope сообщает, является ли s известным правом
+func ValidScope(s Scope) bool {
+	return s == ScopeTasksRead || s == ScopeTasksWrite || s == ScopeTasksAdmin
+}
+
+// DefaultTenant - арендатор, к которому относятся ключи без явно указанного арендатора
+const DefaultTenant = "default"
+
+// Principal - аутентифицированный субъект запроса
+type Principal struct {
+	Name   string
+	Tenant string
+	Roles  []string
+	Scopes []Scope
+}
+
+// Authenticator проверяет bearer-токен и возвращает соответствующего субъекта
+type Authenticator interface {
+	Authenticate(ctx context.Context, token string) (*Principal, bool)
+}
+
+// Chain пробует аутентификаторы по очереди и возвращает первого найденного субъекта
+type Chain []Authenticator
+
+// Authenticate реализует Authenticator
+func (c Chain) Authenticate(ctx context.Context, token string) (*Principal, bool) {
+	for _, a := range c {
+		if p, ok := a.Authenticate(ctx, token); ok {
+			return p, true
+		}
+	}
+	return nil, false
+}
+
+// Anonymous используется, когда аутентификация отключена, и имеет все права
+var Anonymous = &Principal{Name: "anonymous", Tenant: DefaultTenant, Scopes: []Scope{ScopeTasksAdmin}}
+
+// HasScope сообщает, обладает ли субъект правом s; tasks:admin подразумевает любое право
+func (p *Principal) HasScope(s Scope) bool {
+	for _, have := range p.Scopes {
+		if have == s || have == ScopeTasksAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+type principalKey struct{}
+
+// WithPrincipal возвращает контекст с привязанным субъектом
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext извлекает субъекта из контекста
+func FromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok
+}
+
+// APIKey описывает выданный ключ; сам ключ не хранится, только его SHA-256
+type APIKey struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Tenant    string    `json:"tenant,omitempty"`
+	Hash      string    `json:"hash,omitempty"`
+	Scopes    []Scope   `json:"scopes"`
+	CreatedAt time.Time `json:"created_at"`
+	// static - ключ задан конфигурацией процесса и не сохраняется в файл
+	static bool
+}
+
+// HashKey возвращает SHA-256 ключа в hex-представлении
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// KeyStore - потокобезопасное хранилище API-ключей.
+// Если задан путь к файлу, изменения сохраняются в него.
+type KeyStore struct {
+	mu     sync.RWMutex
+	path   string
+	byHash map[string]*APIKey
+}
+
+// NewKeyStore создаёт пустое хранилище ключей без файла
+func NewKeyStore() *KeyStore {
+	return &KeyStore{byHash: make(map[string]*APIKey)}
+}
+
+// LoadKeyFile загружает ключи из JSON-файла; отсутствующий файл даёт пустое хранилище,
+// которое будет создано при первом добавлении ключа
+func LoadKeyFile(path string) (*KeyStore, error) {
+	s := NewKeyStore()
+	s.path = path
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var keys []*APIKey
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, fmt.Errorf("разбор %s: %w", path, err)
+	}
+	for _, k := range keys {
+		for _, sc := range k.Scopes {
+			if !ValidScope(sc) {
+				return nil, fmt.Errorf("ключ %q: %w %q", k.ID, ErrUnknownScope, sc)
+			}
+		}
+		s.byHash[k.Hash] = k
+	}
+	return s, nil
+}
+
+// Authenticate возвращает субъекта, соответствующего ключу
+func (s *KeyStore) Authenticate(_ context.Context, key string) (*Principal, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	k, ok := s.byHash[HashKey(key)]
+	if !ok {
+		return nil, false
+	}
+	tenant := k.Tenant
+	if tenant == "" {
+		tenant = DefaultTenant
+	}
+	return &Principal{Name: k.Name, Tenant: tenant, Scopes: k.Scopes}, true
+}
+
+// AddStatic регистрирует ключ с заранее известным значением (например, из переменной окружения).
+// Такой ключ не сохраняется в файл и не может быть отозван через Revoke.
+func (s *KeyStore) AddStatic(name, tenant, key string, scopes []Scope) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hash := HashKey(key)
+	s.byHash[hash] = &APIKey{ID: name, Name: name, Tenant: tenant, Hash: hash, Scopes: scopes, static: true}
+}
+
+// Generate создаёт новый ключ с указанными правами и возвращает его открытое значение.
+// Открытое значение больше нигде не сохраняется.
+func (s *KeyStore) Generate(name, tenant string, scopes []Scope) (string, APIKey, error) {
+	for _, sc := range scopes {
+		if !ValidScope(sc) {
+			return "", APIKey{}, fmt.Errorf("%w %q", ErrUnknownScope, sc)
+		}
+	}
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", APIKey{}, err
+	}
+	key := "wm_" + hex.EncodeToString(buf)
+	k := &APIKey{
+		ID:        uuid.NewString(),
+		Name:      name,
+		Tenant:    tenant,
+		Hash:      HashKey(key),
+		Scopes:    scopes,
+		CreatedAt: time.Now(),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.byHash[k.Hash] = k
+	if err := s.saveLocked(); err != nil {
+		delete(s.byHash, k.Hash)
+		return "", APIKey{}, err
+	}
+	return key, *k, nil
+}
+
+// Revoke удаляет ключ по ID, возвращает true если ключ найден
+func (s *KeyStore) Revoke(id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for hash, k := range s.byHash {
+		if k.ID == id && !k.static {
+			delete(s.byHash, hash)
+			return true, s.saveLocked()
+		}
+	}
+	return false, nil
+}
+
+// List возвращает все ключи, отсортированные по времени создания
+func (s *KeyStore) List() []APIKey {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	list := make([]APIKey, 0, len(s.byHash))
+	for _, k := range s.byHash {
+		list = append(list, *k)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.Before(list[j].CreatedAt) })
+	return list
+}
+
+// saveLocked сохраняет ключи в файл, если он задан; вызывается под s.mu
+func (s *KeyStore) saveLocked() error {
+	if s.path == "" {
+		return nil
+	}
+	keys := make([]*APIKey, 0, len(s.byHash))
+	for _, k := range s.byHash {
+		if !k.static {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.Before(keys[j].CreatedAt) })
+	data, err := json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.path, data, 0o600)
+}
@@ -0,0 +1,55 @@
+package auth
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+// TestKeyStore_GenerateAuthenticateRevoke проверяет выпуск ключа, аутентификацию по нему,
+// сохранение в файл и отзыв.
+func TestKeyStore_GenerateAuthenticateRevoke(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	s, err := LoadKeyFile(path)
+	if err != nil {
+		t.Fatalf("LoadKeyFile вернул ошибку для отсутствующего файла: %v", err)
+	}
+
+	key, meta, err := s.Generate("ci", "team-a", []Scope{ScopeTasksRead})
+	if err != nil {
+		t.Fatalf("Generate вернул ошибку: %v", err)
+	}
+	if meta.Hash != HashKey(key) {
+		t.Errorf("в хранилище должен лежать хэш ключа")
+	}
+
+	// Ключ переживает перезагрузку из файла и даёт только выданные права
+	reloaded, err := LoadKeyFile(path)
+	if err != nil {
+		t.Fatalf("повторный LoadKeyFile вернул ошибку: %v", err)
+	}
+	p, ok := reloaded.Authenticate(context.Background(), key)
+	if !ok {
+		t.Fatalf("ключ не аутентифицирован после перезагрузки")
+	}
+	if p.Tenant != "team-a" {
+		t.Errorf("ожидался арендатор team-a, получили %q", p.Tenant)
+	}
+	if !p.HasScope(ScopeTasksRead) || p.HasScope(ScopeTasksWrite) {
+		t.Errorf("неверные права у субъекта: %v", p.Scopes)
+	}
+
+	if _, ok := s.Authenticate(context.Background(), "wm_wrong"); ok {
+		t.Errorf("неверный ключ не должен проходить аутентификацию")
+	}
+	if _, _, err := s.Generate("bad", "", []Scope{"tasks:everything"}); err == nil {
+		t.Errorf("ожидалась ошибка для неизвестного scope")
+	}
+
+	if ok, err := s.Revoke(meta.ID); !ok || err != nil {
+		t.Fatalf("Revoke вернул %v, %v", ok, err)
+	}
+	if _, ok := s.Authenticate(context.Background(), key); ok {
+		t.Errorf("отозванный ключ не должен проходить аутентификацию")
+	}
+}
@@ -0,0 +1,192 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// ErrUnknownKey возвращается, если в наборе ключей нет ключа с запрошенным kid
+var ErrUnknownKey = errors.New("неизвестный kid")
+
+// minRefetchInterval ограничивает частоту внеочередных обновлений набора ключей,
+// чтобы токены с произвольным kid не приводили к лавине запросов к IdP
+const minRefetchInterval = 30 * time.Second
+
+// jwk - открытый ключ в формате RFC 7517 (поддерживаются RSA и EC P-256)
+type jwk struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWKS - кэшируемый набор открытых ключей IdP.
+// Набор периодически перечитывается из источника, что позволяет подхватывать ротацию ключей.
+type JWKS struct {
+	mu        sync.RWMutex
+	fetch     func(ctx context.Context) ([]byte, error)
+	refresh   time.Duration
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+}
+
+// NewRemoteJWKS создаёт набор ключей, загружаемый по URL (например, jwks_uri из OIDC discovery)
+func NewRemoteJWKS(url string, refresh time.Duration, client *http.Client) *JWKS {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &JWKS{refresh: refresh, fetch: func(ctx context.Context) ([]byte, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("загрузка JWKS: статус %d", resp.StatusCode)
+		}
+		return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	}}
+}
+
+// NewFileJWKS создаёт набор ключей, читаемый из локального файла
+func NewFileJWKS(path string, refresh time.Duration) *JWKS {
+	return &JWKS{refresh: refresh, fetch: func(context.Context) ([]byte, error) {
+		return os.ReadFile(path)
+	}}
+}
+
+// Key возвращает открытый ключ по kid. Набор обновляется, если он устарел
+// или если kid в нём не найден (но не чаще minRefetchInterval).
+func (k *JWKS) Key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	k.mu.RLock()
+	key, ok := k.keys[kid]
+	age := time.Since(k.fetchedAt)
+	stale := k.fetchedAt.IsZero() || (k.refresh > 0 && age > k.refresh)
+	k.mu.RUnlock()
+
+	if ok && !stale {
+		return key, nil
+	}
+	if !stale && age < minRefetchInterval {
+		return nil, fmt.Errorf("%w %q", ErrUnknownKey, kid)
+	}
+	if err := k.Refresh(ctx); err != nil && !ok {
+		return nil, err
+	}
+
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	if key, ok := k.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("%w %q", ErrUnknownKey, kid)
+}
+
+// Refresh перечитывает набор ключей из источника. При ошибке прежний набор сохраняется.
+func (k *JWKS) Refresh(ctx context.Context) error {
+	data, err := k.fetch(ctx)
+	if err == nil {
+		var keys map[string]crypto.PublicKey
+		if keys, err = parseJWKS(data); err == nil {
+			k.mu.Lock()
+			k.keys = keys
+			k.fetchedAt = time.Now()
+			k.mu.Unlock()
+			return nil
+		}
+	}
+	// Не повторяем неудачную загрузку на каждом запросе
+	k.mu.Lock()
+	k.fetchedAt = time.Now()
+	k.mu.Unlock()
+	return err
+}
+
+// parseJWKS разбирает документ {"keys": [...]}, пропуская ключи неподдерживаемых типов
+func parseJWKS(data []byte) (map[string]crypto.PublicKey, error) {
+	var doc struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("разбор JWKS: %w", err)
+	}
+	keys := make(map[string]crypto.PublicKey, len(doc.Keys))
+	for _, j := range doc.Keys {
+		if j.Use != "" && j.Use != "sig" {
+			continue
+		}
+		key, err := j.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("ключ %q: %w", j.Kid, err)
+		}
+		if key != nil {
+			keys[j.Kid] = key
+		}
+	}
+	return keys, nil
+}
+
+// publicKey преобразует JWK в открытый ключ; для неподдерживаемых типов возвращает nil
+func (j jwk) publicKey() (crypto.PublicKey, error) {
+	switch j.Kty {
+	case "RSA":
+		n, err := decodeBigInt(j.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(j.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() < 3 {
+			return nil, errors.New("неверная экспонента RSA")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		if j.Crv != "P-256" {
+			return nil, nil
+		}
+		x, err := decodeBigInt(j.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(j.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !elliptic.P256().IsOnCurve(x, y) {
+			return nil, errors.New("точка не лежит на кривой P-256")
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+	}
+	return nil, nil
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
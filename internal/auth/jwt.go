@@ -0,0 +1,229 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// ErrInvalidToken возвращается при любой ошибке проверки JWT
+var ErrInvalidToken = errors.New("недействительный токен")
+
+// JWTConfig описывает требования к токенам и отображение утверждений (claims) на субъекта
+type JWTConfig struct {
+	// Issuer - ожидаемое значение iss; пустая строка отключает проверку
+	Issuer string
+	// Audience - значение, которое должно присутствовать в aud; пустая строка отключает проверку
+	Audience string
+	// TenantClaim - утверждение с арендатором (по умолчанию "tenant")
+	TenantClaim string
+	// RolesClaim - утверждение со списком ролей (по умолчанию "roles")
+	RolesClaim string
+	// RoleScopes сопоставляет роли с правами; роль, совпадающая с названием права, даёт это право всегда
+	RoleScopes map[string][]Scope
+	// Leeway - допустимое расхождение часов при проверке exp и nbf
+	Leeway time.Duration
+}
+
+// JWTValidator проверяет RS256/ES256 токены по набору ключей JWKS
+type JWTValidator struct {
+	keys *JWKS
+	cfg  JWTConfig
+	now  func() time.Time
+}
+
+// NewJWTValidator создаёт валидатор токенов
+func NewJWTValidator(keys *JWKS, cfg JWTConfig) *JWTValidator {
+	if cfg.TenantClaim == "" {
+		cfg.TenantClaim = "tenant"
+	}
+	if cfg.RolesClaim == "" {
+		cfg.RolesClaim = "roles"
+	}
+	return &JWTValidator{keys: keys, cfg: cfg, now: time.Now}
+}
+
+// ParseRoleScopes разбирает строку вида "admin=tasks:admin,operator=tasks:read tasks:write"
+func ParseRoleScopes(spec string) (map[string][]Scope, error) {
+	m := make(map[string][]Scope)
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		role, scopes, ok := strings.Cut(item, "=")
+		if !ok || role == "" {
+			return nil, fmt.Errorf("ожидался формат роль=права, получили %q", item)
+		}
+		for _, sc := range strings.Fields(scopes) {
+			if !ValidScope(Scope(sc)) {
+				return nil, fmt.Errorf("роль %q: %w %q", role, ErrUnknownScope, sc)
+			}
+			m[role] = append(m[role], Scope(sc))
+		}
+	}
+	return m, nil
+}
+
+// Validate проверяет подпись и утверждения токена и возвращает соответствующего субъекта
+func (v *JWTValidator) Validate(ctx context.Context, token string) (*Principal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: ожидалось три части", ErrInvalidToken)
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("%w: заголовок: %v", ErrInvalidToken, err)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: подпись: %v", ErrInvalidToken, err)
+	}
+	key, err := v.keys.Key(ctx, header.Kid)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+
+	// Алгоритм должен соответствовать типу ключа, иначе возможна подмена алгоритма
+	switch pub := key.(type) {
+	case *rsa.PublicKey:
+		if header.Alg != "RS256" {
+			return nil, fmt.Errorf("%w: алгоритм %q не подходит для RSA-ключа", ErrInvalidToken, header.Alg)
+		}
+		if err := rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], sig); err != nil {
+			return nil, fmt.Errorf("%w: подпись не прошла проверку", ErrInvalidToken)
+		}
+	case *ecdsa.PublicKey:
+		if header.Alg != "ES256" {
+			return nil, fmt.Errorf("%w: алгоритм %q не подходит для EC-ключа", ErrInvalidToken, header.Alg)
+		}
+		if len(sig) != 64 {
+			return nil, fmt.Errorf("%w: неверная длина подписи ES256", ErrInvalidToken)
+		}
+		r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])
+		if !ecdsa.Verify(pub, digest[:], r, s) {
+			return nil, fmt.Errorf("%w: подпись не прошла проверку", ErrInvalidToken)
+		}
+	default:
+		return nil, fmt.Errorf("%w: неподдерживаемый тип ключа", ErrInvalidToken)
+	}
+
+	var claims map[string]any
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("%w: утверждения: %v", ErrInvalidToken, err)
+	}
+	if err := v.checkClaims(claims); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	return v.principal(claims), nil
+}
+
+// Authenticate реализует Authenticator; причина отказа не раскрывается клиенту
+func (v *JWTValidator) Authenticate(ctx context.Context, token string) (*Principal, bool) {
+	p, err := v.Validate(ctx, token)
+	return p, err == nil
+}
+
+// checkClaims проверяет exp, nbf, iss и aud
+func (v *JWTValidator) checkClaims(claims map[string]any) error {
+	now := v.now()
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return errors.New("отсутствует exp")
+	}
+	if now.After(time.Unix(int64(exp), 0).Add(v.cfg.Leeway)) {
+		return errors.New("срок действия истёк")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(v.cfg.Leeway).Before(time.Unix(int64(nbf), 0)) {
+		return errors.New("токен ещё не действителен")
+	}
+	if v.cfg.Issuer != "" && claims["iss"] != v.cfg.Issuer {
+		return fmt.Errorf("неожиданный iss %v", claims["iss"])
+	}
+	if v.cfg.Audience != "" {
+		found := false
+		for _, aud := range stringList(claims["aud"]) {
+			if aud == v.cfg.Audience {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("aud не содержит %q", v.cfg.Audience)
+		}
+	}
+	if sub, _ := claims["sub"].(string); sub == "" {
+		return errors.New("отсутствует sub")
+	}
+	return nil
+}
+
+// principal строит субъекта из проверенных утверждений
+func (v *JWTValidator) principal(claims map[string]any) *Principal {
+	p := &Principal{Tenant: DefaultTenant}
+	p.Name, _ = claims["sub"].(string)
+	if tenant, _ := claims[v.cfg.TenantClaim].(string); tenant != "" {
+		p.Tenant = tenant
+	}
+	p.Roles = stringList(claims[v.cfg.RolesClaim])
+
+	seen := make(map[Scope]bool)
+	add := func(sc Scope) {
+		if ValidScope(sc) && !seen[sc] {
+			seen[sc] = true
+			p.Scopes = append(p.Scopes, sc)
+		}
+	}
+	for _, role := range p.Roles {
+		add(Scope(role))
+		for _, sc := range v.cfg.RoleScopes[role] {
+			add(sc)
+		}
+	}
+	// Стандартное OAuth2-утверждение scope - строка прав через пробел
+	if scope, ok := claims["scope"].(string); ok {
+		for _, sc := range strings.Fields(scope) {
+			add(Scope(sc))
+		}
+	}
+	return p
+}
+
+// stringList приводит утверждение-строку или массив строк к срезу
+func stringList(v any) []string {
+	switch t := v.(type) {
+	case string:
+		return strings.Fields(t)
+	case []any:
+		out := make([]string, 0, len(t))
+		for _, item := range t {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+func decodeSegment(seg string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
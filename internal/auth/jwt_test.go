@@ -0,0 +1,155 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testKeys - локально сгенерированный набор ключей для подписи тестовых токенов
+type testKeys struct {
+	rsa *rsa.PrivateKey
+	ec  *ecdsa.PrivateKey
+}
+
+func newTestKeys(t *testing.T) testKeys {
+	t.Helper()
+	rk, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("генерация RSA-ключа: %v", err)
+	}
+	ek, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("генерация EC-ключа: %v", err)
+	}
+	return testKeys{rsa: rk, ec: ek}
+}
+
+// jwks возвращает JSON-документ JWKS с открытыми частями ключей под указанными kid
+func (k testKeys) jwks(rsaKid, ecKid string) []byte {
+	b64 := func(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+	pad := func(i *big.Int) []byte { return i.FillBytes(make([]byte, 32)) }
+	doc := map[string]any{"keys": []map[string]string{
+		{"kid": rsaKid, "kty": "RSA", "use": "sig", "n": b64(k.rsa.N.Bytes()), "e": b64(big.NewInt(int64(k.rsa.E)).Bytes())},
+		{"kid": ecKid, "kty": "EC", "crv": "P-256", "x": b64(pad(k.ec.X)), "y": b64(pad(k.ec.Y))},
+	}}
+	data, _ := json.Marshal(doc)
+	return data
+}
+
+// sign выпускает токен с указанными алгоритмом, kid и утверждениями
+func (k testKeys) sign(t *testing.T, alg, kid string, claims map[string]any) string {
+	t.Helper()
+	enc := func(v any) string {
+		data, _ := json.Marshal(v)
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	signing := enc(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"}) + "." + enc(claims)
+	digest := sha256.Sum256([]byte(signing))
+	var sig []byte
+	switch alg {
+	case "RS256":
+		var err error
+		if sig, err = rsa.SignPKCS1v15(rand.Reader, k.rsa, crypto.SHA256, digest[:]); err != nil {
+			t.Fatalf("подпись RS256: %v", err)
+		}
+	case "ES256":
+		r, s, err := ecdsa.Sign(rand.Reader, k.ec, digest[:])
+		if err != nil {
+			t.Fatalf("подпись ES256: %v", err)
+		}
+		sig = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	}
+	return signing + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+// TestJWTValidator_Validate проверяет RS256 и ES256 токены, отображение утверждений и типичные отказы.
+func TestJWTValidator_Validate(t *testing.T) {
+	keys := newTestKeys(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(keys.jwks("rsa-1", "ec-1"))
+	}))
+	defer srv.Close()
+
+	v := NewJWTValidator(NewRemoteJWKS(srv.URL, time.Hour, nil), JWTConfig{
+		Issuer:     "https://idp.example",
+		Audience:   "tasks-api",
+		RoleScopes: map[string][]Scope{"operator": {ScopeTasksRead, ScopeTasksWrite}},
+	})
+	base := func() map[string]any {
+		return map[string]any{
+			"iss": "https://idp.example", "aud": []string{"tasks-api"}, "sub": "alice",
+			"exp": time.Now().Add(time.Hour).Unix(), "tenant": "team-a", "roles": []string{"operator"},
+		}
+	}
+	ctx := context.Background()
+
+	p, err := v.Validate(ctx, keys.sign(t, "RS256", "rsa-1", base()))
+	if err != nil {
+		t.Fatalf("валидный RS256 токен отклонён: %v", err)
+	}
+	if p.Name != "alice" || p.Tenant != "team-a" || !p.HasScope(ScopeTasksWrite) || p.HasScope(ScopeTasksAdmin) {
+		t.Errorf("неверный субъект: %+v", p)
+	}
+	if _, err := v.Validate(ctx, keys.sign(t, "ES256", "ec-1", base())); err != nil {
+		t.Errorf("валидный ES256 токен отклонён: %v", err)
+	}
+
+	expired := base()
+	expired["exp"] = time.Now().Add(-time.Hour).Unix()
+	wrongAud := base()
+	wrongAud["aud"] = "other-api"
+	bad := map[string]string{
+		"истёкший токен":       keys.sign(t, "RS256", "rsa-1", expired),
+		"чужая аудитория":      keys.sign(t, "RS256", "rsa-1", wrongAud),
+		"подмена алгоритма":    keys.sign(t, "ES256", "rsa-1", base()),
+		"неизвестный kid":      keys.sign(t, "RS256", "rsa-9", base()),
+		"искажённый заголовок": keys.sign(t, "RS256", "rsa-1", base())[:10] + "x" + keys.sign(t, "RS256", "rsa-1", base())[11:],
+	}
+	for name, token := range bad {
+		if _, err := v.Validate(ctx, token); err == nil {
+			t.Errorf("%s: ожидался отказ", name)
+		}
+	}
+}
+
+// TestJWKS_Rotation проверяет, что после ротации ключей у IdP новый kid подхватывается при обновлении набора.
+func TestJWKS_Rotation(t *testing.T) {
+	oldKeys, newKeys := newTestKeys(t), newTestKeys(t)
+	var rotated atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rotated.Load() {
+			w.Write(newKeys.jwks("rsa-2", "ec-2"))
+			return
+		}
+		w.Write(oldKeys.jwks("rsa-1", "ec-1"))
+	}))
+	defer srv.Close()
+
+	v := NewJWTValidator(NewRemoteJWKS(srv.URL, time.Nanosecond, nil), JWTConfig{})
+	claims := map[string]any{"sub": "bob", "exp": time.Now().Add(time.Hour).Unix()}
+	ctx := context.Background()
+
+	if _, err := v.Validate(ctx, oldKeys.sign(t, "RS256", "rsa-1", claims)); err != nil {
+		t.Fatalf("токен до ротации отклонён: %v", err)
+	}
+	rotated.Store(true)
+	if _, err := v.Validate(ctx, newKeys.sign(t, "RS256", "rsa-2", claims)); err != nil {
+		t.Errorf("токен после ротации отклонён: %v", err)
+	}
+	if _, err := v.Validate(ctx, oldKeys.sign(t, "RS256", "rsa-1", claims)); err == nil {
+		t.Errorf("токен, подписанный выведенным из оборота ключом, принят")
+	}
+}
@@ -0,0 +1,506 @@
+// Package bench - генератор нагрузки на HTTP API задач: выполняет смесь операций create/get/list/cancel
+// с заданной частотой и считает задержки по операциям, пропускную способность API и скорость,
+// с которой обработчик разбирает созданные задачи.
+package bench
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Операции нагрузки
+const (
+	OpCreate = "create"
+	OpGet    = "get"
+	OpList   = "list"
+	OpCancel = "cancel"
+)
+
+// ops - операции в порядке вывода
+var ops = []string{OpCreate, OpGet, OpList, OpCancel}
+
+// maxIDs - сколько последних созданных задач помнить для get и cancel
+const maxIDs = 10000
+
+// maxRate - наибольшая поддерживаемая частота операций в секунду
+const maxRate = 1e6
+
+// Mix - веса операций в нагрузке
+type Mix map[string]int
+
+// DefaultMix - смесь по умолчанию: в основном создание и чтение задач
+var DefaultMix = Mix{OpCreate: 50, OpGet: 30, OpList: 10, OpCancel: 10}
+
+// ParseMix разбирает строку вида "create=50,get=30,list=10,cancel=10"
+func ParseMix(spec string) (Mix, error) {
+	m := make(Mix)
+	total := 0
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		op, w, ok := strings.Cut(item, "=")
+		n, err := strconv.Atoi(w)
+		if !ok || err != nil || n < 0 {
+			return nil, fmt.Errorf("ожидался формат операция=вес, получили %q", item)
+		}
+		if !knownOp(op) {
+			return nil, fmt.Errorf("неизвестная операция %q, ожидалось create, get, list или cancel", op)
+		}
+		m[op] = n
+		total += n
+	}
+	if total == 0 {
+		return nil, errors.New("сумма весов операций должна быть положительной")
+	}
+	return m, nil
+}
+
+func knownOp(op string) bool {
+	for _, o := range ops {
+		if o == op {
+			return true
+		}
+	}
+	return false
+}
+
+// String возвращает смесь в формате ParseMix
+func (m Mix) String() string {
+	var parts []string
+	for _, op := range ops {
+		if m[op] > 0 {
+			parts = append(parts, fmt.Sprintf("%s=%d", op, m[op]))
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// Config - параметры испытания
+type Config struct {
+	// URL - базовый адрес API, например http://localhost:8080
+	URL string
+	// Token - API-ключ или JWT; пустая строка - без аутентификации
+	Token string
+	// Rate - целевая частота операций в секунду
+	Rate float64
+	// Duration - длительность нагрузки
+	Duration time.Duration
+	// Workers - сколько запросов может выполняться одновременно (по умолчанию 64)
+	Workers int
+	// Mix - смесь операций (по умолчанию DefaultMix)
+	Mix Mix
+	// TaskType и Payload - тип и payload создаваемых задач
+	TaskType string
+	Payload  json.RawMessage
+	// Drain - сколько после нагрузки ждать, пока очередь обработчика опустеет; 0 - не ждать
+	Drain time.Duration
+	// Seed - начальное значение выбора операций; с одинаковым Seed последовательность операций повторяется
+	Seed int64
+	// Client - HTTP-клиент (по умолчанию клиент с пулом соединений на Workers)
+	Client *http.Client
+}
+
+// Report - результат испытания в виде, пригодном для сравнения запусков
+type Report struct {
+	StartedAt time.Time `json:"started_at"`
+	// Target - адрес сервера; для сервера в процессе - "in-process"
+	Target string `json:"target"`
+	// Store - хранилище задач сервера в процессе
+	Store    string  `json:"store,omitempty"`
+	Rate     float64 `json:"rate"`
+	Duration string  `json:"duration"`
+	Workers  int     `json:"workers"`
+	Mix      string  `json:"mix"`
+	Requests int     `json:"requests"`
+	Errors   int     `json:"errors"`
+	// Delayed - операции, которые не удалось начать вовремя, потому что все Workers были заняты:
+	// они выполнены позже, а ожидание вошло в их задержку
+	Delayed int `json:"delayed"`
+	// Throughput - выполненных запросов в секунду
+	Throughput float64             `json:"throughput"`
+	Ops        map[string]*OpStats `json:"ops"`
+	Scheduler  *SchedulerStats     `json:"scheduler,omitempty"`
+}
+
+// OpStats - статистика одной операции
+type OpStats struct {
+	Count      int     `json:"count"`
+	Errors     int     `json:"errors"`
+	Throughput float64 `json:"throughput"`
+	// Latency - задержки в миллисекундах от запланированного начала операции до получения ответа,
+	// поэтому ожидание свободного Worker тоже учитывается (операции не отбрасываются, а ждут в очереди)
+	Latency Latency `json:"latency_ms"`
+	// Statuses - число ответов по кодам; "error" - ошибки соединения
+	Statuses map[string]int `json:"statuses"`
+
+	latencies []time.Duration
+}
+
+// Latency - распределение задержек в миллисекундах
+type Latency struct {
+	Min  float64 `json:"min"`
+	Mean float64 `json:"mean"`
+	P50  float64 `json:"p50"`
+	P90  float64 `json:"p90"`
+	P99  float64 `json:"p99"`
+	P999 float64 `json:"p999"`
+	Max  float64 `json:"max"`
+}
+
+// SchedulerStats - как быстро обработчик разобрал созданные задачи. Очередь на сервере общая,
+// поэтому для удалённого сервера значения верны, только если его не нагружает кто-то ещё.
+type SchedulerStats struct {
+	Created int `json:"created"`
+	// Drained - очередь и выполняющиеся задачи закончились до истечения Drain
+	Drained   bool   `json:"drained"`
+	DrainTime string `json:"drain_time"`
+	// Throughput - созданных задач в секунду от начала нагрузки до опустошения очереди
+	Throughput float64 `json:"throughput,omitempty"`
+	// Queued и Running - задачи, оставшиеся к концу ожидания
+	Queued  int `json:"queued"`
+	Running int `json:"running"`
+}
+
+// job - запланированная операция
+type job struct {
+	op       string
+	intended time.Time
+}
+
+// runner - состояние испытания
+type runner struct {
+	cfg Config
+
+	mu    sync.Mutex
+	stats map[string]*OpStats
+	ids   []string
+	rng   *rand.Rand
+}
+
+// Run выполняет испытание: операции планируются через равные промежутки с частотой Rate,
+// независимо от того, как быстро отвечает сервер. Операции, для которых нет свободного Worker,
+// ждут в очереди, поэтому после Duration Run дожидается их выполнения. При отмене ctx
+// возвращается отчёт по выполненным операциям вместе с ошибкой ctx.
+func Run(ctx context.Context, cfg Config) (*Report, error) {
+	if cfg.URL == "" {
+		return nil, errors.New("не задан адрес сервера")
+	}
+	if cfg.Rate <= 0 || cfg.Duration <= 0 {
+		return nil, errors.New("частота и длительность должны быть положительными")
+	}
+	if cfg.Rate > maxRate {
+		return nil, fmt.Errorf("частота не должна превышать %.0f операций в секунду", float64(maxRate))
+	}
+	if cfg.Workers <= 0 {
+		cfg.Workers = 64
+	}
+	if cfg.Mix == nil {
+		cfg.Mix = DefaultMix
+	}
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{
+			Timeout:   30 * time.Second,
+			Transport: &http.Transport{MaxIdleConnsPerHost: cfg.Workers},
+		}
+	}
+	cfg.URL = strings.TrimRight(cfg.URL, "/")
+	r := &runner{cfg: cfg, stats: make(map[string]*OpStats), rng: rand.New(rand.NewSource(cfg.Seed))}
+	for _, op := range ops {
+		r.stats[op] = &OpStats{Statuses: make(map[string]int)}
+	}
+
+	rep := &Report{
+		StartedAt: time.Now(),
+		Target:    cfg.URL,
+		Rate:      cfg.Rate,
+		Duration:  cfg.Duration.String(),
+		Workers:   cfg.Workers,
+		Mix:       cfg.Mix.String(),
+	}
+	jobs := make(chan job, cfg.Workers)
+	var wg sync.WaitGroup
+	for i := 0; i < cfg.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				r.do(ctx, j)
+			}
+		}()
+	}
+
+	start := time.Now()
+	// Момент начала i-й операции считается от start, чтобы ошибки округления не накапливались
+	at := func(i int) time.Time {
+		return start.Add(time.Duration(float64(i) * float64(time.Second) / cfg.Rate))
+	}
+	// pending - запланированные операции, которые ждут свободного Worker
+	var pending []job
+	next, scheduling := 0, true
+	timer := time.NewTimer(0)
+	<-timer.C
+load:
+	for {
+		for now := time.Now(); scheduling && !at(next).After(now); {
+			j := job{op: r.pick(), intended: at(next)}
+			next++
+			scheduling = at(next).Sub(start) < cfg.Duration
+			if len(pending) == 0 {
+				select {
+				case jobs <- j:
+					continue
+				default:
+				}
+			}
+			rep.Delayed++
+			pending = append(pending, j)
+		}
+		if !scheduling && len(pending) == 0 {
+			break
+		}
+		var (
+			send chan<- job
+			head job
+			tick <-chan time.Time
+		)
+		if len(pending) > 0 {
+			send, head = jobs, pending[0]
+		}
+		if scheduling {
+			timer.Reset(time.Until(at(next)))
+			tick = timer.C
+		}
+		select {
+		case send <- head:
+			pending = pending[1:]
+		case <-tick:
+		case <-ctx.Done():
+			break load
+		}
+	}
+	timer.Stop()
+	close(jobs)
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	for _, op := range ops {
+		s := r.stats[op]
+		s.Throughput = float64(s.Count) / elapsed.Seconds()
+		s.Latency = latency(s.latencies)
+		rep.Requests += s.Count
+		rep.Errors += s.Errors
+	}
+	rep.Ops = r.stats
+	rep.Throughput = float64(rep.Requests) / elapsed.Seconds()
+
+	if cfg.Drain > 0 {
+		rep.Scheduler = r.drain(ctx, start)
+	}
+	return rep, ctx.Err()
+}
+
+// pick выбирает операцию по весам смеси
+func (r *runner) pick() string {
+	total := 0
+	for _, op := range ops {
+		total += r.cfg.Mix[op]
+	}
+	r.mu.Lock()
+	n := r.rng.Intn(total)
+	r.mu.Unlock()
+	for _, op := range ops {
+		if n < r.cfg.Mix[op] {
+			return op
+		}
+		n -= r.cfg.Mix[op]
+	}
+	return OpCreate
+}
+
+// target выбирает задачу для get (случайную из недавних) и cancel (последнюю созданную,
+// чтобы отменять ещё не завершённые задачи). Пока задач нет, операция заменяется на create.
+func (r *runner) target(op string) (string, string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.ids) == 0 {
+		return OpCreate, ""
+	}
+	switch op {
+	case OpGet:
+		return op, r.ids[r.rng.Intn(len(r.ids))]
+	case OpCancel:
+		id := r.ids[len(r.ids)-1]
+		r.ids = r.ids[:len(r.ids)-1]
+		return op, id
+	}
+	return op, ""
+}
+
+// remember запоминает созданную задачу
+func (r *runner) remember(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.ids) >= maxIDs {
+		r.ids = append(r.ids[:0], r.ids[len(r.ids)-maxIDs/2:]...)
+	}
+	r.ids = append(r.ids, id)
+}
+
+// do выполняет операцию и записывает её результат
+func (r *runner) do(ctx context.Context, j job) {
+	op, id := j.op, ""
+	if op == OpGet || op == OpCancel {
+		op, id = r.target(op)
+	}
+	var (
+		method = http.MethodGet
+		path   = "/tasks"
+		body   []byte
+		ok     = []int{http.StatusOK}
+	)
+	switch op {
+	case OpCreate:
+		method, ok = http.MethodPost, []int{http.StatusCreated}
+		body, _ = json.Marshal(struct {
+			Type    string          `json:"type,omitempty"`
+			Payload json.RawMessage `json:"payload,omitempty"`
+		}{r.cfg.TaskType, r.cfg.Payload})
+	case OpGet:
+		path += "/" + id
+	case OpCancel:
+		// Задача могла завершиться раньше отмены - это не ошибка сервера
+		method, path, ok = http.MethodPost, path+"/"+id+"/cancel", []int{http.StatusOK, http.StatusConflict}
+	}
+
+	status, data, err := r.request(ctx, method, path, body)
+	elapsed := time.Since(j.intended)
+	if err == nil && op == OpCreate && status == http.StatusCreated {
+		var created struct {
+			ID string `json:"id"`
+		}
+		if json.Unmarshal(data, &created) == nil && created.ID != "" {
+			r.remember(created.ID)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := r.stats[op]
+	s.Count++
+	s.latencies = append(s.latencies, elapsed)
+	if err != nil {
+		s.Errors++
+		s.Statuses["error"]++
+		return
+	}
+	s.Statuses[strconv.Itoa(status)]++
+	for _, code := range ok {
+		if status == code {
+			return
+		}
+	}
+	s.Errors++
+}
+
+// request выполняет запрос к API; тело ответа читается целиком, чтобы соединение переиспользовалось
+func (r *runner) request(ctx context.Context, method, path string, body []byte) (int, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, r.cfg.URL+path, bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if r.cfg.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+r.cfg.Token)
+	}
+	resp, err := r.cfg.Client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	return resp.StatusCode, data, err
+}
+
+// drain ждёт, пока обработчик не разберёт очередь, опрашивая GET /queue
+func (r *runner) drain(ctx context.Context, start time.Time) *SchedulerStats {
+	st := &SchedulerStats{Created: r.stats[OpCreate].Count - r.stats[OpCreate].Errors}
+	deadline := time.Now().Add(r.cfg.Drain)
+	for {
+		var queue struct {
+			Queued  int `json:"queued"`
+			Running int `json:"running"`
+		}
+		status, data, err := r.request(ctx, http.MethodGet, "/queue", nil)
+		if err == nil && status == http.StatusOK && json.Unmarshal(data, &queue) == nil {
+			st.Queued, st.Running = queue.Queued, queue.Running
+			if queue.Queued == 0 && queue.Running == 0 {
+				st.Drained = true
+				break
+			}
+		}
+		if time.Now().After(deadline) || ctx.Err() != nil {
+			break
+		}
+		select {
+		case <-time.After(100 * time.Millisecond):
+		case <-ctx.Done():
+		}
+	}
+	total := time.Since(start)
+	st.DrainTime = total.Round(time.Millisecond).String()
+	if st.Drained {
+		st.Throughput = float64(st.Created) / total.Seconds()
+	}
+	return st
+}
+
+// latency вычисляет распределение задержек
+func latency(d []time.Duration) Latency {
+	if len(d) == 0 {
+		return Latency{}
+	}
+	sort.Slice(d, func(i, j int) bool { return d[i] < d[j] })
+	var sum time.Duration
+	for _, v := range d {
+		sum += v
+	}
+	return Latency{
+		Min:  ms(d[0]),
+		Mean: ms(sum / time.Duration(len(d))),
+		P50:  ms(percentile(d, 0.5)),
+		P90:  ms(percentile(d, 0.9)),
+		P99:  ms(percentile(d, 0.99)),
+		P999: ms(percentile(d, 0.999)),
+		Max:  ms(d[len(d)-1]),
+	}
+}
+
+// percentile возвращает q-квантиль отсортированных значений (ближайший ранг)
+func percentile(sorted []time.Duration, q float64) time.Duration {
+	i := int(math.Ceil(q*float64(len(sorted)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	return sorted[i]
+}
+
+// ms переводит длительность в миллисекунды с точностью до микросекунды
+func ms(d time.Duration) float64 {
+	return math.Round(float64(d)/float64(time.Microsecond)) / 1000
+}
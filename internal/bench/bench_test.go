@@ -0,0 +1,144 @@
+package bench
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TestParseMix проверяет разбор смеси операций и её запись
+func TestParseMix(t *testing.T) {
+	m, err := ParseMix("get=3, create=1,cancel=0")
+	if err != nil {
+		t.Fatalf("ParseMix: %v", err)
+	}
+	if got := m.String(); got != "create=1,get=3" {
+		t.Errorf("неверная смесь: %s", got)
+	}
+	for _, bad := range []string{"", "create=0", "delete=1", "create=-1", "create"} {
+		if _, err := ParseMix(bad); err == nil {
+			t.Errorf("смесь %q должна быть отклонена", bad)
+		}
+	}
+}
+
+// TestRun проверяет нагрузку на заглушку API: частоту, выбор целей для get и cancel, подсчёт ошибок,
+// ожидание очереди обработчика и сравнение отчётов
+func TestRun(t *testing.T) {
+	var created, queued atomic.Int64
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /tasks", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		created.Add(1)
+		queued.Add(1)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":"` + uuid.NewString() + `"}`))
+	})
+	mux.HandleFunc("GET /tasks", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(`[]`)) })
+	mux.HandleFunc("GET /tasks/{id}", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(`{}`)) })
+	mux.HandleFunc("POST /tasks/{id}/cancel", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusConflict)
+	})
+	mux.HandleFunc("GET /queue", func(w http.ResponseWriter, r *http.Request) {
+		// Обработчик разбирает очередь между опросами
+		n := queued.Swap(0)
+		if n > 0 {
+			w.Write([]byte(`{"queued":1,"running":0}`))
+			return
+		}
+		w.Write([]byte(`{"queued":0,"running":0}`))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	rep, err := Run(context.Background(), Config{
+		URL: srv.URL + "/", Token: "secret", Rate: 400, Duration: 250 * time.Millisecond, Workers: 8,
+		Mix: Mix{OpCreate: 2, OpGet: 1, OpList: 1, OpCancel: 1}, Drain: 5 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if rep.Requests != 100 {
+		t.Errorf("ожидалось 100 выполненных операций, получили %d", rep.Requests)
+	}
+	if rep.Errors != 0 {
+		t.Errorf("неожиданные ошибки: %+v", rep.Ops)
+	}
+	for _, op := range ops {
+		s := rep.Ops[op]
+		if s.Count == 0 || s.Latency.P50 <= 0 || s.Latency.P50 > s.Latency.P99 || s.Latency.P99 > s.Latency.Max {
+			t.Errorf("%s: неверная статистика %+v", op, s)
+		}
+	}
+	if rep.Ops[OpCancel].Statuses["409"] != rep.Ops[OpCancel].Count {
+		t.Errorf("отмена должна была получить 409: %+v", rep.Ops[OpCancel].Statuses)
+	}
+	if st := rep.Scheduler; st == nil || !st.Drained || st.Created != int(created.Load()) || st.Throughput <= 0 {
+		t.Errorf("неверная статистика обработчика: %+v", st)
+	}
+
+	// Без ключа все создания завершаются ошибкой, а get и cancel заменяются на create
+	bad, err := Run(context.Background(), Config{URL: srv.URL, Rate: 200, Duration: 50 * time.Millisecond, Mix: Mix{OpGet: 1}})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if s := bad.Ops[OpCreate]; s.Count == 0 || s.Errors != s.Count || s.Statuses["401"] != s.Count || bad.Ops[OpGet].Count != 0 {
+		t.Errorf("неверный подсчёт ошибок: %+v", s)
+	}
+
+	path := filepath.Join(t.TempDir(), "bench.json")
+	if err := WriteFile(path, []*Report{rep}); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	base, err := ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	var out bytes.Buffer
+	Compare(&out, base, []*Report{rep})
+	if !strings.Contains(out.String(), "(+0.0%)") || !strings.Contains(out.String(), "cancel") {
+		t.Errorf("неверное сравнение:\n%s", out.String())
+	}
+	out.Reset()
+	WriteText(&out, rep)
+	if !strings.Contains(out.String(), "разобрано") {
+		t.Errorf("в отчёте нет статистики обработчика:\n%s", out.String())
+	}
+}
+
+// TestRun_QueuedOperations проверяет, что операции, для которых нет свободного Worker, не отбрасываются,
+// а ждут в очереди и ожидание входит в их задержку; слишком большая частота отклоняется
+func TestRun_QueuedOperations(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(10 * time.Millisecond)
+		w.Write([]byte(`[]`))
+	}))
+	defer srv.Close()
+
+	// 20 операций по 10 мс на одном Worker за 50 мс: последние ждут около 150 мс
+	rep, err := Run(context.Background(), Config{URL: srv.URL, Rate: 400, Duration: 50 * time.Millisecond, Workers: 1, Mix: Mix{OpList: 1}})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if rep.Requests != 20 || rep.Delayed == 0 {
+		t.Errorf("ожидалось 20 операций, часть с опозданием, получили %d и %d", rep.Requests, rep.Delayed)
+	}
+	if max := rep.Ops[OpList].Latency.Max; max < 100 {
+		t.Errorf("задержка должна включать ожидание в очереди, max %.1f мс", max)
+	}
+
+	if _, err := Run(context.Background(), Config{URL: srv.URL, Rate: 2e9, Duration: time.Second}); err == nil {
+		t.Error("частота выше maxRate должна быть отклонена")
+	}
+}
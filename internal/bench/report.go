@@ -0,0 +1,114 @@
+package bench
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+)
+
+// WriteText выводит отчёт в виде таблицы
+func WriteText(w io.Writer, rep *Report) {
+	target := rep.Target
+	if rep.Store != "" {
+		target += " (" + rep.Store + ")"
+	}
+	fmt.Fprintf(w, "%s: %.0f оп/с в течение %s, смесь %s\n", target, rep.Rate, rep.Duration, rep.Mix)
+	fmt.Fprintf(w, "Запросов: %d, ошибок: %d, начато с опозданием: %d, пропускная способность: %.1f запр/с\n",
+		rep.Requests, rep.Errors, rep.Delayed, rep.Throughput)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "операция\tзапросов\tошибок\tзапр/с\tp50, мс\tp90, мс\tp99, мс\tmax, мс\t")
+	for _, op := range ops {
+		s, ok := rep.Ops[op]
+		if !ok || s.Count == 0 {
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.1f\t%.2f\t%.2f\t%.2f\t%.2f\t\n",
+			op, s.Count, s.Errors, s.Throughput, s.Latency.P50, s.Latency.P90, s.Latency.P99, s.Latency.Max)
+	}
+	tw.Flush()
+	if st := rep.Scheduler; st != nil {
+		if st.Drained {
+			fmt.Fprintf(w, "Обработчик: %d задач разобрано за %s, %.1f задач/с\n", st.Created, st.DrainTime, st.Throughput)
+		} else {
+			fmt.Fprintf(w, "Обработчик: очередь не опустела за %s, в очереди %d, выполняется %d\n", st.DrainTime, st.Queued, st.Running)
+		}
+	}
+}
+
+// WriteFile сохраняет отчёты в JSON
+func WriteFile(path string, reports []*Report) error {
+	data, err := json.MarshalIndent(reports, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// ReadFile читает отчёты, сохранённые WriteFile
+func ReadFile(path string) ([]*Report, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var reports []*Report
+	if err := json.Unmarshal(data, &reports); err != nil {
+		return nil, fmt.Errorf("разбор %s: %w", path, err)
+	}
+	return reports, nil
+}
+
+// Compare выводит изменение пропускной способности и задержек cur относительно base.
+// Отчёты сопоставляются по хранилищу, а без него - по порядку.
+func Compare(w io.Writer, base, cur []*Report) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "хранилище\tоперация\tзапр/с\tp50\tp99\t")
+	for i, c := range cur {
+		b := matchReport(base, c, i)
+		if b == nil {
+			continue
+		}
+		name := c.Store
+		if name == "" {
+			name = c.Target
+		}
+		fmt.Fprintf(tw, "%s\tвсего\t%s\t\t\t\n", name, delta(b.Throughput, c.Throughput))
+		for _, op := range ops {
+			bs, cs := b.Ops[op], c.Ops[op]
+			if bs == nil || cs == nil || bs.Count == 0 || cs.Count == 0 {
+				continue
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t\n", name, op,
+				delta(bs.Throughput, cs.Throughput), delta(bs.Latency.P50, cs.Latency.P50), delta(bs.Latency.P99, cs.Latency.P99))
+		}
+		if b.Scheduler != nil && c.Scheduler != nil && b.Scheduler.Drained && c.Scheduler.Drained {
+			fmt.Fprintf(tw, "%s\tобработчик\t%s\t\t\t\n", name, delta(b.Scheduler.Throughput, c.Scheduler.Throughput))
+		}
+	}
+	tw.Flush()
+}
+
+// matchReport находит в base отчёт, соответствующий i-му отчёту c
+func matchReport(base []*Report, c *Report, i int) *Report {
+	if c.Store != "" {
+		for _, b := range base {
+			if b.Store == c.Store {
+				return b
+			}
+		}
+		return nil
+	}
+	if i < len(base) {
+		return base[i]
+	}
+	return nil
+}
+
+// delta форматирует значение cur и его изменение относительно base в процентах
+func delta(base, cur float64) string {
+	if base == 0 {
+		return fmt.Sprintf("%.2f", cur)
+	}
+	return fmt.Sprintf("%.2f (%+.1f%%)", cur, (cur-base)/base*100)
+}
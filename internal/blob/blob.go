@@ -0,0 +1,153 @@
+package blob
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrNotFound возвращается, если объекта с таким ключом нет в хранилище
+var ErrNotFound = errors.New("объект не найден")
+
+// ErrInvalidKey возвращается для ключей, выходящих за пределы хранилища
+var ErrInvalidKey = errors.New("недопустимый ключ объекта")
+
+// Info описывает сохранённый объект
+type Info struct {
+	Size int64
+	// SHA256 - контрольная сумма содержимого в шестнадцатеричном виде
+	SHA256 string
+}
+
+// Object - открытый для чтения объект; поддерживает произвольный доступ для выдачи диапазонов
+type Object interface {
+	io.ReadSeekCloser
+	Size() int64
+}
+
+// Store - хранилище двоичных объектов (артефактов задач).
+// Ключ - путь из сегментов, разделённых '/'.
+type Store interface {
+	// Put сохраняет содержимое r под ключом key, заменяя прежний объект
+	Put(ctx context.Context, key string, r io.Reader) (Info, error)
+	// Open открывает объект для чтения; возвращает ErrNotFound, если объекта нет
+	Open(ctx context.Context, key string) (Object, error)
+	// Delete удаляет объект; отсутствие объекта не считается ошибкой
+	Delete(ctx context.Context, key string) error
+}
+
+// validKey проверяет, что ключ не пуст и не содержит пустых сегментов, "." и ".."
+func validKey(key string) error {
+	if key == "" {
+		return ErrInvalidKey
+	}
+	for _, seg := range strings.Split(key, "/") {
+		if seg == "" || seg == "." || seg == ".." || strings.ContainsRune(seg, '\\') {
+			return fmt.Errorf("%w %q", ErrInvalidKey, key)
+		}
+	}
+	return nil
+}
+
+// FSStore хранит объекты в файлах локального каталога
+type FSStore struct {
+	root string
+}
+
+// NewFSStore создаёт хранилище в каталоге root, создавая его при необходимости
+func NewFSStore(root string) (*FSStore, error) {
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, err
+	}
+	return &FSStore{root: root}, nil
+}
+
+func (s *FSStore) path(key string) (string, error) {
+	if err := validKey(key); err != nil {
+		return "", err
+	}
+	return filepath.Join(s.root, filepath.FromSlash(key)), nil
+}
+
+// Put записывает объект атомарно: во временный файл, который затем переименовывается
+func (s *FSStore) Put(ctx context.Context, key string, r io.Reader) (Info, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return Info{}, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return Info{}, err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return Info{}, err
+	}
+	info, err := copyHashed(tmp, r)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return Info{}, err
+	}
+	return info, nil
+}
+
+// Open открывает файл объекта
+func (s *FSStore) Open(ctx context.Context, key string) (Object, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	st, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &fileObject{File: f, size: st.Size()}, nil
+}
+
+// Delete удаляет файл объекта
+func (s *FSStore) Delete(ctx context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+type fileObject struct {
+	*os.File
+	size int64
+}
+
+func (o *fileObject) Size() int64 { return o.size }
+
+// copyHashed копирует r в w, одновременно подсчитывая размер и SHA-256
+func copyHashed(w io.Writer, r io.Reader) (Info, error) {
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(w, h), r)
+	if err != nil {
+		return Info{}, err
+	}
+	return Info{Size: n, SHA256: hex.EncodeToString(h.Sum(nil))}, nil
+}
@@ -0,0 +1,144 @@
+// Package clock - источник времени, который можно подменить в тестах
+package clock
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock - часы и таймеры
+type Clock interface {
+	Now() time.Time
+	// After возвращает канал, в который придёт время по истечении d
+	After(d time.Duration) <-chan time.Time
+	// AfterFunc вызывает f по истечении d
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer - отложенный вызов, созданный AfterFunc
+type Timer interface {
+	// Stop отменяет вызов; возвращает false, если вызов уже произошёл или отменён
+	Stop() bool
+}
+
+// Real возвращает системные часы
+func Real() Clock {
+	return realClock{}
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+// Fake - управляемые часы для тестов: время идёт только при вызове Advance.
+// Функции AfterFunc вызываются синхронно внутри Advance в порядке срабатывания.
+type Fake struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+	// changed закрывается и заменяется при добавлении таймера, чтобы BlockUntil не опрашивал часы
+	changed chan struct{}
+}
+
+// fakeTimer - таймер поддельных часов
+type fakeTimer struct {
+	clock *Fake
+	when  time.Time
+	f     func()
+}
+
+// NewFake создаёт поддельные часы, показывающие now
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now, changed: make(chan struct{})}
+}
+
+// Now возвращает текущее время поддельных часов
+func (c *Fake) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// After возвращает канал, в который придёт время, когда часы будут переведены на d вперёд
+func (c *Fake) After(d time.Duration) <-chan time.Time {
+	ch := make(chan time.Time, 1)
+	c.AfterFunc(d, func() { ch <- c.Now() })
+	return ch
+}
+
+// AfterFunc регистрирует вызов f, когда часы будут переведены на d вперёд;
+// при d <= 0 f вызывается при ближайшем Advance
+func (c *Fake) AfterFunc(d time.Duration, f func()) Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTimer{clock: c, when: c.now.Add(d), f: f}
+	c.timers = append(c.timers, t)
+	close(c.changed)
+	c.changed = make(chan struct{})
+	return t
+}
+
+// Stop отменяет таймер
+func (t *fakeTimer) Stop() bool {
+	c := t.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, other := range c.timers {
+		if other == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Advance переводит часы на d вперёд и вызывает сработавшие таймеры, в том числе
+// созданные самими вызовами, если их срок тоже наступил
+func (c *Fake) Advance(d time.Duration) {
+	c.mu.Lock()
+	target := c.now.Add(d)
+	c.mu.Unlock()
+	for {
+		c.mu.Lock()
+		sort.SliceStable(c.timers, func(i, j int) bool { return c.timers[i].when.Before(c.timers[j].when) })
+		if len(c.timers) == 0 || c.timers[0].when.After(target) {
+			c.now = target
+			c.mu.Unlock()
+			return
+		}
+		t := c.timers[0]
+		c.timers = c.timers[1:]
+		if t.when.After(c.now) {
+			c.now = t.when
+		}
+		c.mu.Unlock()
+		t.f()
+	}
+}
+
+// Timers возвращает число ожидающих таймеров
+func (c *Fake) Timers() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers)
+}
+
+// BlockUntil ждёт, пока число ожидающих таймеров не станет не меньше n. Так тест дожидается,
+// пока обработчик в другой горутине дойдёт до ожидания, прежде чем переводить часы.
+func (c *Fake) BlockUntil(n int) {
+	for {
+		c.mu.Lock()
+		if len(c.timers) >= n {
+			c.mu.Unlock()
+			return
+		}
+		changed := c.changed
+		c.mu.Unlock()
+		<-changed
+	}
+}
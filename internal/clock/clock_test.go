@@ -0,0 +1,42 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+// TestFake проверяет порядок срабатывания таймеров, отмену, After и ожидание таймеров в BlockUntil
+func TestFake(t *testing.T) {
+	t.Parallel()
+	start := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	c := NewFake(start)
+	var fired []time.Duration
+	record := func() { fired = append(fired, c.Now().Sub(start)) }
+	c.AfterFunc(3*time.Second, record)
+	c.AfterFunc(time.Second, func() {
+		fired = append(fired, c.Now().Sub(start))
+		// Таймер, созданный при срабатывании, срабатывает в том же Advance, если его срок наступил
+		c.AfterFunc(time.Second, record)
+	})
+	stopped := c.AfterFunc(2500*time.Millisecond, record)
+	if !stopped.Stop() || stopped.Stop() {
+		t.Error("Stop должен отменять таймер ровно один раз")
+	}
+
+	c.Advance(2 * time.Second)
+	if len(fired) != 2 || fired[0] != time.Second || fired[1] != 2*time.Second {
+		t.Errorf("неверные срабатывания: %v", fired)
+	}
+	c.Advance(2 * time.Second)
+	if len(fired) != 3 || fired[2] != 3*time.Second || c.Now() != start.Add(4*time.Second) {
+		t.Errorf("неверные срабатывания: %v, время %s", fired, c.Now())
+	}
+
+	got := make(chan time.Time)
+	go func() { got <- <-c.After(time.Minute) }()
+	c.BlockUntil(1)
+	c.Advance(time.Minute)
+	if at := <-got; at != start.Add(4*time.Second+time.Minute) {
+		t.Errorf("After: получили %s", at)
+	}
+}
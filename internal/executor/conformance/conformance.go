@@ -0,0 +1,280 @@
+// Package conformance - набор тестов совместимости внешних исполнителей с протоколом
+// (internal/executor/protocol.go). Run проверяет общие правила протокола на любом исполнителе
+// и сценарии Case, которые описывает автор исполнителя для своих типов задач.
+package conformance
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"workmateTestProject/internal/executor"
+)
+
+// Case - сценарий для типа задач исполнителя
+type Case struct {
+	Name string
+	Type string
+	// Payload - входные данные задачи в JSON
+	Payload string
+	// WantResult - ожидаемый результат; nil - не проверяется
+	WantResult *string
+	// WantError - задача должна завершиться ошибкой
+	WantError bool
+	// WantProgress - исполнитель должен сообщить о ходе обработки хотя бы раз
+	WantProgress bool
+	// Cancel - задача долгая: проверяется параллельная обработка и ответ на cancel
+	Cancel bool
+}
+
+// Suite - проверяемый исполнитель
+type Suite struct {
+	// Argv - команда запуска исполнителя
+	Argv []string
+	// Cases - сценарии для типов задач исполнителя; без них проверяются только общие правила
+	Cases []Case
+	// Timeout - сколько ждать каждого ответа исполнителя (по умолчанию 5s)
+	Timeout time.Duration
+}
+
+// Run запускает набор тестов как подтесты t
+func Run(t *testing.T, s Suite) {
+	if s.Timeout <= 0 {
+		s.Timeout = 5 * time.Second
+	}
+	t.Run("ready", func(t *testing.T) {
+		c := start(t, s)
+		c.close(t)
+	})
+	t.Run("unknown_type", func(t *testing.T) {
+		c := start(t, s)
+		id := c.execute(t, "conformance.unknown."+uuid.NewString(), "{}")
+		if res := c.result(t, id); res.Error == "" {
+			t.Errorf("для неизвестного типа задачи ожидался result с error, получили %+v", res)
+		}
+		c.close(t)
+	})
+	t.Run("cancel_unknown_id", func(t *testing.T) {
+		c := start(t, s)
+		c.send(t, executor.Message{Type: executor.MsgCancel, ID: uuid.NewString()})
+		id := c.execute(t, "conformance.unknown", "{}")
+		c.result(t, id)
+		c.close(t)
+	})
+	t.Run("interleaved", func(t *testing.T) {
+		c := start(t, s)
+		ids := make([]string, 10)
+		for i := range ids {
+			ids[i] = c.execute(t, "conformance.unknown", "{}")
+		}
+		for _, id := range ids {
+			c.result(t, id)
+		}
+		c.close(t)
+	})
+	for _, tc := range s.Cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			c := start(t, s)
+			id := c.execute(t, tc.Type, tc.Payload)
+			if tc.Cancel {
+				// Пока задача выполняется, исполнитель обязан принимать и выполнять другие
+				other := c.execute(t, "conformance.unknown", "{}")
+				c.result(t, other)
+				c.send(t, executor.Message{Type: executor.MsgCancel, ID: id})
+			}
+			res := c.result(t, id)
+			if tc.WantResult != nil && res.Result != *tc.WantResult {
+				t.Errorf("ожидался результат %q, получили %q", *tc.WantResult, res.Result)
+			}
+			if (res.Error != "") != (tc.WantError || tc.Cancel) {
+				t.Errorf("неожиданная ошибка %q", res.Error)
+			}
+			if tc.WantProgress && c.count(id, executor.MsgProgress) == 0 {
+				t.Error("исполнитель не сообщил о ходе обработки")
+			}
+			c.close(t)
+		})
+	}
+}
+
+// conn - запущенный исполнитель и проверка его сообщений
+type conn struct {
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	timeout time.Duration
+	done    chan struct{}
+
+	mu       sync.Mutex
+	inflight map[string]bool
+	results  map[string]chan executor.Message
+	counts   map[string]map[string]int
+	errs     []string
+}
+
+// start запускает исполнитель и ждёт ready
+func start(t *testing.T, s Suite) *conn {
+	t.Helper()
+	cmd := exec.Command(s.Argv[0], s.Argv[1:]...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("запуск исполнителя: %v", err)
+	}
+	c := &conn{
+		cmd: cmd, stdin: stdin, timeout: s.Timeout, done: make(chan struct{}),
+		inflight: make(map[string]bool),
+		results:  make(map[string]chan executor.Message),
+		counts:   make(map[string]map[string]int),
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		<-c.done
+	})
+
+	ready := make(chan executor.Message, 1)
+	go func() {
+		defer close(c.done)
+		sc := bufio.NewScanner(stdout)
+		sc.Buffer(make([]byte, 0, 64<<10), executor.MaxMessageSize)
+		first := true
+		for sc.Scan() {
+			var msg executor.Message
+			if err := json.Unmarshal(sc.Bytes(), &msg); err != nil {
+				c.violation("строка stdout не является сообщением JSON: %q", sc.Text())
+				continue
+			}
+			if first {
+				first = false
+				ready <- msg
+				continue
+			}
+			c.handle(msg)
+		}
+		if err := sc.Err(); err != nil {
+			c.violation("чтение stdout: %v", err)
+		}
+		cmd.Wait()
+	}()
+
+	select {
+	case msg := <-ready:
+		if msg.Type != executor.MsgReady {
+			t.Fatalf("первым сообщением должно быть ready, получили %+v", msg)
+		}
+		if msg.Protocol != executor.ProtocolVersion {
+			t.Fatalf("ожидалась версия протокола %d, получили %d", executor.ProtocolVersion, msg.Protocol)
+		}
+	case <-c.done:
+		t.Fatal("исполнитель завершился, не отправив ready")
+	case <-time.After(s.Timeout):
+		t.Fatalf("исполнитель не отправил ready за %s", s.Timeout)
+	}
+	return c
+}
+
+// handle проверяет сообщение исполнителя по правилам протокола
+func (c *conn) handle(msg executor.Message) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch msg.Type {
+	case executor.MsgLog, executor.MsgProgress, executor.MsgResult:
+	default:
+		c.errs = append(c.errs, fmt.Sprintf("неизвестный тип сообщения %q", msg.Type))
+		return
+	}
+	if !c.inflight[msg.ID] {
+		c.errs = append(c.errs, fmt.Sprintf("сообщение %s для задачи %q, которая не выполняется", msg.Type, msg.ID))
+		return
+	}
+	if c.counts[msg.ID] == nil {
+		c.counts[msg.ID] = make(map[string]int)
+	}
+	c.counts[msg.ID][msg.Type]++
+	if msg.Type == executor.MsgResult {
+		delete(c.inflight, msg.ID)
+		c.results[msg.ID] <- msg
+	}
+}
+
+func (c *conn) violation(format string, args ...any) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.errs = append(c.errs, fmt.Sprintf(format, args...))
+}
+
+// count возвращает число сообщений типа typ для задачи id
+func (c *conn) count(id, typ string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.counts[id][typ]
+}
+
+func (c *conn) send(t *testing.T, msg executor.Message) {
+	t.Helper()
+	data, _ := json.Marshal(msg)
+	if _, err := c.stdin.Write(append(data, '\n')); err != nil {
+		t.Fatalf("запись в stdin: %v", err)
+	}
+}
+
+// execute отправляет задачу и возвращает её id
+func (c *conn) execute(t *testing.T, typ, payload string) string {
+	t.Helper()
+	id := uuid.NewString()
+	c.mu.Lock()
+	c.inflight[id] = true
+	c.results[id] = make(chan executor.Message, 1)
+	c.mu.Unlock()
+	c.send(t, executor.Message{Type: executor.MsgExecute, ID: id, Task: &executor.TaskSpec{
+		ID: id, Type: typ, Payload: json.RawMessage(payload),
+	}})
+	return id
+}
+
+// result ждёт result для задачи id
+func (c *conn) result(t *testing.T, id string) executor.Message {
+	t.Helper()
+	c.mu.Lock()
+	ch := c.results[id]
+	c.mu.Unlock()
+	select {
+	case msg := <-ch:
+		return msg
+	case <-c.done:
+		t.Fatalf("исполнитель завершился, не ответив на задачу %s", id)
+	case <-time.After(c.timeout):
+		t.Fatalf("нет result для задачи %s за %s", id, c.timeout)
+	}
+	return executor.Message{}
+}
+
+// close закрывает stdin, ждёт завершения исполнителя и сообщает о нарушениях протокола
+func (c *conn) close(t *testing.T) {
+	t.Helper()
+	c.stdin.Close()
+	select {
+	case <-c.done:
+	case <-time.After(c.timeout):
+		t.Errorf("исполнитель не завершился за %s после закрытия stdin", c.timeout)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, e := range c.errs {
+		t.Error(e)
+	}
+	for id := range c.inflight {
+		t.Errorf("нет result для задачи %s", id)
+	}
+}
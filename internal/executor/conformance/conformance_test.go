@@ -0,0 +1,36 @@
+package conformance
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestReferenceExecutor проверяет эталонный исполнитель cmd/reference-executor.
+// Сторонний исполнитель проверяется той же командой с EXECUTOR_CONFORMANCE_CMD
+// (только общие правила протокола):
+//
+//	EXECUTOR_CONFORMANCE_CMD="/opt/executors/report --stdio" go test ./internal/executor/conformance/
+func TestReferenceExecutor(t *testing.T) {
+	if spec := os.Getenv("EXECUTOR_CONFORMANCE_CMD"); spec != "" {
+		Run(t, Suite{Argv: strings.Fields(spec)})
+		return
+	}
+
+	bin := filepath.Join(t.TempDir(), "reference-executor")
+	out, err := exec.Command("go", "build", "-o", bin, "workmateTestProject/cmd/reference-executor").CombinedOutput()
+	if err != nil {
+		t.Fatalf("сборка эталонного исполнителя: %v\n%s", err, out)
+	}
+	payload := `{"a":[1,2]}`
+	done := "Ожидание 30ms завершено"
+	Run(t, Suite{Argv: []string{bin}, Cases: []Case{
+		{Name: "echo", Type: "echo", Payload: payload, WantResult: &payload},
+		{Name: "sleep", Type: "sleep", Payload: `{"duration":"30ms","steps":3}`, WantResult: &done, WantProgress: true},
+		{Name: "sleep_fail", Type: "sleep", Payload: `{"duration":"1ms","fail":"сбой"}`, WantError: true},
+		{Name: "sleep_bad_payload", Type: "sleep", Payload: `{"duration":5}`, WantError: true},
+		{Name: "sleep_cancel", Type: "sleep", Payload: `{"duration":"1m","steps":60}`, Cancel: true},
+	}})
+}
@@ -0,0 +1,232 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"workmateTestProject/internal/model"
+	"workmateTestProject/internal/service"
+)
+
+// ErrHostNotAllowed возвращается для запросов к узлам вне списка разрешённых
+var ErrHostNotAllowed = errors.New("узел не входит в список разрешённых")
+
+// defaultMaxBody - сколько байт тела ответа сохраняется в результате по умолчанию
+const defaultMaxBody = 64 << 10
+
+// HTTPRequest - payload задачи HTTP-исполнителя
+type HTTPRequest struct {
+	// Method - HTTP-метод, по умолчанию GET
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+	// Body - строка передаётся как есть, любое другое JSON-значение - как JSON
+	Body json.RawMessage `json:"body,omitempty"`
+	// ExpectedStatus - коды ответа, считающиеся успешными; по умолчанию любой 2xx
+	ExpectedStatus []int `json:"expected_status,omitempty"`
+}
+
+// HTTPResult - результат задачи HTTP-исполнителя
+type HTTPResult struct {
+	Status  int         `json:"status"`
+	Headers http.Header `json:"headers"`
+	Body    string      `json:"body"`
+	// BodyEncoding - base64, если тело ответа не является текстом UTF-8
+	BodyEncoding string `json:"body_encoding,omitempty"`
+	// Truncated - тело ответа длиннее MaxBody и сохранено не полностью
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// HTTPConfig - настройки HTTP-исполнителя
+type HTTPConfig struct {
+	// AllowedHosts - разрешённые узлы: "host" (любой порт), "host:port" или "*.domain" (поддомены)
+	AllowedHosts []string
+	// MaxBody - сколько байт тела ответа сохранять в результате
+	MaxBody int64
+	// Transport - транспорт HTTP-клиента; nil - http.DefaultTransport
+	Transport http.RoundTripper
+}
+
+// HTTP выполняет задачи, payload которых описывает HTTP-запрос (HTTPRequest).
+// Запросы, в том числе перенаправления, допускаются только к узлам из AllowedHosts.
+type HTTP struct {
+	allowed []string
+	maxBody int64
+	client  *http.Client
+}
+
+// NewHTTP создаёт HTTP-исполнитель
+func NewHTTP(cfg HTTPConfig) *HTTP {
+	e := &HTTP{maxBody: cfg.MaxBody}
+	if e.maxBody <= 0 {
+		e.maxBody = defaultMaxBody
+	}
+	for _, h := range cfg.AllowedHosts {
+		if h = strings.ToLower(strings.TrimSpace(h)); h != "" {
+			e.allowed = append(e.allowed, h)
+		}
+	}
+	e.client = &http.Client{
+		Transport: cfg.Transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return errors.New("слишком много перенаправлений")
+			}
+			return e.checkURL(req.URL)
+		},
+	}
+	return e
+}
+
+// ParseHosts разбирает список узлов через запятую
+func ParseHosts(spec string) []string {
+	var hosts []string
+	for _, h := range strings.Split(spec, ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			hosts = append(hosts, h)
+		}
+	}
+	return hosts
+}
+
+// checkURL проверяет схему и узел адреса по списку разрешённых
+func (e *HTTP) checkURL(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("схема %q не поддерживается, ожидалась http или https", u.Scheme)
+	}
+	host := strings.ToLower(u.Hostname())
+	if host == "" {
+		return errors.New("в url не указан узел")
+	}
+	port := u.Port()
+	if port == "" {
+		port = map[string]string{"http": "80", "https": "443"}[u.Scheme]
+	}
+	for _, a := range e.allowed {
+		allowedHost, allowedPort, err := net.SplitHostPort(a)
+		if err != nil {
+			allowedHost, allowedPort = a, ""
+		}
+		if allowedPort != "" && allowedPort != port {
+			continue
+		}
+		if allowedHost == host || (strings.HasPrefix(allowedHost, "*.") && strings.HasSuffix(host, allowedHost[1:])) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrHostNotAllowed, u.Host)
+}
+
+// parse разбирает payload задачи
+func (e *HTTP) parse(task *model.Task) (*HTTPRequest, *url.URL, error) {
+	var req HTTPRequest
+	if err := json.Unmarshal(task.Payload, &req); err != nil {
+		return nil, nil, fmt.Errorf("payload: ожидался объект с method, url, headers, body и expected_status: %v", err)
+	}
+	if req.Method == "" {
+		req.Method = http.MethodGet
+	}
+	u, err := url.Parse(req.URL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("payload.url: %v", err)
+	}
+	if err := e.checkURL(u); err != nil {
+		return nil, nil, fmt.Errorf("payload.url: %w", err)
+	}
+	for _, code := range req.ExpectedStatus {
+		if code < 100 || code > 599 {
+			return nil, nil, fmt.Errorf("payload.expected_status: неверный код %d", code)
+		}
+	}
+	return &req, u, nil
+}
+
+// ValidatePayload проверяет payload при создании и изменении задачи
+func (e *HTTP) ValidatePayload(task *model.Task) error {
+	_, _, err := e.parse(task)
+	return err
+}
+
+// Execute выполняет запрос. Результат - HTTPResult в JSON; код ответа вне ExpectedStatus
+// возвращается вместе с результатом как ошибка.
+func (e *HTTP) Execute(ctx context.Context, task *model.Task) (string, error) {
+	spec, u, err := e.parse(task)
+	if err != nil {
+		return "", err
+	}
+	var body io.Reader
+	jsonBody := false
+	if len(spec.Body) > 0 {
+		var s string
+		if json.Unmarshal(spec.Body, &s) == nil {
+			body = strings.NewReader(s)
+		} else {
+			body, jsonBody = bytes.NewReader(spec.Body), true
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, spec.Method, u.String(), body)
+	if err != nil {
+		return "", err
+	}
+	if jsonBody {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range spec.Headers {
+		req.Header.Set(k, v)
+	}
+
+	start := time.Now()
+	service.Logf(ctx, "%s %s", spec.Method, u.Redacted())
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, e.maxBody+1))
+	if err != nil {
+		return "", fmt.Errorf("чтение ответа: %w", err)
+	}
+	service.Logf(ctx, "Ответ %d за %s, прочитано %d байт", resp.StatusCode, time.Since(start).Round(time.Millisecond), len(data))
+
+	res := HTTPResult{Status: resp.StatusCode, Headers: resp.Header}
+	if int64(len(data)) > e.maxBody {
+		data, res.Truncated = data[:e.maxBody], true
+	}
+	if utf8.Valid(data) {
+		res.Body = string(data)
+	} else {
+		res.Body, res.BodyEncoding = base64.StdEncoding.EncodeToString(data), "base64"
+	}
+	out, err := json.Marshal(res)
+	if err != nil {
+		return "", err
+	}
+	if !expected(resp.StatusCode, spec.ExpectedStatus) {
+		return string(out), fmt.Errorf("неожиданный код ответа %d", resp.StatusCode)
+	}
+	return string(out), nil
+}
+
+// expected сообщает, входит ли код в список ожидаемых (пустой список - любой 2xx)
+func expected(code int, codes []int) bool {
+	if len(codes) == 0 {
+		return code >= 200 && code < 300
+	}
+	for _, c := range codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
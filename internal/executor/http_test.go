@@ -0,0 +1,111 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"workmateTestProject/internal/model"
+)
+
+// TestHTTP_Execute проверяет выполнение запроса, сохранение кода, заголовков и усечённого тела,
+// неожиданный код ответа, запрет чужих узлов и перенаправлений на них, отмену по контексту.
+func TestHTTP_Execute(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/echo":
+			body, _ := io.ReadAll(r.Body)
+			w.Header().Set("X-Method", r.Method)
+			w.Header().Set("X-Content-Type", r.Header.Get("Content-Type"))
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(strings.Repeat(string(body), 3)))
+		case "/fail":
+			w.WriteHeader(http.StatusInternalServerError)
+		case "/redirect":
+			http.Redirect(w, r, "http://evil.example/", http.StatusFound)
+		case "/slow":
+			<-r.Context().Done()
+		}
+	}))
+	defer srv.Close()
+	host := mustHost(t, srv.URL)
+
+	e := NewHTTP(HTTPConfig{AllowedHosts: []string{host}, MaxBody: 10})
+	task := func(payload string) *model.Task {
+		return &model.Task{ID: uuid.New(), Type: "http", Payload: json.RawMessage(payload)}
+	}
+
+	out, err := e.Execute(context.Background(), task(`{"method":"POST","url":"`+srv.URL+`/echo","body":{"a":1},"expected_status":[201]}`))
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	var res HTTPResult
+	json.Unmarshal([]byte(out), &res)
+	if res.Status != http.StatusCreated || res.Headers.Get("X-Method") != "POST" || res.Headers.Get("X-Content-Type") != "application/json" {
+		t.Errorf("неверный результат: %+v", res)
+	}
+	if res.Body != `{"a":1}{"a` || !res.Truncated {
+		t.Errorf("тело должно быть усечено до 10 байт, получили %q (truncated=%v)", res.Body, res.Truncated)
+	}
+
+	out, err = e.Execute(context.Background(), task(`{"url":"`+srv.URL+`/fail"}`))
+	if err == nil || !strings.Contains(out, `"status":500`) {
+		t.Errorf("код 500 должен давать ошибку с результатом, получили %q, %v", out, err)
+	}
+
+	for _, payload := range []string{
+		`{"url":"http://evil.example/"}`,
+		`{"url":"file:///etc/passwd"}`,
+		`{"url":"` + strings.Replace(srv.URL, "127.0.0.1", "localhost", 1) + `/echo"}`,
+	} {
+		if err := e.ValidatePayload(task(payload)); err == nil {
+			t.Errorf("payload %s должен быть отклонён", payload)
+		}
+	}
+	if _, err := e.Execute(context.Background(), task(`{"url":"`+srv.URL+`/redirect"}`)); !errors.Is(err, ErrHostNotAllowed) {
+		t.Errorf("перенаправление на чужой узел должно быть запрещено, получили %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := e.Execute(ctx, task(`{"url":"`+srv.URL+`/slow"}`)); !errors.Is(err, context.Canceled) {
+		t.Errorf("ожидалась отмена запроса, получили %v", err)
+	}
+}
+
+// TestHTTP_AllowedHosts проверяет сопоставление узлов с шаблонами списка разрешённых
+func TestHTTP_AllowedHosts(t *testing.T) {
+	e := NewHTTP(HTTPConfig{AllowedHosts: ParseHosts("api.internal, billing.internal:8443, *.svc.cluster.local")})
+	cases := map[string]bool{
+		"http://api.internal/x":                   true,
+		"https://API.internal:9000/x":             true,
+		"https://billing.internal:8443/":          true,
+		"https://billing.internal/":               false,
+		"http://orders.svc.cluster.local/":        true,
+		"http://svc.cluster.local/":               false,
+		"http://api.internal.evil.example/":       false,
+		"http://169.254.169.254/latest/meta-data": false,
+	}
+	for raw, want := range cases {
+		u, _ := url.Parse(raw)
+		if got := e.checkURL(u) == nil; got != want {
+			t.Errorf("%s: ожидалось %v, получили %v", raw, want, got)
+		}
+	}
+}
+
+func mustHost(t *testing.T, raw string) string {
+	t.Helper()
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u.Host
+}
@@ -0,0 +1,370 @@
+package executor
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"workmateTestProject/internal/model"
+	"workmateTestProject/internal/service"
+)
+
+// ErrExecutorExited возвращается задачам, обработка которых прервалась завершением процесса исполнителя
+var ErrExecutorExited = errors.New("процесс исполнителя завершился")
+
+const (
+	// defaultStartTimeout - сколько ждать сообщения ready после запуска процесса
+	defaultStartTimeout = 10 * time.Second
+	// defaultCancelGrace - сколько ждать result после cancel или выхода после закрытия stdin
+	defaultCancelGrace = 5 * time.Second
+)
+
+// ProcessConfig - настройки внешнего исполнителя
+type ProcessConfig struct {
+	// Argv - исполняемый файл и аргументы процесса исполнителя
+	Argv []string
+	// Types - типы задач, назначенные процессу; каждый должен быть в task_types сообщения ready
+	Types []string
+	// Dir и Env - рабочий каталог и окружение процесса; nil Env - окружение сервиса
+	Dir string
+	Env []string
+	// StartTimeout - сколько ждать сообщения ready после запуска
+	StartTimeout time.Duration
+	// CancelGrace - сколько ждать result после cancel, а при остановке - выхода процесса
+	CancelGrace time.Duration
+}
+
+// Process выполняет задачи во внешнем процессе по протоколу JSON через stdin/stdout
+// (см. описание в protocol.go). Процесс запускается методом Start или при первой задаче и
+// обрабатывает задачи параллельно; после его завершения следующая задача запускает процесс заново.
+type Process struct {
+	cfg  ProcessConfig
+	name string
+
+	mu     sync.Mutex
+	conn   *processConn
+	closed bool
+}
+
+// processConn - запущенный процесс исполнителя
+type processConn struct {
+	cmd   *exec.Cmd
+	ready chan struct{}
+	// done закрывается после завершения процесса, err - причина
+	done chan struct{}
+	err  error
+
+	wmu   sync.Mutex
+	stdin io.WriteCloser
+
+	mu    sync.Mutex
+	calls map[string]*processCall
+}
+
+// processCall - задача, переданная процессу
+type processCall struct {
+	ctx    context.Context
+	result chan Message
+}
+
+// NewProcess создаёт внешний исполнитель; процесс запускается при первой задаче
+func NewProcess(cfg ProcessConfig) (*Process, error) {
+	if len(cfg.Argv) == 0 || cfg.Argv[0] == "" {
+		return nil, errors.New("не указан исполняемый файл")
+	}
+	if cfg.StartTimeout <= 0 {
+		cfg.StartTimeout = defaultStartTimeout
+	}
+	if cfg.CancelGrace <= 0 {
+		cfg.CancelGrace = defaultCancelGrace
+	}
+	return &Process{cfg: cfg, name: filepath.Base(cfg.Argv[0])}, nil
+}
+
+// ParseProcesses разбирает список внешних исполнителей вида "тип=команда аргументы,тип=команда".
+// Типы с одинаковой командой обслуживает один процесс.
+func ParseProcesses(spec string) (map[string][]string, error) {
+	res := make(map[string][]string)
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		typ, command, ok := strings.Cut(item, "=")
+		argv := strings.Fields(command)
+		if !ok || strings.TrimSpace(typ) == "" || len(argv) == 0 {
+			return nil, fmt.Errorf("неверный элемент %q, ожидалось тип=команда", item)
+		}
+		res[strings.TrimSpace(typ)] = argv
+	}
+	return res, nil
+}
+
+// Start запускает процесс и ждёт сообщения ready. Ошибка означает, что процесс не запустился,
+// не ответил за StartTimeout или не объявил какой-либо из типов задач Types.
+func (p *Process) Start(ctx context.Context) error {
+	_, err := p.connect(ctx)
+	return err
+}
+
+// connect возвращает работающий процесс, при необходимости запуская его
+func (p *Process) connect(ctx context.Context) (*processConn, error) {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil, errors.New("исполнитель остановлен")
+	}
+	c := p.conn
+	if c != nil {
+		select {
+		case <-c.done:
+			c = nil
+		default:
+		}
+	}
+	if c == nil {
+		var err error
+		if c, err = p.start(); err != nil {
+			p.mu.Unlock()
+			return nil, err
+		}
+		p.conn = c
+	}
+	p.mu.Unlock()
+
+	timer := time.NewTimer(p.cfg.StartTimeout)
+	defer timer.Stop()
+	select {
+	case <-c.ready:
+		return c, nil
+	case <-c.done:
+		return nil, c.err
+	case <-timer.C:
+		c.kill()
+		return nil, fmt.Errorf("исполнитель %s не ответил ready за %s", p.name, p.cfg.StartTimeout)
+	case <-ctx.Done():
+		return nil, context.Cause(ctx)
+	}
+}
+
+// start запускает процесс и горутины чтения его stdout и stderr; вызывается под p.mu
+func (p *Process) start() (*processConn, error) {
+	cmd := exec.Command(p.cfg.Argv[0], p.cfg.Argv[1:]...)
+	cmd.Dir, cmd.Env = p.cfg.Dir, p.cfg.Env
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("запуск исполнителя %s: %w", p.name, err)
+	}
+	log.Printf("Исполнитель %s запущен (pid %d)", p.name, cmd.Process.Pid)
+
+	c := &processConn{
+		cmd:   cmd,
+		stdin: stdin,
+		ready: make(chan struct{}),
+		done:  make(chan struct{}),
+		calls: make(map[string]*processCall),
+	}
+	stderrDone := make(chan struct{})
+	go func() {
+		defer close(stderrDone)
+		sc := bufio.NewScanner(stderr)
+		for sc.Scan() {
+			log.Printf("[%s] %s", p.name, sc.Text())
+		}
+	}()
+	go func() {
+		readErr := c.read(p.name, p.cfg.Types, stdout)
+		<-stderrDone
+		waitErr := cmd.Wait()
+		c.err = fmt.Errorf("%w: %s", ErrExecutorExited, p.name)
+		switch {
+		case readErr != nil:
+			c.err = fmt.Errorf("%w: %v", c.err, readErr)
+		case waitErr != nil:
+			c.err = fmt.Errorf("%w: %v", c.err, waitErr)
+		}
+		log.Printf("%v", c.err)
+		close(c.done)
+	}()
+	return c, nil
+}
+
+// read разбирает сообщения процесса до закрытия stdout; types - типы задач, которые процесс
+// должен объявить в ready
+func (c *processConn) read(name string, types []string, stdout io.Reader) error {
+	sc := bufio.NewScanner(stdout)
+	sc.Buffer(make([]byte, 0, 64<<10), MaxMessageSize)
+	readySeen := false
+	for sc.Scan() {
+		var msg Message
+		if err := json.Unmarshal(sc.Bytes(), &msg); err != nil {
+			log.Printf("[%s] сообщение не разобрано: %v", name, err)
+			continue
+		}
+		if msg.Type == MsgReady {
+			if msg.Protocol != ProtocolVersion {
+				c.kill()
+				return fmt.Errorf("неподдерживаемая версия протокола %d", msg.Protocol)
+			}
+			if missing := missingTypes(types, msg.TaskTypes); len(missing) > 0 {
+				c.kill()
+				return fmt.Errorf("в ready нет типов задач %s", strings.Join(missing, ", "))
+			}
+			if !readySeen {
+				readySeen = true
+				close(c.ready)
+			}
+			continue
+		}
+		c.mu.Lock()
+		call := c.calls[msg.ID]
+		if msg.Type == MsgResult {
+			delete(c.calls, msg.ID)
+		}
+		c.mu.Unlock()
+		if call == nil {
+			continue
+		}
+		switch msg.Type {
+		case MsgLog:
+			service.Logf(call.ctx, "%s", msg.Message)
+		case MsgProgress:
+			service.Progress(call.ctx, "%s", msg.Message)
+		case MsgResult:
+			call.result <- msg
+		default:
+			log.Printf("[%s] неизвестный тип сообщения %q", name, msg.Type)
+		}
+	}
+	// Процесс закрыл stdout или прислал слишком длинную строку - дальнейший обмен невозможен
+	c.kill()
+	return sc.Err()
+}
+
+// missingTypes возвращает типы из want, которых нет в have
+func missingTypes(want, have []string) []string {
+	var missing []string
+	for _, typ := range want {
+		if !slices.Contains(have, typ) {
+			missing = append(missing, typ)
+		}
+	}
+	return missing
+}
+
+// send отправляет сообщение процессу
+func (c *processConn) send(msg Message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	_, err = c.stdin.Write(append(data, '\n'))
+	return err
+}
+
+// kill принудительно завершает процесс
+func (c *processConn) kill() {
+	c.cmd.Process.Kill()
+}
+
+// Execute передаёт задачу процессу и ждёт её результата. При отмене ctx процессу
+// отправляется cancel; если result не пришёл за CancelGrace, задача завершается без него.
+// Каждый вызов получает свой id, поэтому result брошенного вызова, пришедший позже,
+// не достанется повторной попытке той же задачи.
+func (p *Process) Execute(ctx context.Context, task *model.Task) (string, error) {
+	c, err := p.connect(ctx)
+	if err != nil {
+		return "", err
+	}
+	id := uuid.NewString()
+	call := &processCall{ctx: ctx, result: make(chan Message, 1)}
+	c.mu.Lock()
+	c.calls[id] = call
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.calls, id)
+		c.mu.Unlock()
+	}()
+
+	if err := c.send(Message{Type: MsgExecute, ID: id, Task: taskSpec(task)}); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrExecutorExited, err)
+	}
+	result := func(msg Message) (string, error) {
+		if msg.Error != "" {
+			return msg.Result, errors.New(msg.Error)
+		}
+		return msg.Result, nil
+	}
+	var graceC <-chan time.Time
+	ctxDone := ctx.Done()
+	for {
+		select {
+		case msg := <-call.result:
+			return result(msg)
+		case <-c.done:
+			// result, прочитанный до завершения процесса, уже в канале
+			select {
+			case msg := <-call.result:
+				return result(msg)
+			default:
+			}
+			return "", c.err
+		case <-ctxDone:
+			ctxDone = nil
+			c.send(Message{Type: MsgCancel, ID: id})
+			timer := time.NewTimer(p.cfg.CancelGrace)
+			defer timer.Stop()
+			graceC = timer.C
+		case <-graceC:
+			service.Logf(ctx, "Исполнитель %s не подтвердил отмену за %s", p.name, p.cfg.CancelGrace)
+			return "", context.Cause(ctx)
+		}
+	}
+}
+
+// Close закрывает stdin процесса и ждёт его завершения не дольше CancelGrace,
+// после чего завершает процесс принудительно
+func (p *Process) Close() error {
+	p.mu.Lock()
+	p.closed = true
+	c := p.conn
+	p.mu.Unlock()
+	if c == nil {
+		return nil
+	}
+	c.wmu.Lock()
+	c.stdin.Close()
+	c.wmu.Unlock()
+	select {
+	case <-c.done:
+	case <-time.After(p.cfg.CancelGrace):
+		c.kill()
+		<-c.done
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"workmateTestProject/internal/model"
+	"workmateTestProject/internal/service"
+)
+
+// TestMain с EXECUTOR_TEST_SERVE=1 превращает тестовый бинарь во внешний исполнитель для TestProcess
+func TestMain(m *testing.M) {
+	if os.Getenv("EXECUTOR_TEST_SERVE") == "1" {
+		Serve(os.Stdin, os.Stdout, []string{"proc-ok", "proc-crash", "proc-wait", "proc-late"}, func(ctx context.Context, task *TaskSpec, r *Reporter) (string, error) {
+			switch task.Type {
+			case "proc-ok":
+				r.Logf("получено %s", task.Payload)
+				r.Progressf("половина")
+				return "готово " + task.ID, nil
+			case "proc-crash":
+				os.Exit(3)
+			case "proc-wait":
+				<-ctx.Done()
+				return "", errors.New("остановлено")
+			case "proc-late":
+				// Не замечает cancel и отвечает после задержки из payload
+				var p struct {
+					Delay  model.Duration `json:"delay"`
+					Result string         `json:"result"`
+				}
+				json.Unmarshal(task.Payload, &p)
+				time.Sleep(time.Duration(p.Delay))
+				return p.Result, nil
+			}
+			return "", errors.New("неизвестный тип")
+		})
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// TestProcess проверяет выполнение задач внешним исполнителем: результат, журнал и ход обработки,
+// отмену, завершение процесса во время обработки и его перезапуск
+func TestProcess(t *testing.T) {
+	self, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := NewProcess(ProcessConfig{Argv: []string{self}, Env: append(os.Environ(), "EXECUTOR_TEST_SERVE=1"), CancelGrace: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	for _, typ := range []string{"proc-ok", "proc-crash", "proc-wait"} {
+		service.RegisterExecutor(typ, p)
+		defer service.RegisterExecutor(typ, nil)
+	}
+
+	run := func(typ string, cancelAfter time.Duration) *model.Task {
+		t.Helper()
+		task := &model.Task{ID: uuid.New(), Type: typ, Status: model.StatusPending, Payload: json.RawMessage(`{"n":1}`)}
+		if err := service.StartProcessing(task); err != nil {
+			t.Fatalf("StartProcessing: %v", err)
+		}
+		if cancelAfter > 0 {
+			time.Sleep(cancelAfter)
+			service.Cancel(context.Background(), task.ID, "alice")
+		}
+		deadline := time.Now().Add(5 * time.Second)
+		for service.Active(task.ID) {
+			if time.Now().After(deadline) {
+				t.Fatalf("задача %s не завершилась", typ)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		return task
+	}
+
+	task := run("proc-ok", 0)
+	if task.Status != model.StatusCompleted || task.Result != "готово "+task.ID.String() {
+		t.Fatalf("ожидался Completed, получили %s %q %q", task.Status, task.Result, task.Error)
+	}
+	buf, _ := service.TaskLogs(task.ID)
+	lines, _ := buf.Lines(0, 0)
+	var logs []string
+	for _, l := range lines {
+		logs = append(logs, l.Message)
+	}
+	if joined := strings.Join(logs, "\n"); !strings.Contains(joined, `получено {"n":1}`) || !strings.Contains(joined, "половина") {
+		t.Errorf("в журнале нет сообщений исполнителя:\n%s", joined)
+	}
+	progress := 0
+	for _, e := range service.TaskHistory(task.ID) {
+		if e.Type == model.EventProgress && e.Message == "половина" {
+			progress++
+		}
+	}
+	if progress != 1 {
+		t.Errorf("ожидалось одно событие progress, получили %d", progress)
+	}
+
+	if task := run("proc-wait", 100*time.Millisecond); task.Status != model.StatusCanceled {
+		t.Errorf("ожидался Canceled, получили %s %q", task.Status, task.Error)
+	}
+
+	task = run("proc-crash", 0)
+	if task.Status != model.StatusFailed || !strings.Contains(task.Error, ErrExecutorExited.Error()) {
+		t.Errorf("ожидался Failed из-за завершения процесса, получили %s %q", task.Status, task.Error)
+	}
+	if task := run("proc-ok", 0); task.Status != model.StatusCompleted {
+		t.Errorf("после перезапуска процесса ожидался Completed, получили %s %q", task.Status, task.Error)
+	}
+}
+
+// TestProcess_LateResult проверяет, что result брошенной после cancel попытки, пришедший позже,
+// не достаётся повторной попытке той же задачи
+func TestProcess_LateResult(t *testing.T) {
+	self, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := NewProcess(ProcessConfig{Argv: []string{self}, Env: append(os.Environ(), "EXECUTOR_TEST_SERVE=1"), CancelGrace: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	task := &model.Task{ID: uuid.New(), Type: "proc-late", Payload: json.RawMessage(`{"delay":"300ms","result":"первая"}`)}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := p.Execute(ctx, task); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("первая попытка должна завершиться по отмене, получили %v", err)
+	}
+
+	task.Payload = json.RawMessage(`{"delay":"600ms","result":"вторая"}`)
+	if res, err := p.Execute(context.Background(), task); err != nil || res != "вторая" {
+		t.Errorf("повторная попытка получила чужой результат: %q %v", res, err)
+	}
+}
+
+// TestProcess_MissingType проверяет, что запуск завершается ошибкой, если исполнитель
+// не объявил в ready назначенный ему тип задач
+func TestProcess_MissingType(t *testing.T) {
+	self, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := NewProcess(ProcessConfig{
+		Argv: []string{self}, Env: append(os.Environ(), "EXECUTOR_TEST_SERVE=1"),
+		Types: []string{"proc-ok", "proc-missing"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	if err := p.Start(context.Background()); err == nil || !strings.Contains(err.Error(), "proc-missing") {
+		t.Errorf("ожидалась ошибка о типе proc-missing, получили %v", err)
+	}
+
+	p, err = NewProcess(ProcessConfig{
+		Argv: []string{self}, Env: append(os.Environ(), "EXECUTOR_TEST_SERVE=1"),
+		Types: []string{"proc-ok", "proc-wait"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	if err := p.Start(context.Background()); err != nil {
+		t.Errorf("Start: %v", err)
+	}
+}
@@ -0,0 +1,97 @@
+package executor
+
+// Протокол внешних исполнителей (версия 1).
+//
+// Сервис запускает процесс исполнителя и обменивается с ним сообщениями через stdin/stdout:
+// каждое сообщение - JSON-объект в одной строке (UTF-8, не длиннее MaxMessageSize).
+// stderr исполнителя пишется в журнал сервиса и для протокола не используется.
+//
+//	исполнитель → сервис  {"type":"ready","protocol":1,"task_types":["report"]}
+//	сервис → исполнитель  {"type":"execute","id":"<uuid>","task":{"id":"<uuid>","type":"report","payload":{...}}}
+//	исполнитель → сервис  {"type":"log","id":"<uuid>","message":"..."}
+//	исполнитель → сервис  {"type":"progress","id":"<uuid>","message":"..."}
+//	сервис → исполнитель  {"type":"cancel","id":"<uuid>"}
+//	исполнитель → сервис  {"type":"result","id":"<uuid>","result":"...","error":"..."}
+//
+// Правила:
+//   - ready - первое сообщение исполнителя; сервис не отправляет задачи до него и завершает
+//     процесс, если в task_types нет какого-либо из назначенных ему типов;
+//   - id в execute свой у каждой попытки обработки; задачу определяет task.id;
+//   - исполнитель принимает новые execute, не дожидаясь завершения предыдущих;
+//   - на каждый execute исполнитель отвечает ровно одним result с тем же id, в том числе
+//     для неизвестного типа задачи и после cancel; непустое error означает неудачу;
+//   - log и progress допустимы только между execute и result; progress дополнительно
+//     попадает в историю задачи;
+//   - cancel для задачи без обработки (неизвестный id или уже отправленный result) игнорируется;
+//   - закрытие stdin означает остановку: исполнитель прекращает обработку и завершается.
+
+import (
+	"encoding/json"
+
+	"workmateTestProject/internal/model"
+)
+
+// ProtocolVersion - версия протокола внешних исполнителей
+const ProtocolVersion = 1
+
+// MaxMessageSize - наибольшая длина строки сообщения протокола
+const MaxMessageSize = 1 << 20
+
+// Типы сообщений протокола
+const (
+	MsgReady    = "ready"
+	MsgExecute  = "execute"
+	MsgCancel   = "cancel"
+	MsgLog      = "log"
+	MsgProgress = "progress"
+	MsgResult   = "result"
+)
+
+// Message - сообщение протокола внешних исполнителей
+type Message struct {
+	Type string `json:"type"`
+	// ID - идентификатор вызова execute, к которому относится сообщение. У каждой попытки
+	// обработки задачи он свой; идентификатор самой задачи передаётся в task.id.
+	ID string `json:"id,omitempty"`
+	// Protocol и TaskTypes - в сообщении ready
+	Protocol  int      `json:"protocol,omitempty"`
+	TaskTypes []string `json:"task_types,omitempty"`
+	// Task - в сообщении execute
+	Task *TaskSpec `json:"task,omitempty"`
+	// Message - в сообщениях log и progress
+	Message string `json:"message,omitempty"`
+	// Result и Error - в сообщении result
+	Result string `json:"result,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// TaskSpec - описание задачи, передаваемое внешнему исполнителю
+type TaskSpec struct {
+	ID        string            `json:"id"`
+	Tenant    string            `json:"tenant,omitempty"`
+	Type      string            `json:"type"`
+	Priority  int               `json:"priority,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	Timeout   model.Duration    `json:"timeout,omitempty"`
+	Payload   json.RawMessage   `json:"payload,omitempty"`
+	CreatedBy string            `json:"created_by,omitempty"`
+	ParentID  string            `json:"parent_id,omitempty"`
+}
+
+// taskSpec описывает задачу для внешнего исполнителя
+func taskSpec(task *model.Task) *TaskSpec {
+	spec := &TaskSpec{
+		ID:        task.ID.String(),
+		Tenant:    task.Tenant,
+		Type:      task.Type,
+		Priority:  task.Priority,
+		Labels:    task.Labels,
+		Timeout:   task.Timeout,
+		Payload:   task.Payload,
+		CreatedBy: task.CreatedBy,
+	}
+	if task.ParentID != nil {
+		spec.ParentID = task.ParentID.String()
+	}
+	return spec
+}
@@ -0,0 +1,95 @@
+package executor
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Reporter передаёт сервису журнал и ход обработки задачи из внешнего исполнителя
+type Reporter struct {
+	id   string
+	send func(Message)
+}
+
+// Logf пишет строку в журнал задачи
+func (r *Reporter) Logf(format string, args ...any) {
+	r.send(Message{Type: MsgLog, ID: r.id, Message: fmt.Sprintf(format, args...)})
+}
+
+// Progressf сообщает о ходе обработки: строка попадает в журнал и историю задачи
+func (r *Reporter) Progressf(format string, args ...any) {
+	r.send(Message{Type: MsgProgress, ID: r.id, Message: fmt.Sprintf(format, args...)})
+}
+
+// Handler обрабатывает задачу во внешнем исполнителе. ctx отменяется по cancel от сервиса
+// и при закрытии stdin.
+type Handler func(ctx context.Context, task *TaskSpec, r *Reporter) (string, error)
+
+// Serve реализует сторону исполнителя в протоколе внешних исполнителей: отправляет ready
+// с types, выполняет каждую задачу в отдельной горутине и отвечает result. Возвращается после
+// закрытия in, отменив и дождавшись выполняющихся задач.
+func Serve(in io.Reader, out io.Writer, types []string, h Handler) error {
+	var wmu sync.Mutex
+	enc := json.NewEncoder(out)
+	send := func(msg Message) {
+		wmu.Lock()
+		defer wmu.Unlock()
+		enc.Encode(msg)
+	}
+	send(Message{Type: MsgReady, Protocol: ProtocolVersion, TaskTypes: types})
+
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	var (
+		mu      sync.Mutex
+		running = make(map[string]context.CancelFunc)
+		wg      sync.WaitGroup
+	)
+	sc := bufio.NewScanner(in)
+	sc.Buffer(make([]byte, 0, 64<<10), MaxMessageSize)
+	for sc.Scan() {
+		var msg Message
+		if err := json.Unmarshal(sc.Bytes(), &msg); err != nil {
+			continue
+		}
+		switch msg.Type {
+		case MsgExecute:
+			if msg.Task == nil {
+				send(Message{Type: MsgResult, ID: msg.ID, Error: "в сообщении execute нет задачи"})
+				continue
+			}
+			taskCtx, cancel := context.WithCancel(ctx)
+			mu.Lock()
+			running[msg.ID] = cancel
+			mu.Unlock()
+			wg.Add(1)
+			go func(id string, task *TaskSpec) {
+				defer wg.Done()
+				res := Message{Type: MsgResult, ID: id}
+				result, err := h(taskCtx, task, &Reporter{id: id, send: send})
+				res.Result = result
+				if err != nil {
+					res.Error = err.Error()
+				}
+				mu.Lock()
+				delete(running, id)
+				mu.Unlock()
+				cancel()
+				send(res)
+			}(msg.ID, msg.Task)
+		case MsgCancel:
+			mu.Lock()
+			if cancel, ok := running[msg.ID]; ok {
+				cancel()
+			}
+			mu.Unlock()
+		}
+	}
+	stop()
+	wg.Wait()
+	return sc.Err()
+}
@@ -0,0 +1,406 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"workmateTestProject/internal/model"
+	"workmateTestProject/internal/service"
+)
+
+// ErrUnknownCommand возвращается для команд, не входящих в список разрешённых
+var ErrUnknownCommand = errors.New("команда не входит в список разрешённых")
+
+const (
+	// defaultParamPattern - допустимые значения параметра, для которого шаблон не задаёт pattern.
+	// Значение не может начинаться с - (иначе команда примет его за флаг) и с / (абсолютный путь).
+	defaultParamPattern = `(?:[\w.,:@+=][\w.,:/@+=-]{0,255})?`
+	// defaultKillGrace - сколько ждать завершения группы процессов после SIGTERM до SIGKILL
+	defaultKillGrace = 5 * time.Second
+	// defaultPath - PATH команды, если шаблон не задаёт свой
+	defaultPath = "/usr/local/bin:/usr/bin:/bin"
+	// maxLogLine - строки вывода длиннее этого разбиваются на несколько строк журнала
+	maxLogLine = 4096
+)
+
+// placeholder - подстановка параметра в аргумент или переменную окружения: {{name}}
+var placeholder = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+
+// ShellParam - параметр шаблона команды
+type ShellParam struct {
+	// Pattern - регулярное выражение, которому должно целиком соответствовать значение;
+	// по умолчанию буквы, цифры и символы .,:/@+=- длиной до 256, не начинающиеся с - или /
+	// и без сегментов пути ..
+	Pattern string `json:"pattern,omitempty"`
+	// Default - значение по умолчанию; параметр без значения по умолчанию обязателен
+	Default *string `json:"default,omitempty"`
+
+	re *regexp.Regexp
+	// noDotDot - отклонять значения с сегментом пути .. (шаблон по умолчанию)
+	noDotDot bool
+}
+
+// valid сообщает, допустимо ли значение параметра
+func (p *ShellParam) valid(v string) bool {
+	if !p.re.MatchString(v) {
+		return false
+	}
+	if p.noDotDot {
+		for _, seg := range strings.Split(v, "/") {
+			if seg == ".." {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// ShellLimits - ограничения ресурсов процесса (rlimit); нулевое значение - без ограничения
+type ShellLimits struct {
+	CPUSeconds int64 `json:"cpu_seconds,omitempty"`
+	MemoryMB   int64 `json:"memory_mb,omitempty"`
+	OpenFiles  int64 `json:"open_files,omitempty"`
+	FileSizeMB int64 `json:"file_size_mb,omitempty"`
+}
+
+// ShellCommand - шаблон команды, зарегистрированный администратором. Задача выбирает шаблон
+// по имени и передаёт только значения параметров; команда запускается без оболочки,
+// поэтому значение параметра всегда остаётся одним аргументом.
+type ShellCommand struct {
+	Name string `json:"name"`
+	// Argv - исполняемый файл (абсолютный путь) и аргументы с подстановками {{param}}
+	Argv   []string              `json:"argv"`
+	Params map[string]ShellParam `json:"params,omitempty"`
+	// Dir - рабочий каталог; по умолчанию каталог сервиса
+	Dir string `json:"dir,omitempty"`
+	// Env - переменные окружения с подстановками {{param}}; окружение сервиса не наследуется
+	Env    map[string]string `json:"env,omitempty"`
+	Limits ShellLimits       `json:"limits,omitempty"`
+}
+
+// ShellRequest - payload задачи shell-исполнителя
+type ShellRequest struct {
+	Command string            `json:"command"`
+	Params  map[string]string `json:"params,omitempty"`
+}
+
+// ShellResult - результат задачи shell-исполнителя
+type ShellResult struct {
+	Command  string `json:"command"`
+	ExitCode int    `json:"exit_code"`
+	// Signal - сигнал, которым был завершён процесс (exit_code при этом -1)
+	Signal string `json:"signal,omitempty"`
+}
+
+// ShellConfig - настройки shell-исполнителя
+type ShellConfig struct {
+	Commands []ShellCommand
+	// KillGrace - сколько ждать после SIGTERM при отмене до SIGKILL всей группы процессов
+	KillGrace time.Duration
+	// Shell - оболочка, через которую устанавливаются ограничения ресурсов; по умолчанию /bin/sh
+	Shell string
+}
+
+// Shell выполняет команды из списка разрешённых шаблонов. Вывод команды построчно пишется
+// в журнал задачи (stderr - с префиксом), код завершения - в результат. Команда запускается
+// в собственной группе процессов, которая целиком завершается при отмене задачи.
+type Shell struct {
+	commands  map[string]*ShellCommand
+	killGrace time.Duration
+	shell     string
+}
+
+// NewShell проверяет шаблоны команд и создаёт shell-исполнитель
+func NewShell(cfg ShellConfig) (*Shell, error) {
+	e := &Shell{commands: make(map[string]*ShellCommand), killGrace: cfg.KillGrace, shell: cfg.Shell}
+	if e.killGrace <= 0 {
+		e.killGrace = defaultKillGrace
+	}
+	if e.shell == "" {
+		e.shell = "/bin/sh"
+	}
+	for i := range cfg.Commands {
+		c := cfg.Commands[i]
+		if err := c.compile(); err != nil {
+			return nil, fmt.Errorf("команда %q: %w", c.Name, err)
+		}
+		if _, dup := e.commands[c.Name]; dup {
+			return nil, fmt.Errorf("команда %q описана дважды", c.Name)
+		}
+		e.commands[c.Name] = &c
+	}
+	return e, nil
+}
+
+// LoadShellCommands читает шаблоны команд из JSON-файла (массив ShellCommand)
+func LoadShellCommands(path string) ([]ShellCommand, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var commands []ShellCommand
+	if err := json.Unmarshal(data, &commands); err != nil {
+		return nil, fmt.Errorf("разбор %s: %w", path, err)
+	}
+	return commands, nil
+}
+
+// Commands возвращает имена разрешённых команд
+func (e *Shell) Commands() []string {
+	names := make([]string, 0, len(e.commands))
+	for name := range e.commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// compile проверяет шаблон и компилирует шаблоны параметров
+func (c *ShellCommand) compile() error {
+	if c.Name == "" {
+		return errors.New("не указано имя")
+	}
+	if len(c.Argv) == 0 || !filepath.IsAbs(c.Argv[0]) || placeholder.MatchString(c.Argv[0]) {
+		return errors.New("argv[0] должен быть абсолютным путём без подстановок")
+	}
+	params := make(map[string]ShellParam, len(c.Params))
+	for name, p := range c.Params {
+		pattern := p.Pattern
+		if pattern == "" {
+			pattern, p.noDotDot = defaultParamPattern, true
+		}
+		re, err := regexp.Compile(`^(?:` + pattern + `)$`)
+		if err != nil {
+			return fmt.Errorf("параметр %s: %v", name, err)
+		}
+		p.re = re
+		if p.Default != nil && !p.valid(*p.Default) {
+			return fmt.Errorf("параметр %s: значение по умолчанию не соответствует шаблону", name)
+		}
+		params[name] = p
+	}
+	c.Params = params
+	check := func(s string) error {
+		for _, m := range placeholder.FindAllStringSubmatch(s, -1) {
+			if _, ok := c.Params[m[1]]; !ok {
+				return fmt.Errorf("подстановка {{%s}} не описана в params", m[1])
+			}
+		}
+		return nil
+	}
+	for _, arg := range c.Argv {
+		if err := check(arg); err != nil {
+			return err
+		}
+	}
+	for k, v := range c.Env {
+		if k == "" || strings.ContainsAny(k, "=\x00") {
+			return fmt.Errorf("неверное имя переменной окружения %q", k)
+		}
+		if err := check(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parse разбирает payload задачи и возвращает шаблон с проверенными значениями параметров
+func (e *Shell) parse(task *model.Task) (*ShellCommand, map[string]string, error) {
+	var req ShellRequest
+	if err := json.Unmarshal(task.Payload, &req); err != nil {
+		return nil, nil, fmt.Errorf("payload: ожидался объект с command и params: %v", err)
+	}
+	c, ok := e.commands[req.Command]
+	if !ok {
+		return nil, nil, fmt.Errorf("payload.command: %w: %q", ErrUnknownCommand, req.Command)
+	}
+	for name := range req.Params {
+		if _, ok := c.Params[name]; !ok {
+			return nil, nil, fmt.Errorf("payload.params: неизвестный параметр %s", name)
+		}
+	}
+	values := make(map[string]string, len(c.Params))
+	for name, p := range c.Params {
+		v, ok := req.Params[name]
+		switch {
+		case ok:
+		case p.Default != nil:
+			v = *p.Default
+		default:
+			return nil, nil, fmt.Errorf("payload.params: не указан обязательный параметр %s", name)
+		}
+		if !p.valid(v) {
+			return nil, nil, fmt.Errorf("payload.params.%s: недопустимое значение", name)
+		}
+		values[name] = v
+	}
+	return c, values, nil
+}
+
+// ValidatePayload проверяет payload при создании и изменении задачи
+func (e *Shell) ValidatePayload(task *model.Task) error {
+	_, _, err := e.parse(task)
+	return err
+}
+
+// expand подставляет значения параметров
+func expand(s string, values map[string]string) string {
+	return placeholder.ReplaceAllStringFunc(s, func(m string) string {
+		return values[placeholder.FindStringSubmatch(m)[1]]
+	})
+}
+
+// command собирает процесс по шаблону. Ограничения ресурсов устанавливаются встроенной
+// командой ulimit оболочки, которая затем заменяется командой через exec "$@".
+func (e *Shell) command(c *ShellCommand, values map[string]string) (*exec.Cmd, error) {
+	argv := make([]string, len(c.Argv))
+	for i, arg := range c.Argv {
+		argv[i] = expand(arg, values)
+	}
+	if limits := c.Limits.ulimit(); limits != "" {
+		if err := checkLimitsSupported(); err != nil {
+			return nil, err
+		}
+		argv = append([]string{e.shell, "-c", limits + `exec "$@"`, "sh"}, argv...)
+	}
+	cmd := exec.Command(argv[0], argv[1:]...)
+	cmd.Dir = c.Dir
+	cmd.Env = []string{"PATH=" + defaultPath}
+	for k, v := range c.Env {
+		cmd.Env = append(cmd.Env, k+"="+expand(v, values))
+	}
+	return cmd, nil
+}
+
+// ulimit возвращает команды оболочки, устанавливающие ограничения (мягкие и жёсткие сразу)
+func (l ShellLimits) ulimit() string {
+	var b strings.Builder
+	for _, lim := range []struct {
+		flag  string
+		value int64
+	}{
+		{"-t", l.CPUSeconds},
+		{"-v", l.MemoryMB << 10},   // КиБ
+		{"-n", l.OpenFiles},        // дескрипторы
+		{"-f", l.FileSizeMB << 11}, // блоки по 512 байт
+	} {
+		if lim.value > 0 {
+			fmt.Fprintf(&b, "ulimit %s %d || exit 126; ", lim.flag, lim.value)
+		}
+	}
+	return b.String()
+}
+
+// Execute выполняет команду задачи. Результат - ShellResult в JSON; ненулевой код завершения
+// возвращается вместе с результатом как ошибка.
+func (e *Shell) Execute(ctx context.Context, task *model.Task) (string, error) {
+	c, values, err := e.parse(task)
+	if err != nil {
+		return "", err
+	}
+	cmd, err := e.command(c, values)
+	if err != nil {
+		return "", err
+	}
+	stdout := &lineWriter{ctx: ctx}
+	stderr := &lineWriter{ctx: ctx, prefix: "stderr: "}
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	startGroup(cmd)
+
+	service.Logf(ctx, "Запуск команды %s", c.Name)
+	start := time.Now()
+	if err := cmd.Start(); err != nil {
+		return "", fmt.Errorf("запуск команды %s: %w", c.Name, err)
+	}
+	// Отмена: SIGTERM всей группе, через KillGrace - SIGKILL; группа добивается и после
+	// обычного завершения, чтобы команда не оставляла фоновых процессов
+	exited := make(chan struct{})
+	stop := context.AfterFunc(ctx, func() {
+		terminateGroup(cmd)
+		select {
+		case <-exited:
+		case <-time.After(e.killGrace):
+			killGroup(cmd)
+		}
+	})
+	cmd.WaitDelay = e.killGrace
+	err = cmd.Wait()
+	close(exited)
+	stop()
+	killGroup(cmd)
+	stdout.flush()
+	stderr.flush()
+
+	res := ShellResult{Command: c.Name, ExitCode: cmd.ProcessState.ExitCode(), Signal: exitSignal(cmd.ProcessState)}
+	service.Logf(ctx, "Команда %s завершилась с кодом %d за %s", c.Name, res.ExitCode, time.Since(start).Round(time.Millisecond))
+	out, jerr := json.Marshal(res)
+	if jerr != nil {
+		return "", jerr
+	}
+	switch {
+	case ctx.Err() != nil:
+		return string(out), context.Cause(ctx)
+	case res.Signal != "":
+		return string(out), fmt.Errorf("команда %s завершена сигналом %s", c.Name, res.Signal)
+	case res.ExitCode != 0:
+		return string(out), fmt.Errorf("команда %s завершилась с кодом %d", c.Name, res.ExitCode)
+	case err != nil:
+		// Код 0, но вывод не дочитан: фоновые процессы держали stdout дольше KillGrace
+		return string(out), fmt.Errorf("команда %s: %w", c.Name, err)
+	}
+	return string(out), nil
+}
+
+// lineWriter построчно пишет вывод команды в журнал задачи
+type lineWriter struct {
+	ctx    context.Context
+	prefix string
+	mu     sync.Mutex
+	buf    []byte
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			if len(w.buf) < maxLogLine {
+				break
+			}
+			i = maxLogLine
+			w.log(w.buf[:i])
+			w.buf = w.buf[i:]
+			continue
+		}
+		w.log(w.buf[:i])
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// flush пишет в журнал незавершённую последнюю строку
+func (w *lineWriter) flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.buf) > 0 {
+		w.log(w.buf)
+		w.buf = nil
+	}
+}
+
+func (w *lineWriter) log(line []byte) {
+	service.Logf(w.ctx, "%s%s", w.prefix, strings.TrimSuffix(string(line), "\r"))
+}
@@ -0,0 +1,28 @@
+//go:build !unix
+
+package executor
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+)
+
+// startGroup: группы процессов не поддерживаются, завершается только сама команда
+func startGroup(cmd *exec.Cmd) {}
+
+func terminateGroup(cmd *exec.Cmd) {
+	cmd.Process.Kill()
+}
+
+func killGroup(cmd *exec.Cmd) {
+	cmd.Process.Kill()
+}
+
+func exitSignal(state *os.ProcessState) string {
+	return ""
+}
+
+func checkLimitsSupported() error {
+	return errors.New("ограничения ресурсов поддерживаются только в Unix")
+}
@@ -0,0 +1,198 @@
+//go:build unix
+
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"workmateTestProject/internal/model"
+	"workmateTestProject/internal/service"
+)
+
+// testCommands - шаблоны для тестов; значения параметров передаются оболочке позиционными аргументами
+func testCommands(t *testing.T) *Shell {
+	t.Helper()
+	zero := "0"
+	e, err := NewShell(ShellConfig{KillGrace: 200 * time.Millisecond, Commands: []ShellCommand{
+		{
+			Name: "echo",
+			Argv: []string{"/bin/sh", "-c", `echo "$1"; echo "dir=$(pwd) secret=$SECRET env=$GREETING"; echo oops >&2; ulimit -n; exit "$2"`, "sh", "{{msg}}", "{{code}}"},
+			Params: map[string]ShellParam{
+				"msg":  {Pattern: `[^\n]{0,64}`},
+				"code": {Pattern: `[0-9]{1,3}`, Default: &zero},
+			},
+			Dir:    "/tmp",
+			Env:    map[string]string{"GREETING": "hi {{msg}}"},
+			Limits: ShellLimits{OpenFiles: 64},
+		},
+		{
+			Name: "hang",
+			Argv: []string{"/bin/sh", "-c", `trap "" TERM; sleep 30 & echo "child=$!"; wait`},
+		},
+	}})
+	if err != nil {
+		t.Fatalf("NewShell: %v", err)
+	}
+	return e
+}
+
+// runShellTask выполняет задачу через сервис и возвращает её историю и журнал
+func runShellTask(t *testing.T, e *Shell, payload string, cancelAfter time.Duration) (*model.Task, []string) {
+	t.Helper()
+	service.RegisterExecutor("shell-test", e)
+	defer service.RegisterExecutor("shell-test", nil)
+
+	task := &model.Task{ID: uuid.New(), Type: "shell-test", Status: model.StatusPending, Payload: json.RawMessage(payload)}
+	if err := service.ValidateTask(task); err != nil {
+		t.Fatalf("ValidateTask: %v", err)
+	}
+	if err := service.StartProcessing(task); err != nil {
+		t.Fatalf("StartProcessing: %v", err)
+	}
+	if cancelAfter > 0 {
+		time.Sleep(cancelAfter)
+		service.Cancel(context.Background(), task.ID, "alice")
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for service.Active(task.ID) {
+		if time.Now().After(deadline) {
+			t.Fatal("задача не завершилась")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	buf, _ := service.TaskLogs(task.ID)
+	lines, _ := buf.Lines(0, 0)
+	var msgs []string
+	for _, l := range lines {
+		msgs = append(msgs, l.Message)
+	}
+	return task, msgs
+}
+
+// TestShell_Execute проверяет подстановку параметров без оболочки, окружение, рабочий каталог,
+// ограничения ресурсов, запись вывода в журнал и код завершения в результате
+func TestShell_Execute(t *testing.T) {
+	t.Setenv("SECRET", "leaked")
+	e := testCommands(t)
+
+	task, logs := runShellTask(t, e, `{"command":"echo","params":{"msg":"a; rm -rf / $(id)","code":"3"}}`, 0)
+	joined := strings.Join(logs, "\n")
+	for _, want := range []string{"\na; rm -rf / $(id)\n", "dir=/tmp secret= env=hi a; rm -rf / $(id)", "stderr: oops", "\n64\n"} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("в журнале нет %q:\n%s", want, joined)
+		}
+	}
+	if task.Status != model.StatusFailed || task.Result != `{"command":"echo","exit_code":3}` {
+		t.Errorf("ожидался Failed с кодом 3, получили %s %q %q", task.Status, task.Result, task.Error)
+	}
+
+	task, _ = runShellTask(t, e, `{"command":"echo","params":{"msg":"ok"}}`, 0)
+	if task.Status != model.StatusCompleted || task.Result != `{"command":"echo","exit_code":0}` {
+		t.Errorf("ожидался Completed, получили %s %q %q", task.Status, task.Result, task.Error)
+	}
+
+	for _, payload := range []string{
+		`{"command":"rm","params":{}}`,
+		`{"command":"echo"}`,
+		`{"command":"echo","params":{"msg":"x","code":"1; reboot"}}`,
+		`{"command":"echo","params":{"msg":"x","extra":"1"}}`,
+	} {
+		if err := e.ValidatePayload(&model.Task{Payload: json.RawMessage(payload)}); err == nil {
+			t.Errorf("payload %s должен быть отклонён", payload)
+		}
+	}
+	err := e.ValidatePayload(&model.Task{Payload: json.RawMessage(`{"command":"ls"}`)})
+	if !errors.Is(err, ErrUnknownCommand) {
+		t.Errorf("ожидалась ErrUnknownCommand, получили %v", err)
+	}
+}
+
+// TestShell_CancelKillsGroup проверяет, что отмена завершает всю группу процессов,
+// в том числе игнорирующие SIGTERM фоновые процессы
+func TestShell_CancelKillsGroup(t *testing.T) {
+	e := testCommands(t)
+	task, logs := runShellTask(t, e, `{"command":"hang"}`, 300*time.Millisecond)
+	if task.Status != model.StatusCanceled {
+		t.Fatalf("ожидался Canceled, получили %s (%s)", task.Status, task.Error)
+	}
+	var child int
+	for _, l := range logs {
+		if strings.HasPrefix(l, "child=") {
+			json.Unmarshal([]byte(strings.TrimPrefix(l, "child=")), &child)
+		}
+	}
+	if child == 0 {
+		t.Fatalf("в журнале нет pid фонового процесса: %v", logs)
+	}
+	// Завершённый процесс может остаться зомби, если init в контейнере не забирает сирот
+	deadline := time.Now().Add(time.Second)
+	for {
+		stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", child))
+		if err == nil && !strings.Contains(string(stat), ") Z ") {
+			if time.Now().After(deadline) {
+				t.Fatalf("фоновый процесс %d не завершён: %s", child, stat)
+			}
+			time.Sleep(10 * time.Millisecond)
+			continue
+		}
+		if err != nil && syscall.Kill(child, 0) == nil {
+			t.Fatalf("фоновый процесс %d не завершён", child)
+		}
+		break
+	}
+}
+
+// TestNewShell_Validation проверяет отклонение небезопасных шаблонов
+func TestNewShell_Validation(t *testing.T) {
+	for name, c := range map[string]ShellCommand{
+		"относительный путь":    {Name: "a", Argv: []string{"backup.sh"}},
+		"подстановка в argv[0]": {Name: "b", Argv: []string{"/{{bin}}"}, Params: map[string]ShellParam{"bin": {}}},
+		"неописанный параметр":  {Name: "c", Argv: []string{"/bin/echo", "{{x}}"}},
+		"неверный шаблон":       {Name: "d", Argv: []string{"/bin/echo", "{{x}}"}, Params: map[string]ShellParam{"x": {Pattern: "("}}},
+	} {
+		if _, err := NewShell(ShellConfig{Commands: []ShellCommand{c}}); err == nil {
+			t.Errorf("%s: шаблон должен быть отклонён", name)
+		}
+	}
+}
+
+// TestShell_DefaultParamPattern проверяет, что шаблон параметра по умолчанию не пропускает
+// значения, которые команда примет за флаг, и выход из каталога через ..
+func TestShell_DefaultParamPattern(t *testing.T) {
+	up := "../etc"
+	if _, err := NewShell(ShellConfig{Commands: []ShellCommand{{
+		Name: "cat", Argv: []string{"/bin/cat", "{{file}}"}, Params: map[string]ShellParam{"file": {Default: &up}},
+	}}}); err == nil {
+		t.Error("значение по умолчанию с .. должно быть отклонено")
+	}
+
+	e, err := NewShell(ShellConfig{Commands: []ShellCommand{{
+		Name: "cat", Argv: []string{"/bin/cat", "{{file}}"}, Params: map[string]ShellParam{"file": {}},
+	}}})
+	if err != nil {
+		t.Fatalf("NewShell: %v", err)
+	}
+	validate := func(file string) error {
+		payload, _ := json.Marshal(ShellRequest{Command: "cat", Params: map[string]string{"file": file}})
+		return e.ValidatePayload(&model.Task{Payload: payload})
+	}
+	for _, file := range []string{"-n", "--help", "-", "/etc/passwd", "..", "../etc/passwd", "logs/../../etc", "logs/.."} {
+		if err := validate(file); err == nil {
+			t.Errorf("значение %q должно быть отклонено", file)
+		}
+	}
+	for _, file := range []string{"", "report.txt", "logs/2025-01-01.log", "a-b", "v1..v2", ".hidden", "user@host:path"} {
+		if err := validate(file); err != nil {
+			t.Errorf("значение %q должно быть допустимо: %v", file, err)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+//go:build unix
+
+package executor
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// startGroup запускает команду в собственной группе процессов
+func startGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// terminateGroup просит все процессы группы завершиться
+func terminateGroup(cmd *exec.Cmd) {
+	syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM)
+}
+
+// killGroup принудительно завершает все процессы группы
+func killGroup(cmd *exec.Cmd) {
+	syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
+
+// exitSignal возвращает имя сигнала, которым был завершён процесс
+func exitSignal(state *os.ProcessState) string {
+	if ws, ok := state.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		return ws.Signal().String()
+	}
+	return ""
+}
+
+// checkLimitsSupported сообщает, можно ли ограничить ресурсы команды
+func checkLimitsSupported() error {
+	return nil
+}
@@ -0,0 +1,142 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// EventType - тип события в истории задачи
+type EventType string
+
+const (
+	// EventCreated - задача создана; событие содержит снимок начального состояния
+	EventCreated EventType = "created"
+	// EventScheduled - задача ожидает наступления RunAt
+	EventScheduled EventType = "scheduled"
+	// EventQueued - задача поставлена в очередь на обработку
+	EventQueued EventType = "queued"
+	// EventUpdated - параметры ожидающей задачи изменены; событие содержит merge patch
+	EventUpdated EventType = "updated"
+	// EventStarted - обработка задачи начата
+	EventStarted EventType = "started"
+	// EventProgress - промежуточное сообщение обработчика или новый артефакт
+	EventProgress EventType = "progress"
+	// EventRetried - задача возвращена в состояние Pending для повторной обработки
+	EventRetried EventType = "retried"
+	// EventCanceled - задача отменена по запросу или вытеснена из очереди
+	EventCanceled EventType = "canceled"
+	// EventInterrupted - обработка прервана при остановке сервиса
+	EventInterrupted EventType = "interrupted"
+	// EventCompleted - обработка завершена успешно
+	EventCompleted EventType = "completed"
+	// EventFailed - обработка завершена с ошибкой
+	EventFailed EventType = "failed"
+	// EventDeleted - задача мягко удалена
+	EventDeleted EventType = "deleted"
+	// EventRestored - задача восстановлена после мягкого удаления или из архива
+	EventRestored EventType = "restored"
+)
+
+// ActorSystem - инициатор событий, которые сервис порождает сам
+const ActorSystem = "system"
+
+// Event - событие в истории задачи. Состояние задачи получается сверткой её событий (Fold).
+type Event struct {
+	// Seq - номер события в истории задачи, начиная с 1
+	Seq   int64     `json:"seq"`
+	Type  EventType `json:"type"`
+	Time  time.Time `json:"time"`
+	Actor string    `json:"actor,omitempty"`
+	// Message - пояснение к событию (например, сообщение о ходе обработки)
+	Message  string    `json:"message,omitempty"`
+	Result   string    `json:"result,omitempty"`
+	Error    string    `json:"error,omitempty"`
+	Artifact *Artifact `json:"artifact,omitempty"`
+	// Patch - JSON Merge Patch события updated
+	Patch json.RawMessage `json:"patch,omitempty"`
+	// Task - снимок задачи, заменяющий накопленное состояние: при создании, загрузке
+	// из выгрузки или восстановлении из архива, когда предыдущая история недоступна
+	Task *Task `json:"task,omitempty"`
+}
+
+// ErrNoSnapshot возвращается Fold, если история не начинается со снимка задачи
+var ErrNoSnapshot = errors.New("история задачи не начинается со снимка")
+
+// Clone возвращает копию задачи, не разделяющую с ней изменяемые поля
+func (t *Task) Clone() *Task {
+	c := *t
+	c.Artifacts = append([]Artifact(nil), t.Artifacts...)
+	c.Payload = append(json.RawMessage(nil), t.Payload...)
+	if t.Labels != nil {
+		c.Labels = make(map[string]string, len(t.Labels))
+		for k, v := range t.Labels {
+			c.Labels[k] = v
+		}
+	}
+	return &c
+}
+
+// Apply применяет событие к задаче и увеличивает её версию
+func (t *Task) Apply(e Event) {
+	if e.Task != nil {
+		*t = *e.Task.Clone()
+	}
+	at := e.Time
+	switch e.Type {
+	case EventScheduled:
+		t.Status = StatusScheduled
+	case EventQueued:
+		t.Status = StatusPending
+	case EventUpdated:
+		// Патч проверяется до записи события, поэтому ошибка здесь невозможна
+		_ = t.ApplyMergePatch(e.Patch)
+	case EventStarted:
+		t.Status = StatusInProgress
+		t.StartedAt = &at
+	case EventProgress:
+		if e.Artifact != nil {
+			t.putArtifact(*e.Artifact)
+		}
+	case EventRetried:
+		t.ResetForRequeue()
+	case EventCanceled, EventInterrupted, EventCompleted, EventFailed:
+		t.Status = map[EventType]TaskStatus{
+			EventCanceled:    StatusCanceled,
+			EventInterrupted: StatusInterrupted,
+			EventCompleted:   StatusCompleted,
+			EventFailed:      StatusFailed,
+		}[e.Type]
+		t.FinishedAt = &at
+		t.Result = e.Result
+		t.Error = e.Error
+	case EventDeleted:
+		t.DeletedAt = &at
+	case EventRestored:
+		t.DeletedAt = nil
+	}
+	t.Version++
+}
+
+// putArtifact добавляет артефакт или заменяет артефакт с тем же именем
+func (t *Task) putArtifact(a Artifact) {
+	for i := range t.Artifacts {
+		if t.Artifacts[i].Name == a.Name {
+			t.Artifacts[i] = a
+			return
+		}
+	}
+	t.Artifacts = append(t.Artifacts, a)
+}
+
+// Fold восстанавливает состояние задачи по её истории
+func Fold(events []Event) (*Task, error) {
+	if len(events) == 0 || events[0].Task == nil {
+		return nil, ErrNoSnapshot
+	}
+	task := &Task{}
+	for _, e := range events {
+		task.Apply(e)
+	}
+	return task, nil
+}
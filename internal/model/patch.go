@@ -0,0 +1,153 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// patchableFields - поля задачи, которые можно изменить через ApplyMergePatch
+var patchableFields = map[string]bool{
+	"priority": true,
+	"labels":   true,
+	"run_at":   true,
+	"timeout":  true,
+	"payload":  true,
+}
+
+// ApplyMergePatch изменяет параметры задачи по JSON Merge Patch (RFC 7386).
+// Изменять можно priority, labels, run_at, timeout и payload; null сбрасывает поле,
+// в labels и payload объекты объединяются рекурсивно. Задача изменяется, только если патч разобран целиком;
+// проверка значений (Validate) остаётся за вызывающим.
+func (t *Task) ApplyMergePatch(patch []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(patch, &fields); err != nil || fields == nil {
+		return fmt.Errorf("патч должен быть JSON-объектом")
+	}
+	var unknown []string
+	for name := range fields {
+		if !patchableFields[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("нельзя изменить поля: %s", strings.Join(unknown, ", "))
+	}
+
+	// Изменения собираются в копии, чтобы ошибка в одном поле не оставила задачу изменённой наполовину
+	c := t.Clone()
+	for name, raw := range fields {
+		null := string(raw) == "null"
+		var err error
+		switch name {
+		case "priority":
+			c.Priority = 0
+			if !null {
+				err = json.Unmarshal(raw, &c.Priority)
+			}
+		case "timeout":
+			c.Timeout = 0
+			if !null {
+				err = json.Unmarshal(raw, &c.Timeout)
+			}
+		case "run_at":
+			c.RunAt = nil
+			if !null {
+				err = json.Unmarshal(raw, &c.RunAt)
+			}
+		case "labels":
+			err = c.patchLabels(raw)
+		case "payload":
+			c.Payload, err = mergeJSON(c.Payload, raw)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	*t = *c
+	return nil
+}
+
+// patchLabels объединяет метки с патчем: null удаляет метку или все метки
+func (t *Task) patchLabels(raw json.RawMessage) error {
+	if string(raw) == "null" {
+		t.Labels = nil
+		return nil
+	}
+	var patch map[string]*string
+	if err := json.Unmarshal(raw, &patch); err != nil {
+		return fmt.Errorf("ожидался объект со строковыми значениями")
+	}
+	for k, v := range patch {
+		if v == nil {
+			delete(t.Labels, k)
+			continue
+		}
+		if t.Labels == nil {
+			t.Labels = make(map[string]string)
+		}
+		t.Labels[k] = *v
+	}
+	if len(t.Labels) == 0 {
+		t.Labels = nil
+	}
+	return nil
+}
+
+// mergeJSON применяет merge patch к JSON-документу target
+func mergeJSON(target, patch json.RawMessage) (json.RawMessage, error) {
+	var p any
+	if err := decodeJSON(patch, &p); err != nil {
+		return nil, err
+	}
+	var doc any
+	if len(target) > 0 {
+		if err := decodeJSON(target, &doc); err != nil {
+			return nil, err
+		}
+	}
+	merged := mergeValue(doc, p)
+	if merged == nil {
+		return nil, nil
+	}
+	return json.Marshal(merged)
+}
+
+// decodeJSON разбирает документ, сохраняя числа как json.Number: через float64 большие целые
+// в полях, которых патч не касается, потеряли бы точность
+func decodeJSON(data []byte, v *any) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return errors.New("лишние данные после JSON-документа")
+	}
+	return nil
+}
+
+// mergeValue реализует алгоритм MergePatch из RFC 7386
+func mergeValue(target, patch any) any {
+	p, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]any)
+	if !ok {
+		t = make(map[string]any)
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+		} else {
+			t[k] = mergeValue(t[k], v)
+		}
+	}
+	return t
+}
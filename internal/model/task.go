@@ -1,30 +1,184 @@
 package model
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
 	"time"
 
 	"github.com/google/uuid"
 )
 
 // TaskStatus представляет статус задачи
-// Допустимые значения: Pending, InProgress, Completed, Failed, Canceled
+// Допустимые значения: Scheduled, Pending, InProgress, Completed, Failed, Canceled, Interrupted
 type TaskStatus string
 
 const (
+	// StatusScheduled - задача ждёт наступления RunAt, после чего встанет в очередь
+	StatusScheduled  TaskStatus = "Scheduled"
 	StatusPending    TaskStatus = "Pending"
 	StatusInProgress TaskStatus = "InProgress"
 	StatusCompleted  TaskStatus = "Completed"
 	StatusFailed     TaskStatus = "Failed"
 	StatusCanceled   TaskStatus = "Canceled"
+	// StatusInterrupted - задача была прервана при остановке сервиса и будет перезапущена
+	StatusInterrupted TaskStatus = "Interrupted"
 )
 
+// Requeueable сообщает, должна ли задача с таким статусом быть поставлена в очередь повторно после перезапуска
+func (s TaskStatus) Requeueable() bool {
+	return s == StatusScheduled || s == StatusPending || s == StatusInterrupted
+}
+
+// Editable сообщает, можно ли изменять параметры задачи с таким статусом: обработка ещё не начата
+func (s TaskStatus) Editable() bool {
+	return s == StatusScheduled || s == StatusPending
+}
+
+// Valid сообщает, является ли значение одним из известных статусов
+func (s TaskStatus) Valid() bool {
+	switch s {
+	case StatusScheduled, StatusPending, StatusInProgress, StatusCompleted, StatusFailed, StatusCanceled, StatusInterrupted:
+		return true
+	}
+	return false
+}
+
+// Terminal сообщает, завершена ли обработка задачи окончательно
+func (s TaskStatus) Terminal() bool {
+	return s == StatusCompleted || s == StatusFailed || s == StatusCanceled
+}
+
+// Границы допустимого приоритета задачи; задачи с большим приоритетом обрабатываются раньше
+const (
+	MinPriority = -100
+	MaxPriority = 100
+)
+
+// Ограничения параметров задачи
+const (
+	MaxLabels        = 32
+	MaxLabelValueLen = 256
+	MaxPayloadSize   = 64 << 10
+	MaxTimeout       = 24 * time.Hour
+)
+
+// typePattern - допустимый формат типа задачи
+var typePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]{0,63}$`)
+
+// labelPattern - допустимый формат ключа метки
+var labelPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_./-]{0,62}$`)
+
+// Duration - длительность, которая в JSON записывается строкой вида "1m30s"
+type Duration time.Duration
+
+// MarshalJSON записывает длительность строкой
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON принимает строку формата time.ParseDuration
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return errors.New("длительность должна быть строкой вида \"1m30s\"")
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// Artifact описывает файл-результат задачи, сохранённый в хранилище артефактов
+type Artifact struct {
+	Name        string    `json:"name"`
+	ContentType string    `json:"content_type,omitempty"`
+	Size        int64     `json:"size"`
+	SHA256      string    `json:"sha256"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
 // Task описывает I/O-bound задачу
 type Task struct {
-	ID         uuid.UUID  `json:"id"`
-	Status     TaskStatus `json:"status"`
-	CreatedAt  time.Time  `json:"created_at"`
-	StartedAt  *time.Time `json:"started_at,omitempty"`
-	FinishedAt *time.Time `json:"finished_at,omitempty"`
-	Result     string     `json:"result,omitempty"`
-	Error      string     `json:"error,omitempty"`
+	ID         uuid.UUID         `json:"id"`
+	Tenant     string            `json:"tenant,omitempty"`
+	CreatedBy  string            `json:"created_by,omitempty"`
+	ParentID   *uuid.UUID        `json:"parent_id,omitempty"` // задача, повтором или копией которой создана эта
+	Type       string            `json:"type,omitempty"`
+	Priority   int               `json:"priority,omitempty"`
+	Labels     map[string]string `json:"labels,omitempty"`
+	RunAt      *time.Time        `json:"run_at,omitempty"`  // не запускать задачу раньше этого момента
+	Timeout    Duration          `json:"timeout,omitempty"` // ограничение времени обработки; 0 - без ограничения
+	Payload    json.RawMessage   `json:"payload,omitempty"` // входные данные обработчика в произвольном JSON
+	Status     TaskStatus        `json:"status"`
+	Version    int64             `json:"version"` // увеличивается при каждом изменении задачи, используется в ETag
+	CreatedAt  time.Time         `json:"created_at"`
+	StartedAt  *time.Time        `json:"started_at,omitempty"`
+	FinishedAt *time.Time        `json:"finished_at,omitempty"`
+	Result     string            `json:"result,omitempty"`
+	Error      string            `json:"error,omitempty"`
+	Artifacts  []Artifact        `json:"artifacts,omitempty"`
+	// RestoredAt - момент последнего восстановления задачи из архива
+	RestoredAt *time.Time `json:"restored_at,omitempty"`
+	// DeletedAt - момент мягкого удаления; такая задача скрыта из API, но её можно восстановить
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// Deleted сообщает, удалена ли задача мягким удалением
+func (t *Task) Deleted() bool {
+	return t.DeletedAt != nil
+}
+
+// ResetForRequeue возвращает задачу в состояние Pending, очищая следы предыдущего запуска
+func (t *Task) ResetForRequeue() {
+	t.Status = StatusPending
+	t.StartedAt = nil
+	t.FinishedAt = nil
+	t.Result = ""
+	t.Error = ""
+	t.Artifacts = nil
+}
+
+// Artifact возвращает артефакт задачи по имени
+func (t *Task) Artifact(name string) (Artifact, bool) {
+	for _, a := range t.Artifacts {
+		if a.Name == name {
+			return a, true
+		}
+	}
+	return Artifact{}, false
+}
+
+// Validate проверяет поля задачи, задаваемые клиентом
+func (t *Task) Validate() error {
+	if t.Type != "" && !typePattern.MatchString(t.Type) {
+		return errors.New("type должен состоять из строчных латинских букв, цифр, '_', '.', '-' и быть не длиннее 64 символов")
+	}
+	if t.Priority < MinPriority || t.Priority > MaxPriority {
+		return fmt.Errorf("priority должен быть в диапазоне [%d, %d]", MinPriority, MaxPriority)
+	}
+	if len(t.Labels) > MaxLabels {
+		return fmt.Errorf("не больше %d меток", MaxLabels)
+	}
+	for k, v := range t.Labels {
+		if !labelPattern.MatchString(k) {
+			return fmt.Errorf("ключ метки %q должен состоять из строчных латинских букв, цифр, '_', '.', '/', '-' и быть не длиннее 63 символов", k)
+		}
+		if len(v) > MaxLabelValueLen {
+			return fmt.Errorf("значение метки %q длиннее %d байт", k, MaxLabelValueLen)
+		}
+	}
+	if t.Timeout < 0 || time.Duration(t.Timeout) > MaxTimeout {
+		return fmt.Errorf("timeout должен быть в диапазоне [0, %s]", MaxTimeout)
+	}
+	if len(t.Payload) > MaxPayloadSize {
+		return fmt.Errorf("payload больше %d байт", MaxPayloadSize)
+	}
+	if len(t.Payload) > 0 && !json.Valid(t.Payload) {
+		return errors.New("payload должен быть корректным JSON")
+	}
+	return nil
 }
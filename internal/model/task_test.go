@@ -50,3 +50,94 @@ func TestTaskJSON_MarshalUnmarshal(t *testing.T) {
 		t.Errorf("Result mismatch: got %v, want %v", taskClone.Result, taskOrig.Result)
 	}
 }
+
+// TestFold проверяет восстановление состояния задачи по истории событий
+func TestFold(t *testing.T) {
+	if _, err := Fold([]Event{{Type: EventStarted}}); err != ErrNoSnapshot {
+		t.Errorf("история без снимка: ожидалась ErrNoSnapshot, получили %v", err)
+	}
+
+	created := time.Now()
+	at := func(d time.Duration) time.Time { return created.Add(d) }
+	snapshot := &Task{ID: uuid.New(), Status: StatusPending, CreatedAt: created, Type: "report"}
+	events := []Event{
+		{Type: EventCreated, Time: created, Task: snapshot},
+		{Type: EventQueued, Time: at(time.Second)},
+		{Type: EventStarted, Time: at(2 * time.Second)},
+		{Type: EventProgress, Time: at(3 * time.Second), Artifact: &Artifact{Name: "a.txt", Size: 1}},
+		{Type: EventProgress, Time: at(4 * time.Second), Artifact: &Artifact{Name: "a.txt", Size: 2}},
+		{Type: EventFailed, Time: at(5 * time.Second), Error: "сбой"},
+		{Type: EventRetried, Time: at(6 * time.Second)},
+		{Type: EventStarted, Time: at(7 * time.Second)},
+		{Type: EventProgress, Time: at(8 * time.Second), Artifact: &Artifact{Name: "b.txt", Size: 3}},
+		{Type: EventCompleted, Time: at(9 * time.Second), Result: "ok"},
+		{Type: EventDeleted, Time: at(10 * time.Second)},
+	}
+	task, err := Fold(events)
+	if err != nil {
+		t.Fatalf("Fold: %v", err)
+	}
+	if task.Status != StatusCompleted || task.Result != "ok" || task.Error != "" {
+		t.Errorf("ожидалась успешно завершённая задача, получили %+v", task)
+	}
+	if !task.StartedAt.Equal(at(7*time.Second)) || !task.FinishedAt.Equal(at(9*time.Second)) {
+		t.Errorf("время должно соответствовать последнему запуску: %v - %v", task.StartedAt, task.FinishedAt)
+	}
+	if len(task.Artifacts) != 1 || task.Artifacts[0].Name != "b.txt" {
+		t.Errorf("после повтора должны остаться только артефакты нового запуска, получили %+v", task.Artifacts)
+	}
+	if !task.Deleted() || task.ID != snapshot.ID || task.Type != "report" {
+		t.Errorf("неверное состояние задачи: %+v", task)
+	}
+	if snapshot.Status != StatusPending || snapshot.StartedAt != nil {
+		t.Errorf("свёртка не должна изменять снимок в истории")
+	}
+
+	task.Apply(Event{Type: EventRestored, Time: at(11 * time.Second)})
+	if task.Deleted() {
+		t.Errorf("restored должно снимать пометку удаления")
+	}
+}
+
+// TestApplyMergePatch проверяет семантику JSON Merge Patch для изменяемых полей задачи
+func TestApplyMergePatch(t *testing.T) {
+	task := &Task{
+		Priority: 3,
+		Labels:   map[string]string{"team": "a", "env": "prod"},
+		Timeout:  Duration(time.Minute),
+		Payload:  json.RawMessage(`{"a":1,"nested":{"x":1,"y":2}}`),
+	}
+	err := task.ApplyMergePatch([]byte(`{"priority":null,"labels":{"env":null,"tier":"gold"},` +
+		`"timeout":"30s","run_at":"2030-01-01T00:00:00Z","payload":{"nested":{"y":null,"z":3}}}`))
+	if err != nil {
+		t.Fatalf("ApplyMergePatch: %v", err)
+	}
+	if task.Priority != 0 || len(task.Labels) != 2 || task.Labels["tier"] != "gold" || task.Labels["env"] != "" {
+		t.Errorf("неверные priority или labels: %d %v", task.Priority, task.Labels)
+	}
+	if time.Duration(task.Timeout) != 30*time.Second || task.RunAt == nil || task.RunAt.Year() != 2030 {
+		t.Errorf("неверные timeout или run_at: %v %v", task.Timeout, task.RunAt)
+	}
+	if string(task.Payload) != `{"a":1,"nested":{"x":1,"z":3}}` {
+		t.Errorf("неверный payload: %s", task.Payload)
+	}
+
+	// Большие целые в полях, которых патч не касается, не теряют точность
+	task.Payload = json.RawMessage(`{"account_id":9007199254740993,"x":1,"f":0.1}`)
+	if err := task.ApplyMergePatch([]byte(`{"payload":{"x":2}}`)); err != nil {
+		t.Fatalf("ApplyMergePatch: %v", err)
+	}
+	if string(task.Payload) != `{"account_id":9007199254740993,"f":0.1,"x":2}` {
+		t.Errorf("неверный payload: %s", task.Payload)
+	}
+
+	before := task.Clone()
+	for _, patch := range []string{`[1]`, `{"status":"Completed"}`, `{"priority":1,"timeout":"долго"}`} {
+		if err := task.ApplyMergePatch([]byte(patch)); err == nil {
+			t.Errorf("патч %s должен быть отклонён", patch)
+		}
+	}
+	if task.Priority != before.Priority || task.Timeout != before.Timeout {
+		t.Errorf("отклонённый патч не должен изменять задачу")
+	}
+}
@@ -0,0 +1,135 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Limit описывает корзину токенов: Rate токенов в секунду, не более Burst накопленных
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// ParseLimit разбирает строку вида "10/s", "100/m:20" или "1000/h" (после двоеточия - размер всплеска).
+// Без явного всплеска его размер равен числу запросов за период.
+func ParseLimit(spec string) (Limit, error) {
+	spec = strings.TrimSpace(spec)
+	rateSpec, burstSpec, hasBurst := strings.Cut(spec, ":")
+	countSpec, unit, ok := strings.Cut(rateSpec, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("ожидался формат <число>/<s|m|h>[:всплеск], получили %q", spec)
+	}
+	count, err := strconv.Atoi(countSpec)
+	if err != nil || count <= 0 {
+		return Limit{}, fmt.Errorf("неверное число запросов в %q", spec)
+	}
+	var period time.Duration
+	switch unit {
+	case "s":
+		period = time.Second
+	case "m":
+		period = time.Minute
+	case "h":
+		period = time.Hour
+	default:
+		return Limit{}, fmt.Errorf("неизвестная единица %q в %q", unit, spec)
+	}
+	l := Limit{Rate: float64(count) / period.Seconds(), Burst: count}
+	if hasBurst {
+		if l.Burst, err = strconv.Atoi(burstSpec); err != nil || l.Burst <= 0 {
+			return Limit{}, fmt.Errorf("неверный размер всплеска в %q", spec)
+		}
+	}
+	return l, nil
+}
+
+// Result - результат попытки взять токен
+type Result struct {
+	Allowed bool
+	// Limit - ёмкость корзины
+	Limit int
+	// Remaining - число токенов, оставшихся после запроса
+	Remaining int
+	// Reset - время до полного восполнения корзины
+	Reset time.Duration
+	// RetryAfter - время до появления следующего токена, если запрос отклонён
+	RetryAfter time.Duration
+}
+
+// newResult вычисляет Result по состоянию корзины после попытки
+func newResult(l Limit, allowed bool, tokens float64) Result {
+	res := Result{Allowed: allowed, Limit: l.Burst, Remaining: int(math.Floor(tokens))}
+	res.Reset = time.Duration((float64(l.Burst) - tokens) / l.Rate * float64(time.Second))
+	if !allowed {
+		res.RetryAfter = time.Duration((1 - tokens) / l.Rate * float64(time.Second))
+	}
+	return res
+}
+
+// Backend хранит состояние корзин. Реализации должны быть безопасны для конкурентного использования.
+type Backend interface {
+	Take(ctx context.Context, key string, l Limit) (Result, error)
+}
+
+// bucket - состояние корзины в памяти
+type bucket struct {
+	tokens float64
+	last   time.Time
+	limit  Limit
+}
+
+// MemoryBackend хранит корзины в памяти процесса; лимиты действуют в пределах одной реплики
+type MemoryBackend struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	now       func() time.Time
+	lastSweep time.Time
+}
+
+// NewMemoryBackend создаёт хранилище корзин в памяти
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{buckets: make(map[string]*bucket), now: time.Now}
+}
+
+// sweepInterval - как часто удаляются корзины, успевшие полностью восполниться
+const sweepInterval = time.Minute
+
+// Take пытается взять токен из корзины key
+func (m *MemoryBackend) Take(_ context.Context, key string, l Limit) (Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	if now.Sub(m.lastSweep) > sweepInterval {
+		m.sweep(now)
+		m.lastSweep = now
+	}
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.Burst), last: now, limit: l}
+		m.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(l.Burst), b.tokens+now.Sub(b.last).Seconds()*l.Rate)
+	b.last = now
+	b.limit = l
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	return newResult(l, allowed, b.tokens), nil
+}
+
+// sweep удаляет корзины, которые к моменту now восполнились бы полностью; вызывается под m.mu
+func (m *MemoryBackend) sweep(now time.Time) {
+	for key, b := range m.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*b.limit.Rate >= float64(b.limit.Burst) {
+			delete(m.buckets, key)
+		}
+	}
+}
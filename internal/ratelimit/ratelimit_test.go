@@ -0,0 +1,96 @@
+package ratelimit
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+// TestParseLimit проверяет разбор строк лимитов и отказ на неверных значениях.
+func TestParseLimit(t *testing.T) {
+	l, err := ParseLimit("120/m:10")
+	if err != nil {
+		t.Fatalf("ParseLimit вернул ошибку: %v", err)
+	}
+	if l.Rate != 2 || l.Burst != 10 {
+		t.Errorf("ожидалось 2 токена/с и всплеск 10, получили %+v", l)
+	}
+	for _, bad := range []string{"10", "0/s", "10/d", "10/s:0", "x/s"} {
+		if _, err := ParseLimit(bad); err == nil {
+			t.Errorf("ожидалась ошибка для %q", bad)
+		}
+	}
+}
+
+// TestMemoryBackend_Take проверяет исчерпание корзины, Retry-After и восполнение со временем.
+func TestMemoryBackend_Take(t *testing.T) {
+	m := NewMemoryBackend()
+	now := time.Unix(1000, 0)
+	m.now = func() time.Time { return now }
+	l := Limit{Rate: 1, Burst: 2}
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if res, _ := m.Take(ctx, "k", l); !res.Allowed {
+			t.Fatalf("запрос %d в пределах всплеска отклонён", i+1)
+		}
+	}
+	res, _ := m.Take(ctx, "k", l)
+	if res.Allowed || res.Remaining != 0 || res.RetryAfter != time.Second {
+		t.Errorf("ожидался отказ с RetryAfter 1s, получили %+v", res)
+	}
+	if res, _ := m.Take(ctx, "other", l); !res.Allowed {
+		t.Errorf("корзины разных ключей должны быть независимы")
+	}
+
+	now = now.Add(time.Second)
+	if res, _ := m.Take(ctx, "k", l); !res.Allowed {
+		t.Errorf("через секунду должен появиться токен")
+	}
+}
+
+// TestRedisBackend_Take проверяет обмен по протоколу RESP на заглушке Redis-сервера.
+func TestRedisBackend_Take(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+
+	commands := make(chan []string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		rd := bufio.NewReader(conn)
+		for {
+			reply, err := readReply(rd)
+			if err != nil {
+				return
+			}
+			var args []string
+			for _, a := range reply.([]any) {
+				args = append(args, a.(string))
+			}
+			commands <- args
+			conn.Write([]byte("*2\r\n:0\r\n$4\r\n0.25\r\n"))
+		}
+	}()
+
+	r := NewRedisBackend(ln.Addr().String(), "")
+	res, err := r.Take(context.Background(), "write:ip:1.2.3.4", Limit{Rate: 0.5, Burst: 3})
+	if err != nil {
+		t.Fatalf("Take вернул ошибку: %v", err)
+	}
+	args := <-commands
+	if args[0] != "EVAL" || args[2] != "1" || args[3] != "ratelimit:write:ip:1.2.3.4" || args[4] != "0.5" || args[5] != "3" {
+		t.Errorf("неожиданная команда: %s %q", args[0], args[2:])
+	}
+	if res.Allowed || res.Remaining != 0 || res.RetryAfter != 1500*time.Millisecond {
+		t.Errorf("неверный разбор ответа: %+v", res)
+	}
+}
@@ -0,0 +1,193 @@
+package ratelimit
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// tokenBucketScript атомарно пополняет корзину по серверному времени и пытается взять токен.
+// Возвращает {1|0, оставшиеся токены строкой}, так как Lua-числа в ответе усекаются до целых.
+const tokenBucketScript = `
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local data = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(data[1]) or burst
+local ts = tonumber(data[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - ts) / 1000 * rate)
+local allowed = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst / rate * 1000) + 1000)
+return {allowed, tostring(tokens)}
+`
+
+// RedisBackend хранит корзины в Redis-совместимом сервере, чтобы лимиты действовали на все реплики.
+// Используется собственный минимальный клиент протокола RESP.
+type RedisBackend struct {
+	addr     string
+	password string
+	prefix   string
+	timeout  time.Duration
+	pool     chan *redisConn
+}
+
+// NewRedisBackend создаёт хранилище корзин в Redis по адресу host:port
+func NewRedisBackend(addr, password string) *RedisBackend {
+	return &RedisBackend{
+		addr:     addr,
+		password: password,
+		prefix:   "ratelimit:",
+		timeout:  time.Second,
+		pool:     make(chan *redisConn, 8),
+	}
+}
+
+// Take пытается взять токен из корзины key
+func (r *RedisBackend) Take(ctx context.Context, key string, l Limit) (Result, error) {
+	reply, err := r.do(ctx, "EVAL", tokenBucketScript, "1", r.prefix+key,
+		strconv.FormatFloat(l.Rate, 'f', -1, 64), strconv.Itoa(l.Burst))
+	if err != nil {
+		return Result{}, err
+	}
+	arr, ok := reply.([]any)
+	if !ok || len(arr) != 2 {
+		return Result{}, fmt.Errorf("неожиданный ответ скрипта: %v", reply)
+	}
+	allowed, _ := arr[0].(int64)
+	tokensStr, _ := arr[1].(string)
+	tokens, err := strconv.ParseFloat(tokensStr, 64)
+	if err != nil {
+		return Result{}, fmt.Errorf("неожиданный ответ скрипта: %v", reply)
+	}
+	return newResult(l, allowed == 1, tokens), nil
+}
+
+// redisConn - соединение с сервером и буферизованный читатель ответов
+type redisConn struct {
+	net.Conn
+	rd *bufio.Reader
+}
+
+// do выполняет команду на соединении из пула; повреждённые соединения закрываются
+func (r *RedisBackend) do(ctx context.Context, args ...string) (any, error) {
+	c, err := r.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(r.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	c.SetDeadline(deadline)
+	reply, err := c.command(args...)
+	var redisErr redisError
+	if err != nil && !errors.As(err, &redisErr) {
+		c.Close()
+		return nil, err
+	}
+	r.put(c)
+	return reply, err
+}
+
+func (r *RedisBackend) get(ctx context.Context) (*redisConn, error) {
+	select {
+	case c := <-r.pool:
+		return c, nil
+	default:
+	}
+	d := net.Dialer{Timeout: r.timeout}
+	nc, err := d.DialContext(ctx, "tcp", r.addr)
+	if err != nil {
+		return nil, err
+	}
+	c := &redisConn{Conn: nc, rd: bufio.NewReader(nc)}
+	if r.password != "" {
+		c.SetDeadline(time.Now().Add(r.timeout))
+		if _, err := c.command("AUTH", r.password); err != nil {
+			c.Close()
+			return nil, fmt.Errorf("аутентификация в Redis: %w", err)
+		}
+	}
+	return c, nil
+}
+
+func (r *RedisBackend) put(c *redisConn) {
+	select {
+	case r.pool <- c:
+	default:
+		c.Close()
+	}
+}
+
+// redisError - ошибка, возвращённая сервером (ответ с префиксом '-')
+type redisError string
+
+func (e redisError) Error() string { return "redis: " + string(e) }
+
+// command отправляет команду в формате массива RESP и читает ответ
+func (c *redisConn) command(args ...string) (any, error) {
+	buf := []byte("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, a := range args {
+		buf = append(buf, "$"+strconv.Itoa(len(a))+"\r\n"...)
+		buf = append(buf, a...)
+		buf = append(buf, "\r\n"...)
+	}
+	if _, err := c.Write(buf); err != nil {
+		return nil, err
+	}
+	return readReply(c.rd)
+}
+
+// readReply читает один ответ RESP: строки, ошибки, целые, bulk-строки и массивы
+func readReply(rd *bufio.Reader) (any, error) {
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("неверная строка протокола RESP: %q", line)
+	}
+	kind, body := line[0], line[1:len(line)-2]
+	switch kind {
+	case '+':
+		return body, nil
+	case '-':
+		return nil, redisError(body)
+	case ':':
+		return strconv.ParseInt(body, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(body)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(rd, data); err != nil {
+			return nil, err
+		}
+		return string(data[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(body)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		arr := make([]any, n)
+		for i := range arr {
+			if arr[i], err = readReply(rd); err != nil {
+				return nil, err
+			}
+		}
+		return arr, nil
+	}
+	return nil, fmt.Errorf("неизвестный тип ответа RESP %q", kind)
+}
@@ -0,0 +1,370 @@
+package retention
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"workmateTestProject/internal/clock"
+	"workmateTestProject/internal/model"
+	"workmateTestProject/internal/storage"
+)
+
+// anyStatus в ключе правила означает любой завершённый статус
+const anyStatus = "*"
+
+// Rule - ограничения хранения одной группы завершённых задач. Нулевое поле означает отсутствие ограничения.
+type Rule struct {
+	// MaxAge - сколько хранить задачу после завершения
+	MaxAge time.Duration
+	// MaxCount - сколько последних завершённых задач группы хранить
+	MaxCount int
+}
+
+// String возвращает правило в формате возраст:количество
+func (r Rule) String() string {
+	s := ""
+	if r.MaxAge > 0 {
+		s = r.MaxAge.String()
+	}
+	if r.MaxCount > 0 {
+		s += ":" + strconv.Itoa(r.MaxCount)
+	}
+	return s
+}
+
+// Policy - правила хранения завершённых задач по ключам "статус" и "тип/статус",
+// где статус - Completed, Failed, Canceled или "*" (любой завершённый).
+// Для задачи выбирается наиболее точное правило: тип/статус, тип/*, статус, *.
+type Policy map[string]Rule
+
+// ParsePolicy разбирает строку вида "*=168h,Completed=24h:1000,report/Completed=72h,Canceled=:100"
+func ParsePolicy(spec string) (Policy, error) {
+	p := make(Policy)
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("ожидался формат [тип/]статус=возраст[:количество], получили %q", item)
+		}
+		status := key
+		if i := strings.LastIndexByte(key, '/'); i >= 0 {
+			if i == 0 {
+				return nil, fmt.Errorf("пустой тип в %q", item)
+			}
+			status = key[i+1:]
+		}
+		if status != anyStatus && !model.TaskStatus(status).Terminal() {
+			return nil, fmt.Errorf("статус %q не является завершённым (Completed, Failed, Canceled или *)", status)
+		}
+
+		var rule Rule
+		age, count, _ := strings.Cut(value, ":")
+		if age != "" {
+			d, err := time.ParseDuration(age)
+			if err != nil || d < 0 {
+				return nil, fmt.Errorf("неверный возраст в %q", item)
+			}
+			rule.MaxAge = d
+		}
+		if count != "" {
+			n, err := strconv.Atoi(count)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("неверное количество в %q", item)
+			}
+			rule.MaxCount = n
+		}
+		p[key] = rule
+	}
+	return p, nil
+}
+
+// ruleFor возвращает ключ и правило, действующие для задачи
+func (p Policy) ruleFor(task *model.Task) (string, Rule, bool) {
+	status := string(task.Status)
+	keys := []string{status, anyStatus}
+	if task.Type != "" {
+		keys = []string{task.Type + "/" + status, task.Type + "/" + anyStatus, status, anyStatus}
+	}
+	for _, key := range keys {
+		if rule, ok := p[key]; ok {
+			return key, rule, true
+		}
+	}
+	return "", Rule{}, false
+}
+
+// Stats - сведения о работе сборщика
+type Stats struct {
+	Runs         int64      `json:"runs"`
+	LastRun      *time.Time `json:"last_run,omitempty"`
+	LastDuration string     `json:"last_duration,omitempty"`
+	LastPurged   int        `json:"last_purged"`
+	// Purged - всего удалено задач по статусам и по типам
+	Purged       map[model.TaskStatus]int64 `json:"purged"`
+	PurgedByType map[string]int64           `json:"purged_by_type"`
+	Errors       int64                      `json:"errors"`
+	LastError    string                     `json:"last_error,omitempty"`
+}
+
+// Janitor периодически удаляет из хранилища задачи, вышедшие за пределы политики хранения
+type Janitor struct {
+	store  storage.TaskStore
+	policy Policy
+	batch  int
+
+	// Clock - часы, по которым отсчитываются сроки хранения (по умолчанию системные);
+	// должны совпадать с часами обработчика, отмечающего время завершения задач
+	Clock clock.Clock
+
+	// Lock блокирует изменения задач с указанными ID и возвращает функцию разблокировки.
+	// Пачка остаётся заблокированной от повторной проверки до удаления, чтобы не удалить задачу,
+	// изменённую после отбора. nil - без блокировки.
+	Lock func(ids []uuid.UUID) (unlock func())
+
+	// Snapshot возвращает копию задачи, согласованную с изменениями, которые обработчик вносит
+	// в задачи в хранилище; по ней отбираются и передаются в OnPurge кандидаты. nil - простое копирование.
+	Snapshot func(task *model.Task) *model.Task
+
+	// DeletedGrace - через сколько после мягкого удаления задача удаляется окончательно; 0 - не удалять
+	DeletedGrace time.Duration
+
+	// OnPurge вызывается для каждой пачки задач перед удалением из хранилища (архивирование).
+	// При ошибке пачка остаётся в хранилище до следующего прохода, поэтому OnPurge не должен
+	// уничтожать данные задач, если возвращает ошибку.
+	OnPurge func(ctx context.Context, tasks []*model.Task) error
+
+	// AfterPurge вызывается для каждой пачки после удаления из хранилища (удаление артефактов
+	// и журналов). Очистка выполняется по возможности: задачи уже удалены, и её ошибки не повторяются.
+	AfterPurge func(ctx context.Context, tasks []*model.Task)
+
+	mu    sync.Mutex
+	stats Stats
+}
+
+// NewJanitor создаёт сборщик, удаляющий задачи пачками не больше batch
+func NewJanitor(store storage.TaskStore, policy Policy, batch int) *Janitor {
+	if batch <= 0 {
+		batch = 500
+	}
+	return &Janitor{
+		store:  store,
+		policy: policy,
+		batch:  batch,
+		Clock:  clock.Real(),
+		stats:  Stats{Purged: make(map[model.TaskStatus]int64), PurgedByType: make(map[string]int64)},
+	}
+}
+
+// Policy возвращает действующую политику хранения
+func (j *Janitor) Policy() Policy {
+	return j.policy
+}
+
+// Run выполняет Sweep каждые interval (по умолчанию раз в минуту), пока не отменён ctx
+func (j *Janitor) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-j.Clock.After(interval):
+		}
+		if n, err := j.Sweep(ctx); err != nil {
+			log.Printf("Ошибка очистки задач: %v", err)
+		} else if n > 0 {
+			log.Printf("Удалено устаревших задач: %d", n)
+		}
+	}
+}
+
+// Sweep удаляет устаревшие задачи и возвращает их число.
+// Кандидаты отбираются по снимку хранилища, поэтому перед удалением каждая пачка блокируется
+// и задачи, изменённые после отбора, из неё исключаются. Блокировка хранилища удерживается
+// только на время копирования списка и отдельных удалений.
+func (j *Janitor) Sweep(ctx context.Context) (int, error) {
+	start := j.Clock.Now()
+	expired := j.expired(start)
+
+	purged := 0
+	var err error
+	for len(expired) > 0 && err == nil {
+		if err = ctx.Err(); err != nil {
+			break
+		}
+		n := min(j.batch, len(expired))
+		var batch []*model.Task
+		batch, err = j.purge(ctx, expired[:n])
+		expired = expired[n:]
+		purged += len(batch)
+		j.record(batch)
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.stats.Runs++
+	j.stats.LastRun = &start
+	j.stats.LastDuration = j.Clock.Now().Sub(start).String()
+	j.stats.LastPurged = purged
+	if err != nil {
+		j.stats.Errors++
+		j.stats.LastError = err.Error()
+	}
+	return purged, err
+}
+
+// candidate - задача, отобранная для удаления, и её снимок на момент отбора
+type candidate struct {
+	task *model.Task
+	snap *model.Task
+}
+
+// snapshot возвращает копию задачи через Snapshot, а если он не задан - копию её полей
+func (j *Janitor) snapshot(task *model.Task) *model.Task {
+	if j.Snapshot == nil {
+		c := *task
+		return &c
+	}
+	return j.Snapshot(task)
+}
+
+// unchanged сообщает, что задача всё ещё в хранилище и не менялась после отбора, а значит
+// по-прежнему устарела: время только идёт вперёд, а новые задачи группы лишь отодвигают её за лимит
+func (j *Janitor) unchanged(c candidate) bool {
+	current, ok := j.store.Get(c.task.ID)
+	if !ok || current != c.task {
+		return false
+	}
+	snap := j.snapshot(current)
+	return snap.Version == c.snap.Version && snap.Status == c.snap.Status && snap.Deleted() == c.snap.Deleted()
+}
+
+// purge удаляет пачку под блокировкой её задач и возвращает удалённые задачи
+func (j *Janitor) purge(ctx context.Context, cands []candidate) ([]*model.Task, error) {
+	if j.Lock != nil {
+		ids := make([]uuid.UUID, len(cands))
+		for i, c := range cands {
+			ids[i] = c.task.ID
+		}
+		defer j.Lock(ids)()
+	}
+	var batch []*model.Task
+	for _, c := range cands {
+		if j.unchanged(c) {
+			batch = append(batch, c.snap)
+		}
+	}
+	if len(batch) == 0 {
+		return nil, nil
+	}
+	if j.OnPurge != nil {
+		if err := j.OnPurge(ctx, batch); err != nil {
+			return nil, err
+		}
+	}
+	for _, task := range batch {
+		j.store.Delete(task.ID)
+	}
+	if j.AfterPurge != nil {
+		j.AfterPurge(ctx, batch)
+	}
+	return batch, nil
+}
+
+// expired отбирает задачи, превысившие возраст или количество по своему правилу,
+// и мягко удалённые задачи, срок восстановления которых истёк
+func (j *Janitor) expired(now time.Time) []candidate {
+	var expired []candidate
+	// Группы и проверки строятся по снимкам, а для повторной проверки запоминается и задача из хранилища
+	live := make(map[*model.Task]*model.Task)
+	pick := func(snap *model.Task) {
+		expired = append(expired, candidate{task: live[snap], snap: snap})
+	}
+	groups := make(map[string][]*model.Task)
+	rules := make(map[string]Rule)
+	for _, current := range j.store.List() {
+		task := j.snapshot(current)
+		live[task] = current
+		if task.Deleted() {
+			if j.DeletedGrace > 0 && now.Sub(*task.DeletedAt) > j.DeletedGrace {
+				pick(task)
+			}
+			continue
+		}
+		if !task.Status.Terminal() {
+			continue
+		}
+		key, rule, ok := j.policy.ruleFor(task)
+		if !ok {
+			continue
+		}
+		// Лимит количества считается отдельно для каждого статуса, даже если правило общее
+		key += "|" + string(task.Status)
+		groups[key] = append(groups[key], task)
+		rules[key] = rule
+	}
+
+	for key, tasks := range groups {
+		rule := rules[key]
+		// Сначала самые свежие: за лимит количества выходят самые старые задачи
+		sort.Slice(tasks, func(a, b int) bool { return finishedAt(tasks[a]).After(finishedAt(tasks[b])) })
+		for i, task := range tasks {
+			if (rule.MaxCount > 0 && i >= rule.MaxCount) || (rule.MaxAge > 0 && now.Sub(finishedAt(task)) > rule.MaxAge) {
+				pick(task)
+			}
+		}
+	}
+	return expired
+}
+
+// record учитывает удалённые задачи в статистике
+func (j *Janitor) record(tasks []*model.Task) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for _, task := range tasks {
+		j.stats.Purged[task.Status]++
+		if task.Type != "" {
+			j.stats.PurgedByType[task.Type]++
+		}
+	}
+}
+
+// Stats возвращает копию статистики сборщика
+func (j *Janitor) Stats() Stats {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	st := j.stats
+	st.Purged = make(map[model.TaskStatus]int64, len(j.stats.Purged))
+	for k, v := range j.stats.Purged {
+		st.Purged[k] = v
+	}
+	st.PurgedByType = make(map[string]int64, len(j.stats.PurgedByType))
+	for k, v := range j.stats.PurgedByType {
+		st.PurgedByType[k] = v
+	}
+	return st
+}
+
+// finishedAt - момент, от которого отсчитывается срок хранения: завершение задачи
+// (для задач без него, например отменённых в очереди, - создание) или восстановление из архива
+func finishedAt(task *model.Task) time.Time {
+	t := task.CreatedAt
+	if task.FinishedAt != nil {
+		t = *task.FinishedAt
+	}
+	if task.RestoredAt != nil && task.RestoredAt.After(t) {
+		t = *task.RestoredAt
+	}
+	return t
+}
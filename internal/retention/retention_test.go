@@ -0,0 +1,161 @@
+package retention
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"workmateTestProject/internal/clock"
+	"workmateTestProject/internal/model"
+	"workmateTestProject/internal/storage"
+)
+
+// TestJanitor_Sweep проверяет удаление по возрасту и количеству с переопределением для типа,
+// вызов OnPurge пачками, статистику удалённых задач и окончательное удаление мягко удалённых.
+func TestJanitor_Sweep(t *testing.T) {
+	policy, err := ParsePolicy("Completed=1h:2,report/Completed=24h,Failed=:1")
+	if err != nil {
+		t.Fatalf("ParsePolicy: %v", err)
+	}
+	if _, err := ParsePolicy("Pending=1h"); err == nil {
+		t.Errorf("ожидалась ошибка для незавершённого статуса")
+	}
+
+	store := storage.NewInMemoryTaskStore()
+	clk := clock.NewFake(time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC))
+	now := clk.Now()
+	add := func(typ string, status model.TaskStatus, age time.Duration) *model.Task {
+		finished := now.Add(-age)
+		task := &model.Task{ID: uuid.New(), Type: typ, Status: status, CreatedAt: finished, FinishedAt: &finished}
+		store.Create(task)
+		return task
+	}
+	fresh1 := add("", model.StatusCompleted, time.Minute)
+	fresh2 := add("", model.StatusCompleted, 2*time.Minute)
+	add("", model.StatusCompleted, 3*time.Minute) // выходит за лимит количества
+	add("", model.StatusCompleted, 2*time.Hour)   // устарела
+	report := add("report", model.StatusCompleted, 2*time.Hour)
+	failed := add("", model.StatusFailed, time.Minute)
+	add("", model.StatusFailed, time.Hour)
+	running := add("", model.StatusInProgress, 48*time.Hour)
+
+	j := NewJanitor(store, policy, 2)
+	j.Clock = clk
+	var batches int
+	j.OnPurge = func(ctx context.Context, tasks []*model.Task) error {
+		batches++
+		return nil
+	}
+	n, err := j.Sweep(context.Background())
+	if err != nil || n != 3 {
+		t.Fatalf("ожидалось удаление 3 задач, получили %d (%v)", n, err)
+	}
+	if batches != 2 {
+		t.Errorf("ожидались 2 пачки по 2 задачи, получили %d", batches)
+	}
+	for _, task := range []*model.Task{fresh1, fresh2, report, failed, running} {
+		if _, ok := store.Get(task.ID); !ok {
+			t.Errorf("задача %s (%s, %s) не должна была удаляться", task.ID, task.Type, task.Status)
+		}
+	}
+	st := j.Stats()
+	if st.Runs != 1 || st.LastPurged != 3 || st.Purged[model.StatusCompleted] != 2 || st.Purged[model.StatusFailed] != 1 {
+		t.Errorf("неверная статистика: %+v", st)
+	}
+
+	// Мягко удалённые задачи удаляются по истечении срока восстановления независимо от политики
+	j.DeletedGrace = time.Hour
+	softDeleted := func(ago time.Duration) *model.Task {
+		task := add("", model.StatusInProgress, 0)
+		at := now.Add(-ago)
+		task.DeletedAt = &at
+		return task
+	}
+	expired := softDeleted(2 * time.Hour)
+	recent := softDeleted(10 * time.Minute)
+	if n, err := j.Sweep(context.Background()); err != nil || n != 1 {
+		t.Fatalf("ожидалось окончательное удаление 1 задачи, получили %d (%v)", n, err)
+	}
+	if _, ok := store.Get(expired.ID); ok {
+		t.Errorf("задача с истёкшим сроком восстановления должна быть удалена")
+	}
+	if _, ok := store.Get(recent.ID); !ok {
+		t.Errorf("недавно удалённая задача должна сохраниться до истечения срока")
+	}
+
+	// Сроки отсчитываются по часам сборщика: через час устаревают обе свежие задачи и недавно удалённая
+	clk.Advance(time.Hour)
+	if n, err := j.Sweep(context.Background()); err != nil || n != 3 {
+		t.Fatalf("после сдвига часов ожидалось удаление 3 задач, получили %d (%v)", n, err)
+	}
+	if _, ok := store.Get(recent.ID); ok {
+		t.Errorf("срок восстановления должен истечь по часам сборщика")
+	}
+}
+
+// TestJanitor_SweepRecheck проверяет, что задача, изменённая после отбора, не удаляется:
+// повторная проверка выполняется под блокировкой пачки
+func TestJanitor_SweepRecheck(t *testing.T) {
+	policy, _ := ParsePolicy("Completed=1h")
+	store := storage.NewInMemoryTaskStore()
+	clk := clock.NewFake(time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC))
+	finished := clk.Now().Add(-2 * time.Hour)
+	stale := &model.Task{ID: uuid.New(), Status: model.StatusCompleted, CreatedAt: finished, FinishedAt: &finished}
+	changed := &model.Task{ID: uuid.New(), Status: model.StatusCompleted, CreatedAt: finished, FinishedAt: &finished}
+	store.Create(stale)
+	store.Create(changed)
+
+	j := NewJanitor(store, policy, 10)
+	j.Clock = clk
+	var locked []uuid.UUID
+	j.Lock = func(ids []uuid.UUID) func() {
+		// Задачу перезапустили между отбором и блокировкой
+		changed.Status = model.StatusPending
+		changed.Version++
+		locked = ids
+		return func() {}
+	}
+	var purged []*model.Task
+	j.OnPurge = func(ctx context.Context, tasks []*model.Task) error {
+		purged = tasks
+		return nil
+	}
+	if n, err := j.Sweep(context.Background()); err != nil || n != 1 {
+		t.Fatalf("ожидалось удаление 1 задачи, получили %d (%v)", n, err)
+	}
+	if len(locked) != 2 || len(purged) != 1 || purged[0].ID != stale.ID || purged[0] == stale {
+		t.Errorf("OnPurge должен получить снимок только неизменённой задачи: заблокировано %v, передано %v", locked, purged)
+	}
+	if _, ok := store.Get(changed.ID); !ok {
+		t.Errorf("изменённая после отбора задача не должна удаляться")
+	}
+}
+
+// TestJanitor_AfterPurge проверяет, что AfterPurge получает пачку уже после удаления задач из хранилища
+func TestJanitor_AfterPurge(t *testing.T) {
+	policy, _ := ParsePolicy("Completed=1h")
+	store := storage.NewInMemoryTaskStore()
+	clk := clock.NewFake(time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC))
+	finished := clk.Now().Add(-2 * time.Hour)
+	task := &model.Task{ID: uuid.New(), Status: model.StatusCompleted, CreatedAt: finished, FinishedAt: &finished}
+	store.Create(task)
+
+	j := NewJanitor(store, policy, 10)
+	j.Clock = clk
+	var cleaned, stored int
+	j.AfterPurge = func(ctx context.Context, tasks []*model.Task) {
+		cleaned += len(tasks)
+		for _, task := range tasks {
+			if _, ok := store.Get(task.ID); ok {
+				stored++
+			}
+		}
+	}
+	if n, err := j.Sweep(context.Background()); err != nil || n != 1 {
+		t.Fatalf("ожидалось удаление 1 задачи, получили %d (%v)", n, err)
+	}
+	if cleaned != 1 || stored != 0 {
+		t.Errorf("AfterPurge: получено %d задач, из них ещё в хранилище %d", cleaned, stored)
+	}
+}
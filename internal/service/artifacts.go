@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"regexp"
+
+	"workmateTestProject/internal/blob"
+	"workmateTestProject/internal/model"
+)
+
+// ErrNoArtifactStore возвращается, если хранилище артефактов не настроено
+var ErrNoArtifactStore = errors.New("хранилище артефактов не настроено")
+
+// ErrInvalidArtifactName возвращается для имён артефактов недопустимого формата
+var ErrInvalidArtifactName = errors.New("имя артефакта должно состоять из латинских букв, цифр, '_', '.', '-' и быть не длиннее 128 символов")
+
+// ErrNoTask возвращается PutArtifact, если контекст не принадлежит обрабатываемой задаче
+var ErrNoTask = errors.New("контекст не связан с задачей")
+
+// artifactNamePattern - допустимый формат имени артефакта
+var artifactNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]{0,127}$`)
+
+// taskKey - ключ контекста, в котором обработчику передаётся его задача
+type taskKey struct{}
+
+// TaskFromContext возвращает задачу, в рамках обработки которой выполняется код
+func TaskFromContext(ctx context.Context) (*model.Task, bool) {
+	task, ok := ctx.Value(taskKey{}).(*model.Task)
+	return task, ok
+}
+
+// SetArtifactStore задаёт хранилище артефактов; вызывается при старте до приёма задач
+func (p *Processor) SetArtifactStore(s blob.Store) {
+	p.artifacts = s
+}
+
+// artifactKey - ключ объекта артефакта в хранилище
+func artifactKey(task *model.Task, name string) string {
+	return task.ID.String() + "/" + name
+}
+
+// PutArtifact сохраняет артефакт обрабатываемой задачи и добавляет его в task.Artifacts и историю задачи.
+// Артефакт с тем же именем заменяется. ctx - контекст, переданный обработчику задачи.
+func PutArtifact(ctx context.Context, name, contentType string, r io.Reader) error {
+	task, ok := TaskFromContext(ctx)
+	p, _ := processorFromContext(ctx)
+	if !ok || p == nil {
+		return ErrNoTask
+	}
+	if p.artifacts == nil {
+		return ErrNoArtifactStore
+	}
+	if !artifactNamePattern.MatchString(name) {
+		return ErrInvalidArtifactName
+	}
+	info, err := p.artifacts.Put(ctx, artifactKey(task, name), r)
+	if err != nil {
+		return err
+	}
+
+	a := model.Artifact{Name: name, ContentType: contentType, Size: info.Size, SHA256: info.SHA256, CreatedAt: p.clock.Now()}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.RecordEvent(task, model.Event{Type: model.EventProgress, Message: "сохранён артефакт " + name, Artifact: &a})
+	return nil
+}
+
+// OpenArtifact открывает артефакт задачи для чтения.
+// Возвращает blob.ErrNotFound, если у задачи нет артефакта с таким именем.
+func (p *Processor) OpenArtifact(ctx context.Context, task *model.Task, name string) (model.Artifact, blob.Object, error) {
+	if p.artifacts == nil {
+		return model.Artifact{}, nil, ErrNoArtifactStore
+	}
+	// Список артефактов меняется событиями обработки, поэтому читается под tasksMu
+	p.tasksMu.RLock()
+	a, ok := task.Artifact(name)
+	p.tasksMu.RUnlock()
+	if !ok {
+		return model.Artifact{}, nil, blob.ErrNotFound
+	}
+	obj, err := p.artifacts.Open(ctx, artifactKey(task, name))
+	if err != nil {
+		return model.Artifact{}, nil, err
+	}
+	return a, obj, nil
+}
+
+// DeleteArtifacts удаляет из хранилища все артефакты задачи
+func (p *Processor) DeleteArtifacts(ctx context.Context, task *model.Task) error {
+	if p.artifacts == nil {
+		return nil
+	}
+	var errs []error
+	for _, a := range p.Snapshot(task).Artifacts {
+		if err := p.artifacts.Delete(ctx, artifactKey(task, a.Name)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
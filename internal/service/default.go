@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"workmateTestProject/internal/blob"
+	"workmateTestProject/internal/model"
+	"workmateTestProject/internal/storage"
+	"workmateTestProject/internal/tasklog"
+)
+
+// Функции пакета работают с обработчиком по умолчанию (Default) и повторяют одноимённые методы Processor.
+
+// Now возвращает текущее время по часам обработчика по умолчанию
+func Now() time.Time { return defaultProcessor.Now() }
+
+// StartProcessing ставит задачу в очередь на обработку; см. Processor.StartProcessing
+func StartProcessing(task *model.Task) error { return defaultProcessor.StartProcessing(task) }
+
+// Cancel отменяет задачу; см. Processor.Cancel
+func Cancel(ctx context.Context, id uuid.UUID, actor string) error {
+	return defaultProcessor.Cancel(ctx, id, actor)
+}
+
+// Active сообщает, отложена ли задача, ожидает ли она в очереди или выполняется
+func Active(id uuid.UUID) bool { return defaultProcessor.Active(id) }
+
+// Wait ждёт, пока задача не покинет обработчик; см. Processor.Wait
+func Wait(ctx context.Context, id uuid.UUID) error { return defaultProcessor.Wait(ctx, id) }
+
+// Stats возвращает текущую загрузку очереди и слотов
+func Stats() QueueStats { return defaultProcessor.Stats() }
+
+// Shutdown прекращает приём задач и ждёт завершения запущенных; см. Processor.Shutdown
+func Shutdown(ctx context.Context) error { return defaultProcessor.Shutdown(ctx) }
+
+// Update применяет изменения к задаче; см. Processor.Update
+func Update(task *model.Task, actor string, patch json.RawMessage) error {
+	return defaultProcessor.Update(task, actor, patch)
+}
+
+// SetTenantLimits задаёт лимиты арендаторов
+func SetTenantLimits(defaults TenantLimits, overrides map[string]TenantLimits) {
+	defaultProcessor.SetTenantLimits(defaults, overrides)
+}
+
+// SetAdmissionPolicy задаёт правила приёма задач в очередь
+func SetAdmissionPolicy(policy AdmissionPolicy) { defaultProcessor.SetAdmissionPolicy(policy) }
+
+// SetMaxConcurrent меняет глобальный лимит одновременных задач
+func SetMaxConcurrent(n int) error { return defaultProcessor.SetMaxConcurrent(n) }
+
+// Pause приостанавливает запуск задач типа typ или всех задач
+func Pause(typ string) { defaultProcessor.Pause(typ) }
+
+// Resume возобновляет запуск задач типа typ или всех задач
+func Resume(typ string) { defaultProcessor.Resume(typ) }
+
+// Settings возвращает текущие настройки пула
+func Settings() PoolSettings { return defaultProcessor.Settings() }
+
+// ApplySettings применяет настройки пула
+func ApplySettings(s PoolSettings) error { return defaultProcessor.ApplySettings(s) }
+
+// SaveSettings сохраняет настройки пула в файл
+func SaveSettings(path string) error { return defaultProcessor.SaveSettings(path) }
+
+// LoadSettings загружает и применяет настройки пула из файла
+func LoadSettings(path string) error { return defaultProcessor.LoadSettings(path) }
+
+// SetResourcePools задаёт пулы ресурсов и классы типов задач
+func SetResourcePools(pools map[string]int, classes map[string]ResourceClass) error {
+	return defaultProcessor.SetResourcePools(pools, classes)
+}
+
+// RegisterExecutor назначает исполнителя задачам типа taskType; nil снимает назначение
+func RegisterExecutor(taskType string, e Executor) { defaultProcessor.RegisterExecutor(taskType, e) }
+
+// ExecutorTypes возвращает типы задач, для которых назначены исполнители
+func ExecutorTypes() []string { return defaultProcessor.ExecutorTypes() }
+
+// ValidateTask проверяет задачу так же, как при создании
+func ValidateTask(task *model.Task) error { return defaultProcessor.ValidateTask(task) }
+
+// SetHistory задаёт хранилище истории задач; вызывается при старте до приёма задач
+func SetHistory(h *storage.History) { defaultProcessor.SetHistory(h) }
+
+// RecordEvent записывает событие в историю задачи и применяет его к задаче
+func RecordEvent(task *model.Task, e model.Event) { defaultProcessor.RecordEvent(task, e) }
+
+// RecordSnapshot начинает (или продолжает) историю задачи снимком её текущего состояния
+func RecordSnapshot(task *model.Task, typ model.EventType, actor, message string) {
+	defaultProcessor.RecordSnapshot(task, typ, actor, message)
+}
+
+// Snapshot возвращает копию задачи, согласованную с применёнными к ней событиями
+func Snapshot(task *model.Task) *model.Task { return defaultProcessor.Snapshot(task) }
+
+// TaskHistory возвращает историю событий задачи
+func TaskHistory(id uuid.UUID) []model.Event { return defaultProcessor.TaskHistory(id) }
+
+// DeleteHistory удаляет историю задачи
+func DeleteHistory(id uuid.UUID) { defaultProcessor.DeleteHistory(id) }
+
+// TaskLogs возвращает журнал задачи, если задача уже запускалась
+func TaskLogs(id uuid.UUID) (*tasklog.Buffer, bool) { return defaultProcessor.TaskLogs(id) }
+
+// DeleteLogs удаляет журнал задачи
+func DeleteLogs(id uuid.UUID) error { return defaultProcessor.DeleteLogs(id) }
+
+// SetArtifactStore задаёт хранилище артефактов; вызывается при старте до приёма задач
+func SetArtifactStore(s blob.Store) { defaultProcessor.SetArtifactStore(s) }
+
+// OpenArtifact открывает артефакт задачи для чтения
+func OpenArtifact(ctx context.Context, task *model.Task, name string) (model.Artifact, blob.Object, error) {
+	return defaultProcessor.OpenArtifact(ctx, task, name)
+}
+
+// DeleteArtifacts удаляет из хранилища все артефакты задачи
+func DeleteArtifacts(ctx context.Context, task *model.Task) error {
+	return defaultProcessor.DeleteArtifacts(ctx, task)
+}
+
+// LoadSimulationProfiles загружает профили симулятора из JSON-файла
+func LoadSimulationProfiles(path string) error { return defaultProcessor.LoadSimulationProfiles(path) }
+
+// SetSimulationProfiles заменяет профили симулятора
+func SetSimulationProfiles(profiles map[string]SimulationProfile) error {
+	return defaultProcessor.SetSimulationProfiles(profiles)
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"sort"
+
+	"workmateTestProject/internal/model"
+)
+
+// Executor выполняет задачи одного типа. Execute обязан завершаться при отмене ctx;
+// результат возвращается и при ошибке, если он есть (например, ответ с неожиданным кодом).
+// Журнал, ход обработки и артефакты доступны через Logf, Progress и PutArtifact с тем же ctx.
+type Executor interface {
+	Execute(ctx context.Context, task *model.Task) (string, error)
+}
+
+// ExecutorFunc позволяет использовать функцию как Executor
+type ExecutorFunc func(ctx context.Context, task *model.Task) (string, error)
+
+// Execute вызывает f
+func (f ExecutorFunc) Execute(ctx context.Context, task *model.Task) (string, error) {
+	return f(ctx, task)
+}
+
+// PayloadValidator - необязательный интерфейс исполнителя, проверяющего payload при создании
+// и изменении задачи, чтобы ошибка была видна клиенту сразу, а не при обработке
+type PayloadValidator interface {
+	ValidatePayload(task *model.Task) error
+}
+
+// RegisterExecutor назначает исполнителя задачам типа taskType; nil снимает назначение
+func (p *Processor) RegisterExecutor(taskType string, e Executor) {
+	p.executorsMu.Lock()
+	defer p.executorsMu.Unlock()
+	if e == nil {
+		delete(p.executors, taskType)
+		return
+	}
+	p.executors[taskType] = e
+}
+
+// ExecutorTypes возвращает типы задач, для которых назначены исполнители
+func (p *Processor) ExecutorTypes() []string {
+	p.executorsMu.RLock()
+	defer p.executorsMu.RUnlock()
+	types := make([]string, 0, len(p.executors))
+	for t := range p.executors {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// executorFor возвращает исполнителя задачи
+func (p *Processor) executorFor(task *model.Task) Executor {
+	p.executorsMu.RLock()
+	e, ok := p.executors[task.Type]
+	p.executorsMu.RUnlock()
+	if ok {
+		return e
+	}
+	p.executorsMu.RLock()
+	simulate := p.simulate
+	p.executorsMu.RUnlock()
+	if simulate == nil {
+		simulate = p.simulateWork
+	}
+	return ExecutorFunc(func(ctx context.Context, _ *model.Task) (string, error) {
+		return simulate(ctx)
+	})
+}
+
+// SetSimulate задаёт функцию, выполняющую задачи типов без исполнителя; nil возвращает симулятор по профилям
+func (p *Processor) SetSimulate(f WorkFunc) {
+	p.executorsMu.Lock()
+	defer p.executorsMu.Unlock()
+	p.simulate = f
+}
+
+// ValidateTask проверяет задачу так же, как при создании: общие поля и payload для её исполнителя
+// (для задач без исполнителя - профиль симулятора в payload)
+func (p *Processor) ValidateTask(task *model.Task) error {
+	if err := task.Validate(); err != nil {
+		return err
+	}
+	p.executorsMu.RLock()
+	e, ok := p.executors[task.Type]
+	p.executorsMu.RUnlock()
+	if !ok {
+		return validateSimulation(task)
+	}
+	if v, ok := e.(PayloadValidator); ok {
+		return v.ValidatePayload(task)
+	}
+	return nil
+}
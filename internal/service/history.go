@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"workmateTestProject/internal/model"
+	"workmateTestProject/internal/storage"
+)
+
+// processorKey - ключ контекста, в котором обработчику задачи передаётся его Processor
+type processorKey struct{}
+
+// processorFromContext возвращает обработчик, выполняющий задачу ctx
+func processorFromContext(ctx context.Context) (*Processor, bool) {
+	p, ok := ctx.Value(processorKey{}).(*Processor)
+	return p, ok
+}
+
+// SetHistory задаёт хранилище истории задач; вызывается при старте до приёма задач
+func (p *Processor) SetHistory(h *storage.History) {
+	p.history = h
+}
+
+// RecordEvent записывает событие в историю задачи и применяет его к задаче.
+// Пустые Time и Actor заполняются текущим временем и model.ActorSystem.
+func (p *Processor) RecordEvent(task *model.Task, e model.Event) {
+	if e.Time.IsZero() {
+		e.Time = p.clock.Now()
+	}
+	if e.Actor == "" {
+		e.Actor = model.ActorSystem
+	}
+	p.tasksMu.Lock()
+	defer p.tasksMu.Unlock()
+	e = p.history.Append(task.ID, e)
+	task.Apply(e)
+}
+
+// Snapshot возвращает копию задачи, согласованную с применёнными к ней событиями.
+// Задачу, переданную обработчику, изменяет горутина обработки, поэтому читать её поля
+// для ответа нужно через Snapshot.
+func (p *Processor) Snapshot(task *model.Task) *model.Task {
+	p.tasksMu.RLock()
+	defer p.tasksMu.RUnlock()
+	return task.Clone()
+}
+
+// Deleted сообщает, удалена ли задача мягким удалением, не копируя её целиком
+func (p *Processor) Deleted(task *model.Task) bool {
+	p.tasksMu.RLock()
+	defer p.tasksMu.RUnlock()
+	return task.Deleted()
+}
+
+// RecordSnapshot начинает (или продолжает) историю задачи снимком её текущего состояния
+func (p *Processor) RecordSnapshot(task *model.Task, typ model.EventType, actor, message string) {
+	p.RecordEvent(task, model.Event{Type: typ, Actor: actor, Message: message, Task: task})
+}
+
+// TaskHistory возвращает историю событий задачи
+func (p *Processor) TaskHistory(id uuid.UUID) []model.Event {
+	return p.history.Events(id)
+}
+
+// DeleteHistory удаляет историю задачи
+func (p *Processor) DeleteHistory(id uuid.UUID) {
+	p.history.Delete(id)
+}
+
+// Progress сообщает о ходе обработки задачи: пишет строку в журнал задачи и событие в её историю.
+// ctx - контекст, переданный обработчику задачи; вне обработки задачи вызов ничего не делает.
+func Progress(ctx context.Context, format string, args ...any) {
+	task, ok := TaskFromContext(ctx)
+	p, _ := processorFromContext(ctx)
+	if !ok || p == nil {
+		return
+	}
+	msg := fmt.Sprintf(format, args...)
+	Logf(ctx, "%s", msg)
+	p.RecordEvent(task, model.Event{Type: model.EventProgress, Message: msg})
+}
@@ -0,0 +1,32 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"workmateTestProject/internal/tasklog"
+)
+
+// logKey - ключ контекста с журналом обрабатываемой задачи
+type logKey struct{}
+
+// Logf пишет строку в журнал обрабатываемой задачи с временем по часам обработчика.
+// ctx - контекст, переданный обработчику задачи; вне обработки задачи вызов ничего не делает.
+func Logf(ctx context.Context, format string, args ...any) {
+	b, ok := ctx.Value(logKey{}).(*tasklog.Buffer)
+	p, _ := processorFromContext(ctx)
+	if ok && p != nil {
+		b.Append(p.clock.Now(), fmt.Sprintf(format, args...))
+	}
+}
+
+// TaskLogs возвращает журнал задачи, если задача уже запускалась
+func (p *Processor) TaskLogs(id uuid.UUID) (*tasklog.Buffer, bool) {
+	return p.logs.Get(id)
+}
+
+// DeleteLogs удаляет журнал задачи
+func (p *Processor) DeleteLogs(id uuid.UUID) error {
+	return p.logs.Delete(id)
+}
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"sort"
+)
+
+// PoolSettings - изменяемые во время работы настройки пула обработчиков
+type PoolSettings struct {
+	MaxConcurrent int      `json:"max_concurrent"`
+	Paused        bool     `json:"paused"`
+	PausedTypes   []string `json:"paused_types,omitempty"`
+}
+
+// SetMaxConcurrent меняет размер пула. При увеличении ожидающие задачи запускаются сразу,
+// при уменьшении выполняющиеся задачи не прерываются - пул сокращается по мере их завершения.
+func (p *Processor) SetMaxConcurrent(n int) error {
+	if n <= 0 {
+		return errors.New("размер пула должен быть положительным")
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.maxConcurrent = n
+	for name, ts := range p.tenants {
+		ts.slots = p.tenantSlots(p.tenantLimits(name))
+	}
+	p.dispatchLocked()
+	return nil
+}
+
+// Pause приостанавливает запуск новых задач типа typ или всех задач, если typ пуст.
+// Уже выполняющиеся задачи продолжают работу, новые задачи принимаются в очередь.
+func (p *Processor) Pause(typ string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if typ == "" {
+		p.paused = true
+		return
+	}
+	p.pausedTypes[typ] = true
+}
+
+// Resume возобновляет запуск задач типа typ или всех задач, если typ пуст
+func (p *Processor) Resume(typ string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if typ == "" {
+		p.paused = false
+	} else {
+		delete(p.pausedTypes, typ)
+	}
+	p.dispatchLocked()
+}
+
+// Settings возвращает текущие настройки пула
+func (p *Processor) Settings() PoolSettings {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s := PoolSettings{MaxConcurrent: p.maxConcurrent, Paused: p.paused}
+	for typ := range p.pausedTypes {
+		s.PausedTypes = append(s.PausedTypes, typ)
+	}
+	sort.Strings(s.PausedTypes)
+	return s
+}
+
+// ApplySettings применяет сохранённые настройки пула
+func (p *Processor) ApplySettings(s PoolSettings) error {
+	if s.MaxConcurrent > 0 {
+		if err := p.SetMaxConcurrent(s.MaxConcurrent); err != nil {
+			return err
+		}
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.paused = s.Paused
+	p.pausedTypes = make(map[string]bool, len(s.PausedTypes))
+	for _, typ := range s.PausedTypes {
+		p.pausedTypes[typ] = true
+	}
+	p.dispatchLocked()
+	return nil
+}
+
+// SaveSettings сохраняет текущие настройки пула в JSON-файл
+func (p *Processor) SaveSettings(path string) error {
+	data, err := json.MarshalIndent(p.Settings(), "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// LoadSettings загружает и применяет настройки пула из JSON-файла; отсутствие файла не считается ошибкой
+func (p *Processor) LoadSettings(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var s PoolSettings
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	return p.ApplySettings(s)
+}
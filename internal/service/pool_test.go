@@ -0,0 +1,65 @@
+package service
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+	"workmateTestProject/internal/model"
+)
+
+// TestPool_ResizeAndPause проверяет изменение размера пула на лету, паузу по типу и сохранение настроек.
+func TestPool_ResizeAndPause(t *testing.T) {
+	t.Parallel()
+	release := make(chan struct{})
+	defer close(release)
+	p, _ := newTestProcessor(t, 10, func(ctx context.Context) (string, error) {
+		<-release
+		return "ok", nil
+	})
+
+	if err := p.SetMaxConcurrent(1); err != nil {
+		t.Fatalf("SetMaxConcurrent: %v", err)
+	}
+	p.Pause("report")
+	first := &model.Task{ID: uuid.New()}
+	second := &model.Task{ID: uuid.New()}
+	report := &model.Task{ID: uuid.New(), Type: "report"}
+	for _, task := range []*model.Task{first, second, report} {
+		if err := p.StartProcessing(task); err != nil {
+			t.Fatalf("StartProcessing: %v", err)
+		}
+	}
+	if st := p.Stats(); st.Running != 1 || st.Queued != 2 {
+		t.Fatalf("при размере пула 1 ожидалась 1 задача в работе и 2 в очереди: %+v", st)
+	}
+
+	// Увеличение пула сразу запускает ожидающие задачи, кроме приостановленного типа
+	if err := p.SetMaxConcurrent(3); err != nil {
+		t.Fatalf("SetMaxConcurrent: %v", err)
+	}
+	if st := p.Stats(); st.Running != 2 || st.Queued != 1 {
+		t.Errorf("после увеличения пула ожидалось 2 задачи в работе и 1 в очереди: %+v", st)
+	}
+
+	path := filepath.Join(t.TempDir(), "pool.json")
+	if err := p.SaveSettings(path); err != nil {
+		t.Fatalf("SaveSettings: %v", err)
+	}
+	p.Resume("report")
+	if st := p.Stats(); st.Running != 3 {
+		t.Errorf("после возобновления типа report ожидалось 3 задачи в работе: %+v", st)
+	}
+
+	// Загруженные настройки восстанавливают размер пула и паузу типа
+	if err := p.LoadSettings(path); err != nil {
+		t.Fatalf("LoadSettings: %v", err)
+	}
+	if s := p.Settings(); s.MaxConcurrent != 3 || len(s.PausedTypes) != 1 || s.PausedTypes[0] != "report" {
+		t.Errorf("неверные восстановленные настройки: %+v", s)
+	}
+	if err := p.SetMaxConcurrent(0); err == nil {
+		t.Errorf("ожидалась ошибка для нулевого размера пула")
+	}
+}
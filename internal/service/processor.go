@@ -1,64 +1,724 @@
 package service
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"math/rand"
+	"log"
 	"os"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/google/uuid"
+	"workmateTestProject/internal/blob"
+	"workmateTestProject/internal/clock"
 	"workmateTestProject/internal/model"
+	"workmateTestProject/internal/storage"
+	"workmateTestProject/internal/tasklog"
 )
 
-// SimulateWorkFunc указывает на функцию-симулятор, может быть переопределена в тестах
-var SimulateWorkFunc = simulateWork
+// ErrShuttingDown возвращается StartProcessing, если сервис уже начал завершение работы
+var ErrShuttingDown = errors.New("сервис завершает работу, новые задачи не принимаются")
 
-// maxConcurrent - максимальное число одновременно обрабатываемых задач
-var (
-	maxConcurrent int
-	sem           chan struct{}
+// ErrQueueFull возвращается StartProcessing, если у арендатора уже исчерпан лимит ожидающих задач
+var ErrQueueFull = errors.New("превышен лимит задач в очереди арендатора")
+
+// ErrOverloaded возвращается StartProcessing, если общая очередь заполнена и задачу некуда поставить
+var ErrOverloaded = errors.New("очередь задач переполнена")
+
+// ErrCanceled - причина отмены выполняющейся задачи по запросу клиента
+var ErrCanceled = errors.New("задача отменена")
+
+// ErrTypeQueueFull возвращается StartProcessing, если исчерпан лимит ожидающих задач данного типа
+var ErrTypeQueueFull = errors.New("превышен лимит задач этого типа в очереди")
+
+// ErrTimeout - причина отмены задачи, не уложившейся в свой Timeout
+var ErrTimeout = errors.New("превышено время обработки задачи")
+
+// TenantLimits описывает ограничения арендатора. Нулевое значение поля означает отсутствие ограничения.
+type TenantLimits struct {
+	// MaxConcurrent - доля глобального MAX_CONCURRENT_TASKS, доступная арендатору
+	MaxConcurrent int
+	// MaxQueued - максимальное число задач арендатора, ожидающих свободного слота
+	MaxQueued int
+}
+
+// ShedPolicy определяет поведение при заполненной общей очереди
+type ShedPolicy string
+
+const (
+	// ShedReject отклоняет новую задачу
+	ShedReject ShedPolicy = "reject"
+	// ShedDropLowest вытесняет ожидающую задачу с наименьшим приоритетом, если у новой приоритет выше
+	ShedDropLowest ShedPolicy = "drop-lowest"
 )
 
+// AdmissionPolicy описывает правила приёма задач в очередь. Нулевые лимиты означают отсутствие ограничения.
+type AdmissionPolicy struct {
+	// MaxQueued - общий лимит ожидающих задач
+	MaxQueued int
+	// Shed - политика сброса нагрузки при заполненной очереди
+	Shed ShedPolicy
+	// TypeMaxQueued - лимиты ожидающих задач по типам
+	TypeMaxQueued map[string]int
+}
+
+// QueueStats описывает текущую загрузку обработчика
+type QueueStats struct {
+	Queued        int `json:"queued"`
+	Scheduled     int `json:"scheduled"`
+	MaxQueued     int `json:"max_queued,omitempty"`
+	Running       int `json:"running"`
+	MaxConcurrent int `json:"max_concurrent"`
+	// Pressure - заполненность очереди от 0 до 1 (без лимита очереди - занятость слотов)
+	Pressure float64 `json:"pressure"`
+	// Pools - загрузка пулов ресурсов
+	Pools []ResourcePoolStats `json:"pools,omitempty"`
+}
+
+// tenantState - занятые слоты и число ожидающих задач одного арендатора
+type tenantState struct {
+	slots   int
+	running int
+	queued  int
+}
+
+// activeTask - выполняющаяся задача: отмена её контекста и канал, закрываемый по завершении
+type activeTask struct {
+	cancel context.CancelCauseFunc
+	done   chan struct{}
+	// canceledBy - кто запросил отмену; записывается в историю задачи
+	canceledBy string
+}
+
+// queuedTask - задача, ожидающая свободного слота
+type queuedTask struct {
+	task *model.Task
+	ts   *tenantState
+}
+
+// WorkFunc выполняет задачу типа без исполнителя; задача доступна через TaskFromContext.
+// Функция обязана завершаться при отмене ctx.
+type WorkFunc func(ctx context.Context) (string, error)
+
+// Options - зависимости обработчика. Нулевые значения заменяются значениями по умолчанию.
+type Options struct {
+	// Clock - источник времени для событий, отложенных задач, Timeout и симулятора; nil - системные часы
+	Clock clock.Clock
+	// MaxConcurrent - максимальное число одновременно обрабатываемых задач (по умолчанию 10)
+	MaxConcurrent int
+	// History - хранилище истории задач; nil - новая история в памяти
+	History *storage.History
+	// Logs - журналы задач; nil - журналы в памяти по 1000 строк
+	Logs *tasklog.Store
+	// Simulate выполняет задачи типов без исполнителя; nil - симулятор по профилям
+	Simulate WorkFunc
+	// CancelGrace - сколько Shutdown ждёт выхода задач, отменённых по истечении его ctx (по умолчанию 5 с).
+	// Отсчитывается по системным часам: это ожидание горутин, а не время задач.
+	CancelGrace time.Duration
+}
+
+// Processor - обработчик задач: очередь с приоритетами, лимиты арендаторов и типов,
+// пулы ресурсов, отложенные и выполняющиеся задачи. Обработчики независимы,
+// поэтому тесты создают собственные экземпляры с поддельными часами и выполняются параллельно.
+type Processor struct {
+	clock     clock.Clock
+	logs      *tasklog.Store
+	history   *storage.History
+	artifacts blob.Store
+
+	// tasksMu защищает поля задач, изменяемые RecordEvent; захватывается после mu
+	tasksMu sync.RWMutex
+
+	// mu защищает состояние очереди и выполняющихся задач
+	mu            sync.Mutex
+	maxConcurrent int
+	running       int
+	draining      bool
+	inflight      sync.WaitGroup
+	cancelGrace   time.Duration
+	// active - выполняющиеся задачи
+	active map[uuid.UUID]*activeTask
+	// scheduled - отложенные задачи
+	scheduled map[uuid.UUID]*scheduledTask
+	// changed закрывается и заменяется, когда задача покидает обработчик; его ждёт Wait
+	changed chan struct{}
+
+	// paused приостанавливает запуск всех задач, pausedTypes - задач отдельных типов
+	paused      bool
+	pausedTypes map[string]bool
+
+	// queue упорядочена по убыванию приоритета, при равном приоритете - по времени постановки
+	queue      []*queuedTask
+	typeQueued map[string]int
+	admission  AdmissionPolicy
+
+	tenantDefaults  TenantLimits
+	tenantOverrides map[string]TenantLimits
+	tenants         map[string]*tenantState
+
+	// Пулы ресурсов и отображение типов задач на них; типы без класса ограничены только общим пулом
+	resourcePools   map[string]*resourcePool
+	resourceClasses map[string]ResourceClass
+
+	// executors - исполнители по типам задач; задачи остальных типов выполняет simulate
+	executorsMu sync.RWMutex
+	executors   map[string]Executor
+	simulate    WorkFunc
+
+	// simulators - профили симулятора по типам задач; "*" - для остальных типов
+	simulatorsMu sync.RWMutex
+	simulators   map[string]*simulator
+}
+
+// NewProcessor создаёт обработчик задач
+func NewProcessor(opts Options) *Processor {
+	p := &Processor{
+		clock:           opts.Clock,
+		logs:            opts.Logs,
+		history:         opts.History,
+		maxConcurrent:   opts.MaxConcurrent,
+		cancelGrace:     opts.CancelGrace,
+		active:          make(map[uuid.UUID]*activeTask),
+		scheduled:       make(map[uuid.UUID]*scheduledTask),
+		changed:         make(chan struct{}),
+		pausedTypes:     make(map[string]bool),
+		typeQueued:      make(map[string]int),
+		admission:       AdmissionPolicy{Shed: ShedReject},
+		tenants:         make(map[string]*tenantState),
+		resourcePools:   make(map[string]*resourcePool),
+		resourceClasses: make(map[string]ResourceClass),
+		executors:       make(map[string]Executor),
+		simulate:        opts.Simulate,
+		simulators:      map[string]*simulator{"*": newSimulator(DefaultSimulationProfile)},
+	}
+	if p.clock == nil {
+		p.clock = clock.Real()
+	}
+	if p.logs == nil {
+		p.logs, _ = tasklog.NewStore(1000, "")
+	}
+	if p.history == nil {
+		p.history = storage.NewHistory()
+	}
+	if p.maxConcurrent <= 0 {
+		p.maxConcurrent = 10
+	}
+	if p.cancelGrace <= 0 {
+		p.cancelGrace = 5 * time.Second
+	}
+	return p
+}
+
+// defaultProcessor - обработчик, с которым работают функции пакета; настраивается переменными окружения
+var defaultProcessor *Processor
+
+// Default возвращает обработчик по умолчанию
+func Default() *Processor {
+	return defaultProcessor
+}
+
+// Now возвращает текущее время по часам обработчика
+func (p *Processor) Now() time.Time {
+	return p.clock.Now()
+}
+
+// Clock возвращает часы обработчика
+func (p *Processor) Clock() clock.Clock {
+	return p.clock
+}
+
 func init() {
-	// Инициализируем семафор по переменной окружения
-	limit := os.Getenv("MAX_CONCURRENT_TASKS")
-	if n, err := strconv.Atoi(limit); err == nil && n > 0 {
-		maxConcurrent = n
+	opts := Options{}
+	// Инициализируем лимит одновременных задач по переменной окружения
+	if n, err := strconv.Atoi(os.Getenv("MAX_CONCURRENT_TASKS")); err == nil && n > 0 {
+		opts.MaxConcurrent = n
+	}
+	// Журналы задач: размер буфера задаёт TASK_LOG_LINES, каталог для сохранения на диск - TASK_LOG_DIR
+	capacity := 1000
+	if n, err := strconv.Atoi(os.Getenv("TASK_LOG_LINES")); err == nil && n > 0 {
+		capacity = n
+	}
+	var err error
+	if opts.Logs, err = tasklog.NewStore(capacity, os.Getenv("TASK_LOG_DIR")); err != nil {
+		log.Printf("Каталог журналов задач недоступен, журналы хранятся только в памяти: %v", err)
+		opts.Logs, _ = tasklog.NewStore(capacity, "")
+	}
+	p := NewProcessor(opts)
+	defaultProcessor = p
+
+	// Лимиты арендаторов: TENANT_MAX_CONCURRENT и TENANT_MAX_QUEUED задают значения по умолчанию,
+	// TENANT_LIMITS - переопределения в формате "арендатор:слоты:очередь,..."
+	defaults := TenantLimits{}
+	if n, err := strconv.Atoi(os.Getenv("TENANT_MAX_CONCURRENT")); err == nil && n > 0 {
+		defaults.MaxConcurrent = n
+	}
+	if n, err := strconv.Atoi(os.Getenv("TENANT_MAX_QUEUED")); err == nil && n > 0 {
+		defaults.MaxQueued = n
+	}
+	overrides, err := ParseTenantLimits(os.Getenv("TENANT_LIMITS"))
+	if err != nil {
+		log.Printf("Неверное значение TENANT_LIMITS, переопределения проигнорированы: %v", err)
+	}
+	p.SetTenantLimits(defaults, overrides)
+
+	// Приём задач: MAX_QUEUED_TASKS, SHED_POLICY и TYPE_MAX_QUEUED в формате "тип=лимит,..."
+	policy := AdmissionPolicy{Shed: ShedPolicy(os.Getenv("SHED_POLICY"))}
+	if n, err := strconv.Atoi(os.Getenv("MAX_QUEUED_TASKS")); err == nil && n > 0 {
+		policy.MaxQueued = n
+	}
+	if policy.Shed != ShedDropLowest {
+		policy.Shed = ShedReject
+	}
+	if policy.TypeMaxQueued, err = ParseTypeLimits(os.Getenv("TYPE_MAX_QUEUED")); err != nil {
+		log.Printf("Неверное значение TYPE_MAX_QUEUED, лимиты типов проигнорированы: %v", err)
+	}
+	p.SetAdmissionPolicy(policy)
+
+	// Пулы ресурсов: RESOURCE_POOLS="reports=4,notify=20", TYPE_RESOURCES="report=reports:3,email=notify"
+	pools, err := ParseResourcePools(os.Getenv("RESOURCE_POOLS"))
+	if err == nil {
+		var classes map[string]ResourceClass
+		if classes, err = ParseResourceClasses(os.Getenv("TYPE_RESOURCES")); err == nil {
+			err = p.SetResourcePools(pools, classes)
+		}
+	}
+	if err != nil {
+		log.Printf("Неверная конфигурация пулов ресурсов, пулы не используются: %v", err)
+	}
+}
+
+// ParseTenantLimits разбирает строку вида "team-a:4:100,team-b:2:0" в переопределения лимитов арендаторов
+func ParseTenantLimits(spec string) (map[string]TenantLimits, error) {
+	limits := make(map[string]TenantLimits)
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		parts := strings.Split(item, ":")
+		if len(parts) != 3 || parts[0] == "" {
+			return nil, fmt.Errorf("ожидался формат арендатор:слоты:очередь, получили %q", item)
+		}
+		conc, err1 := strconv.Atoi(parts[1])
+		queued, err2 := strconv.Atoi(parts[2])
+		if err1 != nil || err2 != nil || conc < 0 || queued < 0 {
+			return nil, fmt.Errorf("неверные числа в %q", item)
+		}
+		limits[parts[0]] = TenantLimits{MaxConcurrent: conc, MaxQueued: queued}
+	}
+	return limits, nil
+}
+
+// ParseTypeLimits разбирает строку вида "report=50,email=1000" в лимиты по типам задач
+func ParseTypeLimits(spec string) (map[string]int, error) {
+	limits := make(map[string]int)
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		typ, n, ok := strings.Cut(item, "=")
+		v, err := strconv.Atoi(n)
+		if !ok || typ == "" || err != nil || v < 0 {
+			return nil, fmt.Errorf("ожидался формат тип=лимит, получили %q", item)
+		}
+		limits[typ] = v
+	}
+	return limits, nil
+}
+
+// SetTenantLimits задаёт лимиты арендаторов.
+// Лимит слотов арендатора не может превышать глобальный MAX_CONCURRENT_TASKS.
+func (p *Processor) SetTenantLimits(defaults TenantLimits, overrides map[string]TenantLimits) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.tenantDefaults = defaults
+	p.tenantOverrides = overrides
+	for name, ts := range p.tenants {
+		ts.slots = p.tenantSlots(p.tenantLimits(name))
+	}
+	p.dispatchLocked()
+}
+
+// SetAdmissionPolicy задаёт правила приёма задач в очередь
+func (p *Processor) SetAdmissionPolicy(policy AdmissionPolicy) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.admission = policy
+}
+
+// Stats возвращает текущую загрузку очереди и слотов
+func (p *Processor) Stats() QueueStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	st := QueueStats{
+		Queued:        len(p.queue),
+		Scheduled:     len(p.scheduled),
+		MaxQueued:     p.admission.MaxQueued,
+		Running:       p.running,
+		MaxConcurrent: p.maxConcurrent,
+		Pools:         p.resourceStatsLocked(),
+	}
+	if st.MaxQueued > 0 {
+		st.Pressure = float64(st.Queued) / float64(st.MaxQueued)
 	} else {
-		maxConcurrent = 10
+		st.Pressure = float64(st.Running) / float64(st.MaxConcurrent)
+	}
+	return st
+}
+
+// Cancel отменяет задачу: отложенная или ожидающая в очереди задача снимается, у выполняющейся
+// отменяется контекст, и Cancel ждёт завершения её обработчика, пока не истечёт ctx.
+// В обоих случаях задача получает статус Canceled, а в её историю записывается отмена от имени actor.
+// Для неактивной задачи Cancel ничего не делает.
+func (p *Processor) Cancel(ctx context.Context, id uuid.UUID, actor string) error {
+	p.mu.Lock()
+	if st, ok := p.unscheduleLocked(id); ok {
+		p.RecordEvent(st.q.task, model.Event{Type: model.EventCanceled, Actor: actor, Error: "задача отменена до начала обработки"})
+		p.notifyLocked()
+		p.mu.Unlock()
+		return nil
+	}
+	for i, q := range p.queue {
+		if q.task.ID == id {
+			p.removeLocked(i)
+			p.RecordEvent(q.task, model.Event{Type: model.EventCanceled, Actor: actor, Error: "задача отменена до начала обработки"})
+			p.notifyLocked()
+			p.mu.Unlock()
+			return nil
+		}
+	}
+	a, ok := p.active[id]
+	if ok && a.canceledBy == "" {
+		a.canceledBy = actor
+	}
+	p.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	a.cancel(ErrCanceled)
+	select {
+	case <-a.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Active сообщает, отложена ли задача, ожидает ли она в очереди или выполняется
+func (p *Processor) Active(id uuid.UUID) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.activeLocked(id)
+}
+
+// activeLocked - Active под mu
+func (p *Processor) activeLocked(id uuid.UUID) bool {
+	if _, ok := p.active[id]; ok {
+		return true
+	}
+	if _, ok := p.scheduled[id]; ok {
+		return true
+	}
+	for _, q := range p.queue {
+		if q.task.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// canStartLocked сообщает, может ли задача стартовать немедленно; вызывается под mu
+func (p *Processor) canStartLocked(task *model.Task, ts *tenantState) bool {
+	if p.paused || p.pausedTypes[task.Type] || p.running >= p.maxConcurrent || ts.running >= ts.slots {
+		return false
+	}
+	rp, weight := p.resourceFor(task.Type)
+	return rp == nil || rp.used+weight <= rp.capacity
+}
+
+// tenantLimits возвращает лимиты арендатора с учётом переопределений; вызывается под mu
+func (p *Processor) tenantLimits(name string) TenantLimits {
+	if limits, ok := p.tenantOverrides[name]; ok {
+		return limits
+	}
+	return p.tenantDefaults
+}
+
+// tenantSlots вычисляет число слотов арендатора в пределах глобального лимита; вызывается под mu
+func (p *Processor) tenantSlots(limits TenantLimits) int {
+	if limits.MaxConcurrent <= 0 || limits.MaxConcurrent > p.maxConcurrent {
+		return p.maxConcurrent
+	}
+	return limits.MaxConcurrent
+}
+
+// tenantFor возвращает состояние арендатора, создавая его при первом обращении; вызывается под mu
+func (p *Processor) tenantFor(name string) (*tenantState, TenantLimits) {
+	limits := p.tenantLimits(name)
+	ts, ok := p.tenants[name]
+	if !ok {
+		ts = &tenantState{slots: p.tenantSlots(limits)}
+		p.tenants[name] = ts
 	}
-	sem = make(chan struct{}, maxConcurrent)
+	return ts, limits
 }
 
-// simulateWork симулирует I/O-bound работу, возвращая результат или ошибку
-func simulateWork() (string, error) {
-	// Ждем случайное время от 1 до 5 минут
-	dur := time.Duration(rand.Intn(5)+1) * time.Minute
-	time.Sleep(dur)
-	// Возвращаем результат
-	return fmt.Sprintf("Обработано за %s", dur), nil
+// StartProcessing ставит задачу в очередь на обработку.
+// Задача запускается, когда свободны слот её арендатора и слот глобального пула;
+// из очереди первыми выбираются задачи с большим приоритетом.
+// Задача с RunAt в будущем откладывается и встаёт в очередь по наступлении срока.
+// Возвращает ErrShuttingDown после вызова Shutdown, ErrQueueFull при исчерпании очереди арендатора,
+// ErrTypeQueueFull при исчерпании лимита типа и ErrOverloaded при переполнении общей очереди.
+func (p *Processor) StartProcessing(task *model.Task) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.draining {
+		return ErrShuttingDown
+	}
+	ts, limits := p.tenantFor(task.Tenant)
+	if p.deferred(task) {
+		p.scheduleLocked(&queuedTask{task: task, ts: ts})
+		p.RecordEvent(task, model.Event{Type: model.EventScheduled})
+		return nil
+	}
+	if err := p.admitLocked(task, ts, limits); err != nil {
+		return err
+	}
+
+	p.enqueueLocked(&queuedTask{task: task, ts: ts})
+	p.RecordEvent(task, model.Event{Type: model.EventQueued})
+	p.dispatchLocked()
+	return nil
+}
+
+// admitLocked проверяет лимиты очереди перед постановкой задачи в очередь; лимиты проверяются,
+// только если задача не может стартовать сразу. Вызывается под mu.
+func (p *Processor) admitLocked(task *model.Task, ts *tenantState, limits TenantLimits) error {
+	if p.canStartLocked(task, ts) {
+		return nil
+	}
+	if limits.MaxQueued > 0 && ts.queued >= limits.MaxQueued {
+		return ErrQueueFull
+	}
+	if n := p.admission.TypeMaxQueued[task.Type]; n > 0 && p.typeQueued[task.Type] >= n {
+		return ErrTypeQueueFull
+	}
+	if p.admission.MaxQueued > 0 && len(p.queue) >= p.admission.MaxQueued && !p.shedLocked(task) {
+		return ErrOverloaded
+	}
+	return nil
 }
 
-// StartProcessing запускает обработку задачи с ограничением семафора
-func StartProcessing(task *model.Task) {
+// shedLocked освобождает место в очереди по политике ShedDropLowest, вытесняя
+// последнюю из задач с наименьшим приоритетом, если он ниже приоритета task; вызывается под mu
+func (p *Processor) shedLocked(task *model.Task) bool {
+	if p.admission.Shed != ShedDropLowest || len(p.queue) == 0 {
+		return false
+	}
+	victim := p.queue[len(p.queue)-1]
+	if victim.task.Priority >= task.Priority {
+		return false
+	}
+	p.removeLocked(len(p.queue) - 1)
+	p.RecordEvent(victim.task, model.Event{
+		Type:  model.EventCanceled,
+		Error: "задача вытеснена из переполненной очереди задачей с более высоким приоритетом",
+	})
+	p.notifyLocked()
+	return true
+}
+
+// enqueueLocked вставляет задачу в очередь с сохранением порядка; вызывается под mu
+func (p *Processor) enqueueLocked(q *queuedTask) {
+	i := len(p.queue)
+	for i > 0 && p.queue[i-1].task.Priority < q.task.Priority {
+		i--
+	}
+	p.queue = append(p.queue, nil)
+	copy(p.queue[i+1:], p.queue[i:])
+	p.queue[i] = q
+	q.ts.queued++
+	p.typeQueued[q.task.Type]++
+}
+
+// removeLocked удаляет i-ю задачу из очереди; вызывается под mu
+func (p *Processor) removeLocked(i int) *queuedTask {
+	q := p.queue[i]
+	p.queue = append(p.queue[:i], p.queue[i+1:]...)
+	q.ts.queued--
+	p.typeQueued[q.task.Type]--
+	return q
+}
+
+// dispatchLocked запускает задачи из очереди, пока есть свободные слоты; вызывается под mu
+func (p *Processor) dispatchLocked() {
+	if p.draining || p.paused {
+		return
+	}
+	for i := 0; i < len(p.queue) && p.running < p.maxConcurrent; {
+		q := p.queue[i]
+		// У арендатора или пула ресурсов нет свободных слотов либо тип приостановлен -
+		// пропускаем задачу, не блокируя остальных
+		if !p.canStartLocked(q.task, q.ts) {
+			i++
+			continue
+		}
+		rp, weight := p.resourceFor(q.task.Type)
+		p.startLocked(p.removeLocked(i), rp, weight)
+	}
+}
+
+// startLocked занимает слоты (и единицы пула ресурсов rp, если он задан)
+// и запускает обработку задачи в горутине; вызывается под mu
+func (p *Processor) startLocked(q *queuedTask, rp *resourcePool, weight int) {
+	task, ts := q.task, q.ts
+	logs := p.logs.Open(task.ID)
+	ctx := context.WithValue(context.Background(), taskKey{}, task)
+	ctx = context.WithValue(ctx, processorKey{}, p)
+	ctx, cancel := context.WithCancelCause(context.WithValue(ctx, logKey{}, logs))
+	// Отмена по Timeout отличается от отмены по запросу и при остановке причиной ErrTimeout;
+	// срок отсчитывается по часам обработчика
+	workCtx, cancelWork := context.WithCancelCause(ctx)
+	var timeout clock.Timer
+	if task.Timeout > 0 {
+		timeout = p.clock.AfterFunc(time.Duration(task.Timeout), func() { cancelWork(ErrTimeout) })
+	}
+	done := make(chan struct{})
+	at := &activeTask{cancel: cancel, done: done}
+	p.active[task.ID] = at
+	p.running++
+	ts.running++
+	if rp != nil {
+		rp.used += weight
+		rp.running++
+	}
+	p.inflight.Add(1)
+
 	go func() {
-		// Захватываем слот семафора
-		sem <- struct{}{}
-		defer func() { <-sem }()
-
-		task.Status = model.StatusInProgress
-		now := time.Now()
-		task.StartedAt = &now
-
-		result, err := SimulateWorkFunc()
-		finish := time.Now()
-		task.FinishedAt = &finish
-		if err != nil {
-			task.Status = model.StatusFailed
-			task.Error = err.Error()
+		defer p.inflight.Done()
+		defer func() {
+			if timeout != nil {
+				timeout.Stop()
+			}
+			cancelWork(nil)
+			cancel(nil)
+			p.mu.Lock()
+			delete(p.active, task.ID)
+			close(done)
+			p.running--
+			ts.running--
+			if rp != nil {
+				rp.used -= weight
+				rp.running--
+			}
+			p.notifyLocked()
+			p.dispatchLocked()
+			p.mu.Unlock()
+		}()
+
+		p.RecordEvent(task, model.Event{Type: model.EventStarted})
+		logs.Append(p.clock.Now(), "Обработка начата")
+		// Журнал закрывается последним, чтобы читатели увидели итоговый статус
+		defer logs.Finish()
+
+		result, err := p.executorFor(task).Execute(workCtx, task)
+		var e model.Event
+		switch {
+		case errors.Is(context.Cause(ctx), ErrCanceled):
+			p.mu.Lock()
+			actor := at.canceledBy
+			p.mu.Unlock()
+			e = model.Event{Type: model.EventCanceled, Actor: actor, Error: "обработка отменена по запросу"}
+		case ctx.Err() != nil:
+			e = model.Event{Type: model.EventInterrupted, Error: "обработка прервана при остановке сервиса"}
+		case errors.Is(context.Cause(workCtx), ErrTimeout):
+			e = model.Event{Type: model.EventFailed, Error: fmt.Sprintf("превышено время обработки (%s)", time.Duration(task.Timeout))}
+		case err != nil:
+			e = model.Event{Type: model.EventFailed, Result: result, Error: err.Error()}
+		default:
+			e = model.Event{Type: model.EventCompleted, Result: result}
+		}
+		p.RecordEvent(task, e)
+		if task.Error != "" {
+			logs.Append(p.clock.Now(), fmt.Sprintf("Обработка завершена со статусом %s: %s", task.Status, task.Error))
 		} else {
-			task.Status = model.StatusCompleted
-			task.Result = result
+			logs.Append(p.clock.Now(), fmt.Sprintf("Обработка завершена со статусом %s", task.Status))
 		}
 	}()
 }
+
+// notifyLocked будит ожидающих в Wait: задача покинула очередь, отложенные или выполняющиеся;
+// вызывается под mu
+func (p *Processor) notifyLocked() {
+	close(p.changed)
+	p.changed = make(chan struct{})
+}
+
+// Wait ждёт, пока задача не покинет обработчик (не будет завершена, отменена или снята
+// при остановке), или истечения ctx. Для неактивной задачи возвращается сразу.
+func (p *Processor) Wait(ctx context.Context, id uuid.UUID) error {
+	for {
+		p.mu.Lock()
+		if !p.activeLocked(id) {
+			p.mu.Unlock()
+			return nil
+		}
+		changed := p.changed
+		p.mu.Unlock()
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Shutdown прекращает приём новых задач и ждёт завершения уже запущенных, пока не истечёт ctx.
+// Оставшиеся по истечении ctx задачи отменяются и получают статус Interrupted,
+// а ещё не начатые остаются в статусе Pending (или Scheduled), чтобы их можно было поставить в очередь повторно.
+// Выхода отменённых задач Shutdown ждёт не дольше CancelGrace: исполнитель, не реагирующий на отмену,
+// не должен блокировать остановку сервиса.
+func (p *Processor) Shutdown(ctx context.Context) error {
+	p.mu.Lock()
+	p.draining = true
+	for len(p.queue) > 0 {
+		p.removeLocked(len(p.queue) - 1)
+	}
+	// Отложенные задачи сохраняют статус Scheduled и будут отложены заново после перезапуска
+	for id := range p.scheduled {
+		p.unscheduleLocked(id)
+	}
+	p.notifyLocked()
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.inflight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+	}
+
+	// Время на дренаж истекло - отменяем всё, что ещё выполняется
+	p.mu.Lock()
+	for _, a := range p.active {
+		a.cancel(nil)
+	}
+	p.mu.Unlock()
+	grace := time.NewTimer(p.cancelGrace)
+	defer grace.Stop()
+	select {
+	case <-done:
+	case <-grace.C:
+		log.Printf("Не все отменённые задачи завершились за %s, остановка продолжается без них", p.cancelGrace)
+	}
+	return ctx.Err()
+}
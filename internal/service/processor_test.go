@@ -1,41 +1,58 @@
 package service
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/google/uuid"
+	"workmateTestProject/internal/clock"
 	"workmateTestProject/internal/model"
 )
 
+// newTestProcessor создаёт для теста собственный обработчик с поддельными часами, чтобы тесты
+// не делили состояние и выполнялись параллельно. Задачи без исполнителя выполняет work.
+func newTestProcessor(t *testing.T, maxConcurrent int, work WorkFunc) (*Processor, *clock.Fake) {
+	t.Helper()
+	clk := clock.NewFake(time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC))
+	p := NewProcessor(Options{Clock: clk, MaxConcurrent: maxConcurrent, Simulate: work})
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		p.Shutdown(ctx)
+	})
+	return p, clk
+}
+
+// waitDone ждёт, пока задача не покинет обработчик
+func waitDone(t *testing.T, p *Processor, task *model.Task) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := p.Wait(ctx, task.ID); err != nil {
+		t.Fatalf("задача %s не завершилась: %v", task.ID, err)
+	}
+}
+
 // TestStartProcessing_Success проверяет корректное обновление полей Task при успешном завершении обработки.
-// Мы заменяем simulateWorkFunc на быстрый заглушечный вариант, чтобы тест шел мгновенно.
+// Вместо симулятора задачу выполняет мгновенная заглушка, чтобы тест шел мгновенно.
 func TestStartProcessing_Success(t *testing.T) {
-	orig := SimulateWorkFunc
-	defer func() { SimulateWorkFunc = orig }()
-
-	// Подменяем функцию работы на мгновенную
-	SimulateWorkFunc = func() (string, error) {
+	t.Parallel()
+	p, clk := newTestProcessor(t, 1, func(ctx context.Context) (string, error) {
 		// Возвращаем заранее известный результат
 		return "stub-result", nil
-	}
-
-	// Создаем новую задачу с неопределенным статусом
-	id := uuid.New()
-	task := &model.Task{ID: id, Status: model.StatusPending}
+	})
 
-	// Запускаем обработку
-	StartProcessing(task)
-
-	// Ожидаем выполнения горутины (макс 1 сек)
-	deadline := time.Now().Add(time.Second)
-	for time.Now().Before(deadline) {
-		if task.Status != model.StatusPending {
-			break
-		}
-		time.Sleep(10 * time.Millisecond)
+	// Создаем новую задачу с неопределенным статусом и запускаем обработку
+	task := &model.Task{ID: uuid.New(), Status: model.StatusPending}
+	if err := p.StartProcessing(task); err != nil {
+		t.Fatalf("StartProcessing вернул ошибку: %v", err)
 	}
+	waitDone(t, p, task)
 
 	// Проверяем, что статус стал Completed
 	if task.Status != model.StatusCompleted {
@@ -47,38 +64,31 @@ func TestStartProcessing_Success(t *testing.T) {
 		t.Errorf("ожидался Result stub-result, получили %v", task.Result)
 	}
 
-	// Проверяем, что поля StartedAt и FinishedAt заполнены и FinishedAt позже StartedAt
+	// Проверяем, что поля StartedAt и FinishedAt заполнены по часам обработчика
 	if task.StartedAt == nil || task.FinishedAt == nil {
 		t.Fatal("Ожидалось, что StartedAt и FinishedAt не nil после обработки")
 	}
-	if task.FinishedAt.Before(*task.StartedAt) {
-		t.Errorf("FinishedAt должно быть позже StartedAt, got %v before %v", task.FinishedAt, task.StartedAt)
+	if !task.StartedAt.Equal(clk.Now()) || !task.FinishedAt.Equal(clk.Now()) {
+		t.Errorf("время обработки должно браться из часов обработчика: %v, %v", task.StartedAt, task.FinishedAt)
+	}
+	buf, _ := p.TaskLogs(task.ID)
+	if lines, _ := buf.Lines(0, 0); len(lines) != 2 || !lines[0].Time.Equal(clk.Now()) {
+		t.Errorf("строки журнала должны получать время из часов обработчика: %+v", lines)
 	}
 }
 
-// TestStartProcessing_Failure проверяет поведение при ошибке simulateWorkFunc.
+// TestStartProcessing_Failure проверяет поведение при ошибке функции работы.
 func TestStartProcessing_Failure(t *testing.T) {
-	orig := SimulateWorkFunc
-	defer func() { SimulateWorkFunc = orig }()
-
-	// Подменяем функцию на возвращающую ошибку
-	SimulateWorkFunc = func() (string, error) {
+	t.Parallel()
+	// Функция работы возвращает ошибку
+	p, _ := newTestProcessor(t, 1, func(ctx context.Context) (string, error) {
 		return "", fmt.Errorf("simulated error")
-	}
+	})
 
-	// Создаем задачу и запускаем обработку
-	id := uuid.New()
-	task := &model.Task{ID: id, Status: model.StatusPending}
-	StartProcessing(task)
-
-	// Ждем завершения
-	deadline := time.Now().Add(time.Second)
-	for time.Now().Before(deadline) {
-		if task.Status != model.StatusPending {
-			break
-		}
-		time.Sleep(10 * time.Millisecond)
-	}
+	// Создаем задачу, запускаем обработку и ждем завершения
+	task := &model.Task{ID: uuid.New(), Status: model.StatusPending}
+	p.StartProcessing(task)
+	waitDone(t, p, task)
 
 	// Ожидаем статус Failed
 	if task.Status != model.StatusFailed {
@@ -90,3 +100,173 @@ func TestStartProcessing_Failure(t *testing.T) {
 		t.Errorf("ожидалась ошибка simulated error, получили %v", task.Error)
 	}
 }
+
+// TestShutdown_InterruptsAndRejects проверяет, что Shutdown прерывает задачи, не успевшие завершиться
+// за отведённое время, оставляет неначатые задачи в Pending и запрещает запуск новых.
+func TestShutdown_InterruptsAndRejects(t *testing.T) {
+	t.Parallel()
+	// Один слот: первая задача выполняется, вторая ждёт в очереди
+	started := make(chan struct{}, 1)
+	p, _ := newTestProcessor(t, 1, func(ctx context.Context) (string, error) {
+		started <- struct{}{}
+		<-ctx.Done()
+		return "", ctx.Err()
+	})
+
+	running := &model.Task{ID: uuid.New(), Status: model.StatusPending}
+	queued := &model.Task{ID: uuid.New(), Status: model.StatusPending}
+	if err := p.StartProcessing(running); err != nil {
+		t.Fatalf("StartProcessing вернул ошибку: %v", err)
+	}
+	<-started
+	if err := p.StartProcessing(queued); err != nil {
+		t.Fatalf("StartProcessing вернул ошибку: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := p.Shutdown(ctx); err == nil {
+		t.Errorf("ожидалась ошибка истечения времени дренажа")
+	}
+
+	if running.Status != model.StatusInterrupted {
+		t.Errorf("ожидался статус Interrupted, получили %v", running.Status)
+	}
+	if queued.Status != model.StatusPending {
+		t.Errorf("ожидался статус Pending для неначатой задачи, получили %v", queued.Status)
+	}
+	if err := p.StartProcessing(&model.Task{ID: uuid.New()}); err != ErrShuttingDown {
+		t.Errorf("ожидалась ErrShuttingDown после Shutdown, получили %v", err)
+	}
+}
+
+// TestShutdown_StuckTask проверяет, что исполнитель, не реагирующий на отмену, не блокирует Shutdown:
+// после истечения ctx обработчик ждёт отменённые задачи не дольше CancelGrace.
+func TestShutdown_StuckTask(t *testing.T) {
+	t.Parallel()
+	started, release := make(chan struct{}), make(chan struct{})
+	defer close(release)
+	p := NewProcessor(Options{CancelGrace: 10 * time.Millisecond, Simulate: func(ctx context.Context) (string, error) {
+		close(started)
+		<-release
+		return "", nil
+	}})
+	if err := p.StartProcessing(&model.Task{ID: uuid.New(), Status: model.StatusPending}); err != nil {
+		t.Fatalf("StartProcessing вернул ошибку: %v", err)
+	}
+	<-started
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	result := make(chan error, 1)
+	go func() { result <- p.Shutdown(ctx) }()
+	select {
+	case err := <-result:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("ожидалась ошибка context.Canceled, получили %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Shutdown не вернулся после отмены зависшей задачи")
+	}
+}
+
+// TestStartProcessing_TenantQueueLimit проверяет, что при исчерпании очереди арендатора
+// StartProcessing возвращает ErrQueueFull, не затрагивая других арендаторов.
+func TestStartProcessing_TenantQueueLimit(t *testing.T) {
+	t.Parallel()
+	release := make(chan struct{})
+	defer close(release)
+	p, _ := newTestProcessor(t, 10, func(ctx context.Context) (string, error) {
+		<-release
+		return "ok", nil
+	})
+
+	// Один слот и одно место в очереди: третья задача должна быть отклонена
+	p.SetTenantLimits(TenantLimits{}, map[string]TenantLimits{"team-a": {MaxConcurrent: 1, MaxQueued: 1}})
+	if err := p.StartProcessing(&model.Task{ID: uuid.New(), Tenant: "team-a"}); err != nil {
+		t.Fatalf("StartProcessing вернул ошибку: %v", err)
+	}
+	if err := p.StartProcessing(&model.Task{ID: uuid.New(), Tenant: "team-a"}); err != nil {
+		t.Fatalf("вторая задача должна встать в очередь, получили %v", err)
+	}
+	if err := p.StartProcessing(&model.Task{ID: uuid.New(), Tenant: "team-a"}); err != ErrQueueFull {
+		t.Errorf("ожидалась ErrQueueFull, получили %v", err)
+	}
+	if err := p.StartProcessing(&model.Task{ID: uuid.New(), Tenant: "team-b"}); err != nil {
+		t.Errorf("лимит team-a не должен влиять на team-b, получили %v", err)
+	}
+}
+
+// TestAdmission_ShedAndPriority проверяет общий лимит очереди, вытеснение задачи с наименьшим приоритетом,
+// лимит по типу и порядок запуска ожидающих задач по приоритету.
+func TestAdmission_ShedAndPriority(t *testing.T) {
+	t.Parallel()
+	release := make(chan struct{})
+	var (
+		orderMu sync.Mutex
+		order   []uuid.UUID
+	)
+	// Один слот и очередь на две задачи, не больше одной задачи типа report
+	p, clk := newTestProcessor(t, 1, func(ctx context.Context) (string, error) {
+		task, _ := TaskFromContext(ctx)
+		orderMu.Lock()
+		order = append(order, task.ID)
+		orderMu.Unlock()
+		<-release
+		return "ok", nil
+	})
+	p.SetAdmissionPolicy(AdmissionPolicy{MaxQueued: 2, Shed: ShedDropLowest, TypeMaxQueued: map[string]int{"report": 1}})
+
+	blocker := &model.Task{ID: uuid.New()}
+	if err := p.StartProcessing(blocker); err != nil {
+		t.Fatalf("StartProcessing(blocker): %v", err)
+	}
+	low := &model.Task{ID: uuid.New(), Priority: -1}
+	mid := &model.Task{ID: uuid.New(), Type: "report"}
+	if err := p.StartProcessing(low); err != nil {
+		t.Fatalf("StartProcessing(low): %v", err)
+	}
+	if err := p.StartProcessing(mid); err != nil {
+		t.Fatalf("StartProcessing(mid): %v", err)
+	}
+	if err := p.StartProcessing(&model.Task{ID: uuid.New(), Type: "report", Priority: 5}); err != ErrTypeQueueFull {
+		t.Errorf("ожидалась ErrTypeQueueFull, получили %v", err)
+	}
+	if err := p.StartProcessing(&model.Task{ID: uuid.New(), Priority: -1}); err != ErrOverloaded {
+		t.Errorf("ожидалась ErrOverloaded для задачи без преимущества в приоритете, получили %v", err)
+	}
+	high := &model.Task{ID: uuid.New(), Priority: 10}
+	if err := p.StartProcessing(high); err != nil {
+		t.Fatalf("задача с высоким приоритетом должна вытеснить low, получили %v", err)
+	}
+	if low.Status != model.StatusCanceled {
+		t.Errorf("ожидалось вытеснение low, статус %v", low.Status)
+	}
+	if st := p.Stats(); st.Queued != 2 || st.Running != 1 || st.Pressure != 1 {
+		t.Errorf("неверная статистика очереди: %+v", st)
+	}
+
+	// Отложенная задача, переносимая изменением в очередь, проходит те же проверки лимитов
+	runAt := clk.Now().Add(time.Hour)
+	later := &model.Task{ID: uuid.New(), Type: "report", RunAt: &runAt}
+	if err := p.StartProcessing(later); err != nil {
+		t.Fatalf("StartProcessing(later): %v", err)
+	}
+	if err := p.Update(later, "alice", json.RawMessage(`{"run_at":null}`)); err != ErrTypeQueueFull {
+		t.Errorf("ожидалась ErrTypeQueueFull при переносе в очередь, получили %v", err)
+	}
+	if later.RunAt == nil || later.Status != model.StatusScheduled || !p.Active(later.ID) || p.Stats().Queued != 2 {
+		t.Errorf("отклонённое изменение не должно менять задачу и очередь: %+v", later)
+	}
+	p.Cancel(context.Background(), later.ID, "alice")
+
+	// Отпускаем задачи и проверяем, что high стартовала раньше mid
+	close(release)
+	waitDone(t, p, mid)
+	waitDone(t, p, high)
+	orderMu.Lock()
+	defer orderMu.Unlock()
+	if len(order) != 3 || order[0] != blocker.ID || order[1] != high.ID || order[2] != mid.ID {
+		t.Errorf("задача с высоким приоритетом должна стартовать раньше: %v", order)
+	}
+}
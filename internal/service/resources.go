@@ -0,0 +1,133 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ResourceClass связывает тип задачи с пулом ресурсов и числом занимаемых единиц
+type ResourceClass struct {
+	Pool   string
+	Weight int
+}
+
+// ResourcePoolStats описывает загрузку пула ресурсов
+type ResourcePoolStats struct {
+	Name     string `json:"name"`
+	Capacity int    `json:"capacity"`
+	Used     int    `json:"used"`
+	Running  int    `json:"running"`
+	Queued   int    `json:"queued"`
+}
+
+// resourcePool - именованный пул с ограниченной ёмкостью в единицах
+type resourcePool struct {
+	capacity int
+	used     int
+	running  int
+}
+
+// ParseResourcePools разбирает строку вида "reports=4,notify=20" в ёмкости пулов
+func ParseResourcePools(spec string) (map[string]int, error) {
+	pools := make(map[string]int)
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, n, ok := strings.Cut(item, "=")
+		capacity, err := strconv.Atoi(n)
+		if !ok || name == "" || err != nil || capacity <= 0 {
+			return nil, fmt.Errorf("ожидался формат пул=ёмкость, получили %q", item)
+		}
+		pools[name] = capacity
+	}
+	return pools, nil
+}
+
+// ParseResourceClasses разбирает строку вида "report=reports:3,email=notify" (вес по умолчанию 1)
+func ParseResourceClasses(spec string) (map[string]ResourceClass, error) {
+	classes := make(map[string]ResourceClass)
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		typ, class, ok := strings.Cut(item, "=")
+		if !ok || typ == "" {
+			return nil, fmt.Errorf("ожидался формат тип=пул[:вес], получили %q", item)
+		}
+		pool, weightSpec, hasWeight := strings.Cut(class, ":")
+		weight := 1
+		if hasWeight {
+			var err error
+			if weight, err = strconv.Atoi(weightSpec); err != nil || weight <= 0 {
+				return nil, fmt.Errorf("неверный вес в %q", item)
+			}
+		}
+		classes[typ] = ResourceClass{Pool: pool, Weight: weight}
+	}
+	return classes, nil
+}
+
+// SetResourcePools задаёт пулы ресурсов и классы типов задач.
+// Занятость уже существующих пулов сохраняется, поэтому менять конфигурацию можно во время работы.
+func (p *Processor) SetResourcePools(pools map[string]int, classes map[string]ResourceClass) error {
+	for typ, c := range classes {
+		capacity, ok := pools[c.Pool]
+		if !ok {
+			return fmt.Errorf("тип %q ссылается на неизвестный пул %q", typ, c.Pool)
+		}
+		if c.Weight > capacity {
+			return fmt.Errorf("вес типа %q (%d) превышает ёмкость пула %q (%d)", typ, c.Weight, c.Pool, capacity)
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	next := make(map[string]*resourcePool, len(pools))
+	for name, capacity := range pools {
+		rp, ok := p.resourcePools[name]
+		if !ok {
+			rp = &resourcePool{}
+		}
+		rp.capacity = capacity
+		next[name] = rp
+	}
+	p.resourcePools = next
+	p.resourceClasses = classes
+	p.dispatchLocked()
+	return nil
+}
+
+// resourceFor возвращает пул и вес задачи типа typ; nil, если тип не привязан к пулу; вызывается под mu
+func (p *Processor) resourceFor(typ string) (*resourcePool, int) {
+	c, ok := p.resourceClasses[typ]
+	if !ok {
+		return nil, 0
+	}
+	return p.resourcePools[c.Pool], c.Weight
+}
+
+// resourceStatsLocked собирает статистику пулов ресурсов; вызывается под mu
+func (p *Processor) resourceStatsLocked() []ResourcePoolStats {
+	if len(p.resourcePools) == 0 {
+		return nil
+	}
+	queued := make(map[string]int)
+	for _, q := range p.queue {
+		if c, ok := p.resourceClasses[q.task.Type]; ok {
+			queued[c.Pool]++
+		}
+	}
+	stats := make([]ResourcePoolStats, 0, len(p.resourcePools))
+	for name, rp := range p.resourcePools {
+		stats = append(stats, ResourcePoolStats{
+			Name: name, Capacity: rp.capacity, Used: rp.used, Running: rp.running, Queued: queued[name],
+		})
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Name < stats[j].Name })
+	return stats
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"workmateTestProject/internal/model"
+)
+
+// TestResourcePools_Weights проверяет, что тяжёлые задачи занимают несколько единиц своего пула
+// и не мешают задачам другого пула.
+func TestResourcePools_Weights(t *testing.T) {
+	t.Parallel()
+	release := make(chan struct{})
+	defer close(release)
+	p, _ := newTestProcessor(t, 10, func(ctx context.Context) (string, error) {
+		<-release
+		return "ok", nil
+	})
+
+	if err := p.SetResourcePools(map[string]int{"reports": 4}, map[string]ResourceClass{"report": {Pool: "missing", Weight: 1}}); err == nil {
+		t.Errorf("ожидалась ошибка для класса с неизвестным пулом")
+	}
+	err := p.SetResourcePools(
+		map[string]int{"reports": 4, "notify": 2},
+		map[string]ResourceClass{"report": {Pool: "reports", Weight: 3}, "email": {Pool: "notify", Weight: 1}},
+	)
+	if err != nil {
+		t.Fatalf("SetResourcePools: %v", err)
+	}
+
+	// Две тяжёлые задачи не помещаются в пул ёмкостью 4, а уведомления идут своим пулом
+	for _, typ := range []string{"report", "report", "email", "email", "email"} {
+		if err := p.StartProcessing(&model.Task{ID: uuid.New(), Type: typ}); err != nil {
+			t.Fatalf("StartProcessing(%s): %v", typ, err)
+		}
+	}
+	st := p.Stats()
+	want := []ResourcePoolStats{
+		{Name: "notify", Capacity: 2, Used: 2, Running: 2, Queued: 1},
+		{Name: "reports", Capacity: 4, Used: 3, Running: 1, Queued: 1},
+	}
+	if len(st.Pools) != len(want) {
+		t.Fatalf("ожидалось %d пула, получили %+v", len(want), st.Pools)
+	}
+	for i := range want {
+		if st.Pools[i] != want[i] {
+			t.Errorf("пул %s: ожидалось %+v, получили %+v", want[i].Name, want[i], st.Pools[i])
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/google/uuid"
+	"workmateTestProject/internal/clock"
+	"workmateTestProject/internal/model"
+)
+
+// ErrNotEditable возвращается Update, если обработка задачи уже начата или завершена
+var ErrNotEditable = errors.New("задача уже выполняется или завершена")
+
+// scheduledTask - задача, ожидающая наступления RunAt
+type scheduledTask struct {
+	q     *queuedTask
+	timer clock.Timer
+}
+
+// deferred сообщает, нужно ли отложить запуск задачи до RunAt по часам обработчика
+func (p *Processor) deferred(task *model.Task) bool {
+	return task.RunAt != nil && task.RunAt.After(p.clock.Now())
+}
+
+// scheduleLocked откладывает задачу до RunAt; по наступлению срока она встаёт в очередь
+// без повторной проверки лимитов очереди, так как уже была принята; вызывается под mu
+func (p *Processor) scheduleLocked(q *queuedTask) {
+	st := &scheduledTask{q: q}
+	st.timer = p.clock.AfterFunc(q.task.RunAt.Sub(p.clock.Now()), func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		// Задачу могли отменить, изменить или снять при остановке сервиса
+		if p.scheduled[q.task.ID] != st || p.draining {
+			return
+		}
+		delete(p.scheduled, q.task.ID)
+		p.enqueueLocked(q)
+		p.RecordEvent(q.task, model.Event{Type: model.EventQueued})
+		p.dispatchLocked()
+	})
+	p.scheduled[q.task.ID] = st
+}
+
+// unscheduleLocked снимает отложенную задачу; вызывается под mu
+func (p *Processor) unscheduleLocked(id uuid.UUID) (*scheduledTask, bool) {
+	st, ok := p.scheduled[id]
+	if ok {
+		st.timer.Stop()
+		delete(p.scheduled, id)
+	}
+	return st, ok
+}
+
+// Update применяет JSON Merge Patch к задаче, обработка которой ещё не начата,
+// и записывает изменение в историю от имени actor. Патч должен быть заранее проверен
+// на копии задачи. Ожидающая задача занимает в очереди место по новому приоритету,
+// а при изменении RunAt переносится между очередью и отложенными задачами.
+// Задача, которая после изменения встаёт в очередь с другим приоритетом или переходит в неё
+// из отложенных, проходит те же проверки лимитов, что и в StartProcessing; при отказе
+// (ErrQueueFull, ErrTypeQueueFull, ErrOverloaded) задача не меняется.
+func (p *Processor) Update(task *model.Task, actor string, patch json.RawMessage) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.active[task.ID]; ok || !task.Status.Editable() {
+		return ErrNotEditable
+	}
+
+	var q *queuedTask
+	wasScheduled := false
+	if st, ok := p.unscheduleLocked(task.ID); ok {
+		q, wasScheduled = st.q, true
+	} else {
+		for i := range p.queue {
+			if p.queue[i].task.ID == task.ID {
+				q = p.removeLocked(i)
+				break
+			}
+		}
+	}
+
+	if q != nil {
+		next := task.Clone()
+		_ = next.ApplyMergePatch(patch)
+		if !p.deferred(next) && (wasScheduled || next.Priority != task.Priority) {
+			if err := p.admitLocked(next, q.ts, p.tenantLimits(task.Tenant)); err != nil {
+				// Возвращаем задачу на прежнее место
+				if wasScheduled {
+					p.scheduleLocked(q)
+				} else {
+					p.enqueueLocked(q)
+				}
+				return err
+			}
+		}
+	}
+
+	p.RecordEvent(task, model.Event{Type: model.EventUpdated, Actor: actor, Patch: patch})
+	// Задача не в очереди (например, загружена без постановки в очередь) - меняются только параметры
+	if q == nil {
+		return nil
+	}
+	switch {
+	case p.deferred(task):
+		p.scheduleLocked(q)
+		if !wasScheduled {
+			p.RecordEvent(task, model.Event{Type: model.EventScheduled})
+		}
+	default:
+		p.enqueueLocked(q)
+		if wasScheduled {
+			p.RecordEvent(task, model.Event{Type: model.EventQueued})
+		}
+		p.dispatchLocked()
+	}
+	return nil
+}
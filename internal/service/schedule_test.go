@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"workmateTestProject/internal/model"
+)
+
+// TestSchedule_UpdateAndCancel проверяет откладывание задачи до RunAt, её перенос в очередь
+// изменением run_at, запуск по наступлении срока, запрет изменения завершённой задачи
+// и отмену отложенной задачи.
+func TestSchedule_UpdateAndCancel(t *testing.T) {
+	t.Parallel()
+	p, clk := newTestProcessor(t, 10, func(ctx context.Context) (string, error) { return "ok", nil })
+
+	later := clk.Now().Add(time.Hour)
+	task := &model.Task{ID: uuid.New(), Status: model.StatusPending, RunAt: &later}
+	if err := p.StartProcessing(task); err != nil {
+		t.Fatalf("StartProcessing: %v", err)
+	}
+	if task.Status != model.StatusScheduled || !p.Active(task.ID) || p.Stats().Scheduled != 1 {
+		t.Fatalf("задача должна быть отложена, статус %s, очередь %+v", task.Status, p.Stats())
+	}
+
+	if err := p.Update(task, "alice", []byte(`{"run_at":null,"priority":5}`)); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	waitDone(t, p, task)
+	if task.Status != model.StatusCompleted || task.Priority != 5 || task.RunAt != nil {
+		t.Errorf("изменения не применены: %+v", task)
+	}
+	if err := p.Update(task, "alice", []byte(`{"priority":1}`)); err != ErrNotEditable {
+		t.Errorf("для завершённой задачи ожидалась ErrNotEditable, получили %v", err)
+	}
+	var types []string
+	for _, e := range p.TaskHistory(task.ID) {
+		types = append(types, string(e.Type))
+	}
+	if got := strings.Join(types, " "); got != "scheduled updated queued started completed" {
+		t.Errorf("неверная история: %s", got)
+	}
+
+	// Отложенная задача встаёт в очередь, когда часы доходят до RunAt
+	due := &model.Task{ID: uuid.New(), Status: model.StatusPending, RunAt: &later}
+	p.StartProcessing(due)
+	clk.Advance(time.Hour - time.Second)
+	if due.Status != model.StatusScheduled {
+		t.Fatalf("задача запущена раньше срока, статус %s", due.Status)
+	}
+	clk.Advance(time.Second)
+	waitDone(t, p, due)
+	if due.Status != model.StatusCompleted || !due.StartedAt.Equal(later) {
+		t.Errorf("задача должна выполниться в срок, статус %s, начата %v", due.Status, due.StartedAt)
+	}
+
+	evenLater := later.Add(time.Hour)
+	other := &model.Task{ID: uuid.New(), Status: model.StatusPending, RunAt: &evenLater}
+	p.StartProcessing(other)
+	if err := p.Cancel(context.Background(), other.ID, "bob"); err != nil {
+		t.Fatalf("Cancel: %v", err)
+	}
+	if other.Status != model.StatusCanceled || p.Active(other.ID) || p.Stats().Scheduled != 0 || clk.Timers() != 0 {
+		t.Errorf("отложенная задача должна быть отменена и снята, статус %s", other.Status)
+	}
+}
+
+// TestStartProcessing_Timeout проверяет завершение задачи со статусом Failed по истечении Timeout
+func TestStartProcessing_Timeout(t *testing.T) {
+	t.Parallel()
+	p, clk := newTestProcessor(t, 1, func(ctx context.Context) (string, error) {
+		<-ctx.Done()
+		return "", ctx.Err()
+	})
+
+	task := &model.Task{ID: uuid.New(), Status: model.StatusPending, Timeout: model.Duration(time.Minute)}
+	p.StartProcessing(task)
+	clk.Advance(time.Minute - time.Second)
+	if !p.Active(task.ID) {
+		t.Fatalf("задача завершилась до истечения Timeout, статус %s", task.Status)
+	}
+	clk.Advance(time.Second)
+	waitDone(t, p, task)
+	if task.Status != model.StatusFailed || !strings.Contains(task.Error, "превышено время обработки") {
+		t.Errorf("ожидалась ошибка превышения времени, получили %q", task.Error)
+	}
+}
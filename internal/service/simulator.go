@@ -0,0 +1,307 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"workmateTestProject/internal/model"
+)
+
+// Распределения длительности симулируемой работы
+const (
+	DistFixed     = "fixed"
+	DistUniform   = "uniform"
+	DistNormal    = "normal"
+	DistLognormal = "lognormal"
+)
+
+// maxProgressSteps - наибольшее число сообщений о ходе обработки в профиле симулятора
+const maxProgressSteps = 1000
+
+// maxSigma - наибольшее стандартное отклонение логарифма для lognormal
+const maxSigma = 10
+
+// DurationDist - распределение длительности симулируемой работы:
+// fixed - Value; uniform - от Min до Max; normal - Mean и Stddev;
+// lognormal - Median и Sigma (стандартное отклонение логарифма).
+// Для normal и lognormal Min и Max ограничивают выпавшее значение.
+type DurationDist struct {
+	Dist   string         `json:"dist"`
+	Value  model.Duration `json:"value,omitempty"`
+	Min    model.Duration `json:"min,omitempty"`
+	Max    model.Duration `json:"max,omitempty"`
+	Mean   model.Duration `json:"mean,omitempty"`
+	Stddev model.Duration `json:"stddev,omitempty"`
+	Median model.Duration `json:"median,omitempty"`
+	Sigma  float64        `json:"sigma,omitempty"`
+}
+
+// SimulationProfile описывает поведение симулятора для нагрузочных испытаний
+type SimulationProfile struct {
+	Duration DurationDist `json:"duration"`
+	// FailureRate - доля задач, завершающихся ошибкой по истечении длительности
+	FailureRate float64 `json:"failure_rate,omitempty"`
+	// Errors - сообщения об ошибках, из которых выбирается случайное
+	Errors []string `json:"errors,omitempty"`
+	// HangRate - доля задач, которые зависают до отмены или истечения Timeout
+	HangRate float64 `json:"hang_rate,omitempty"`
+	// ProgressSteps - сколько раз через равные промежутки сообщать о ходе обработки
+	ProgressSteps int `json:"progress_steps,omitempty"`
+	// Seed - начальное значение генератора случайных чисел; nil - случайное.
+	// С заданным Seed задачи одного профиля получают одинаковую последовательность
+	// исходов при одинаковом порядке запуска.
+	Seed *int64 `json:"seed,omitempty"`
+}
+
+// DefaultSimulationProfile - поведение симулятора без настроек: от 1 до 5 минут без сбоев
+var DefaultSimulationProfile = SimulationProfile{
+	Duration: DurationDist{Dist: DistUniform, Min: model.Duration(time.Minute), Max: model.Duration(5 * time.Minute)},
+}
+
+// simulationPlan - исход симуляции одной задачи
+type simulationPlan struct {
+	duration time.Duration
+	fail     string
+	hang     bool
+}
+
+// simulator - профиль и генератор случайных чисел, общий для задач профиля
+type simulator struct {
+	profile SimulationProfile
+	rng     *lockedRand
+}
+
+// lockedRand - генератор случайных чисел, безопасный для параллельного использования
+type lockedRand struct {
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+func newSimulator(p SimulationProfile) *simulator {
+	seed := time.Now().UnixNano()
+	if p.Seed != nil {
+		seed = *p.Seed
+	}
+	return &simulator{profile: p, rng: &lockedRand{rng: rand.New(rand.NewSource(seed))}}
+}
+
+// Validate проверяет профиль
+func (p *SimulationProfile) Validate() error {
+	d := p.Duration
+	if d.Value < 0 || d.Min < 0 || d.Max < 0 || d.Mean < 0 || d.Stddev < 0 || d.Median < 0 || d.Sigma < 0 {
+		return errors.New("duration: длительности и sigma не могут быть отрицательными")
+	}
+	if d.Max > 0 && d.Min > d.Max {
+		return errors.New("duration: min больше max")
+	}
+	if math.IsNaN(d.Sigma) || d.Sigma > maxSigma {
+		return fmt.Errorf("duration: sigma должна быть от 0 до %d", maxSigma)
+	}
+	switch d.Dist {
+	case DistFixed:
+	case DistUniform:
+		if d.Max == 0 {
+			return errors.New("duration: для uniform нужен max")
+		}
+	case DistNormal:
+		if d.Mean == 0 {
+			return errors.New("duration: для normal нужен mean")
+		}
+	case DistLognormal:
+		if d.Median == 0 {
+			return errors.New("duration: для lognormal нужна median")
+		}
+	default:
+		return fmt.Errorf("duration.dist: ожидалось fixed, uniform, normal или lognormal, получили %q", d.Dist)
+	}
+	if p.FailureRate < 0 || p.HangRate < 0 || p.FailureRate+p.HangRate > 1 {
+		return errors.New("failure_rate и hang_rate должны быть от 0 до 1 и в сумме не больше 1")
+	}
+	if p.ProgressSteps < 0 || p.ProgressSteps > maxProgressSteps {
+		return fmt.Errorf("progress_steps должно быть от 0 до %d", maxProgressSteps)
+	}
+	return nil
+}
+
+// LoadSimulationProfiles загружает профили симулятора из JSON-файла вида
+// {"*": {...}, "report": {...}}: профиль по типу задачи, "*" - для остальных типов
+func (p *Processor) LoadSimulationProfiles(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var profiles map[string]SimulationProfile
+	if err := json.Unmarshal(data, &profiles); err != nil {
+		return fmt.Errorf("разбор %s: %w", path, err)
+	}
+	return p.SetSimulationProfiles(profiles)
+}
+
+// SetSimulationProfiles заменяет профили симулятора; без профиля "*" остальные типы
+// симулируются DefaultSimulationProfile
+func (p *Processor) SetSimulationProfiles(profiles map[string]SimulationProfile) error {
+	sims := make(map[string]*simulator, len(profiles)+1)
+	for typ, profile := range profiles {
+		if err := profile.Validate(); err != nil {
+			return fmt.Errorf("профиль %q: %w", typ, err)
+		}
+		sims[typ] = newSimulator(profile)
+	}
+	if _, ok := sims["*"]; !ok {
+		sims["*"] = newSimulator(DefaultSimulationProfile)
+	}
+	p.simulatorsMu.Lock()
+	p.simulators = sims
+	p.simulatorsMu.Unlock()
+	return nil
+}
+
+// payloadProfile возвращает профиль из поля simulation payload задачи, если оно есть
+func payloadProfile(task *model.Task) (*SimulationProfile, error) {
+	if len(task.Payload) == 0 || task.Payload[0] != '{' {
+		return nil, nil
+	}
+	var payload struct {
+		Simulation json.RawMessage `json:"simulation"`
+	}
+	if err := json.Unmarshal(task.Payload, &payload); err != nil || len(payload.Simulation) == 0 || string(payload.Simulation) == "null" {
+		return nil, nil
+	}
+	var p SimulationProfile
+	if err := json.Unmarshal(payload.Simulation, &p); err != nil {
+		return nil, fmt.Errorf("payload.simulation: %v", err)
+	}
+	if err := p.Validate(); err != nil {
+		return nil, fmt.Errorf("payload.simulation: %w", err)
+	}
+	return &p, nil
+}
+
+// simulatorFor выбирает профиль задачи: из payload, по типу задачи или общий
+func (p *Processor) simulatorFor(task *model.Task) (*simulator, error) {
+	profile, err := payloadProfile(task)
+	if err != nil {
+		return nil, err
+	}
+	// Профиль из payload без seed использует генератор профиля по типу,
+	// чтобы исходы таких задач тоже воспроизводились с seed из настроек
+	p.simulatorsMu.RLock()
+	s, ok := p.simulators[task.Type]
+	if !ok {
+		s = p.simulators["*"]
+	}
+	p.simulatorsMu.RUnlock()
+	if profile == nil {
+		return s, nil
+	}
+	if profile.Seed != nil {
+		return newSimulator(*profile), nil
+	}
+	return &simulator{profile: *profile, rng: s.rng}, nil
+}
+
+// plan разыгрывает исход задачи; все случайные величины задачи выбираются за один захват
+// генератора, чтобы исходы зависели только от порядка запуска задач
+func (s *simulator) plan() simulationPlan {
+	s.rng.mu.Lock()
+	defer s.rng.mu.Unlock()
+	rng := s.rng.rng
+	p := s.profile
+	var plan simulationPlan
+	plan.duration = p.Duration.sample(rng)
+	switch r := rng.Float64(); {
+	case r < p.HangRate:
+		plan.hang = true
+	case r < p.HangRate+p.FailureRate:
+		plan.fail = "симулированный сбой"
+		if len(p.Errors) > 0 {
+			plan.fail = p.Errors[rng.Intn(len(p.Errors))]
+		}
+	}
+	return plan
+}
+
+// sample выбирает длительность из распределения
+func (d DurationDist) sample(rng *rand.Rand) time.Duration {
+	var v float64
+	switch d.Dist {
+	case DistFixed:
+		return time.Duration(d.Value)
+	case DistUniform:
+		v = float64(d.Min) + rng.Float64()*float64(d.Max-d.Min)
+	case DistNormal:
+		v = float64(d.Mean) + rng.NormFloat64()*float64(d.Stddev)
+	case DistLognormal:
+		v = float64(d.Median) * math.Exp(d.Sigma*rng.NormFloat64())
+	}
+	v = math.Max(v, float64(d.Min))
+	if d.Max > 0 {
+		v = math.Min(v, float64(d.Max))
+	}
+	// Без max большое stddev или sigma может дать значение, не помещающееся в time.Duration
+	if v >= math.MaxInt64 {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(v).Round(time.Millisecond)
+}
+
+// validateSimulation проверяет профиль в payload задачи, которую выполняет симулятор
+func validateSimulation(task *model.Task) error {
+	_, err := payloadProfile(task)
+	return err
+}
+
+// simulateWork симулирует I/O-bound работу по профилю задачи, возвращая результат или ошибку
+func (p *Processor) simulateWork(ctx context.Context) (string, error) {
+	task, _ := TaskFromContext(ctx)
+	if task == nil {
+		task = &model.Task{}
+	}
+	s, err := p.simulatorFor(task)
+	if err != nil {
+		return "", err
+	}
+	plan := s.plan()
+	if plan.hang {
+		Progress(ctx, "Ожидание без ограничения времени")
+		<-ctx.Done()
+		return "", ctx.Err()
+	}
+
+	Progress(ctx, "Ожидание %s", plan.duration)
+	steps := s.profile.ProgressSteps
+	if steps == 0 {
+		steps = 1
+	}
+	for i := 1; i <= steps; i++ {
+		select {
+		case <-p.clock.After(plan.duration / time.Duration(steps)):
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+		if s.profile.ProgressSteps > 0 {
+			Progress(ctx, "Шаг %d из %d", i, steps)
+		}
+	}
+	if plan.fail != "" {
+		return "", errors.New(plan.fail)
+	}
+
+	result := fmt.Sprintf("Обработано за %s", plan.duration)
+	// Дублируем результат в артефакт, если настроено хранилище
+	if p.artifacts != nil {
+		if err := PutArtifact(ctx, "result.txt", "text/plain; charset=utf-8", strings.NewReader(result)); err != nil {
+			return "", err
+		}
+	}
+	// Возвращаем результат
+	return result, nil
+}
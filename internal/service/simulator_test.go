@@ -0,0 +1,137 @@
+package service
+
+import (
+	"encoding/json"
+	"math"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"workmateTestProject/internal/model"
+)
+
+// TestDurationDist_Sample проверяет распределения длительности и ограничение min/max
+func TestDurationDist_Sample(t *testing.T) {
+	s := newSimulator(SimulationProfile{Seed: new(int64)})
+	rng := s.rng.rng
+	ms := func(n int) model.Duration { return model.Duration(time.Duration(n) * time.Millisecond) }
+	median := func(d DurationDist) time.Duration {
+		samples := make([]time.Duration, 2001)
+		for i := range samples {
+			samples[i] = d.sample(rng)
+			if d.Min > 0 && samples[i] < time.Duration(d.Min) || d.Max > 0 && samples[i] > time.Duration(d.Max) {
+				t.Fatalf("%s: значение %s вне [%s, %s]", d.Dist, samples[i], time.Duration(d.Min), time.Duration(d.Max))
+			}
+		}
+		sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+		return samples[len(samples)/2]
+	}
+	near := func(name string, got time.Duration, want time.Duration) {
+		if math.Abs(float64(got-want)) > 0.1*float64(want) {
+			t.Errorf("%s: медиана %s, ожидалось около %s", name, got, want)
+		}
+	}
+
+	if got := (DurationDist{Dist: DistFixed, Value: ms(1500)}).sample(rng); got != 1500*time.Millisecond {
+		t.Errorf("fixed: получили %s", got)
+	}
+	near("uniform", median(DurationDist{Dist: DistUniform, Min: ms(1000), Max: ms(3000)}), 2*time.Second)
+	near("normal", median(DurationDist{Dist: DistNormal, Mean: ms(1000), Stddev: ms(200)}), time.Second)
+	near("lognormal", median(DurationDist{Dist: DistLognormal, Median: ms(1000), Sigma: 1}), time.Second)
+	median(DurationDist{Dist: DistNormal, Mean: ms(100), Stddev: ms(1000), Min: ms(50), Max: ms(150)})
+
+	// Без max огромные значения ограничиваются наибольшей длительностью, а не переполняются
+	huge := DurationDist{Dist: DistNormal, Mean: model.Duration(math.MaxInt64 / 2), Stddev: model.Duration(math.MaxInt64)}
+	for i := 0; i < 100; i++ {
+		if v := huge.sample(rng); v < 0 {
+			t.Fatalf("normal: переполнение, получили %s", v)
+		}
+	}
+	wide := DurationDist{Dist: DistLognormal, Median: model.Duration(time.Hour), Sigma: maxSigma}
+	for i := 0; i < 100; i++ {
+		if v := wide.sample(rng); v < 0 {
+			t.Fatalf("lognormal: переполнение, получили %s", v)
+		}
+	}
+}
+
+// TestSimulator_Plan проверяет воспроизводимость исходов с одинаковым seed и долю сбоев и зависаний
+func TestSimulator_Plan(t *testing.T) {
+	seed := int64(42)
+	p := SimulationProfile{
+		Duration:    DurationDist{Dist: DistLognormal, Median: model.Duration(time.Second), Sigma: 0.5},
+		FailureRate: 0.2,
+		HangRate:    0.1,
+		Errors:      []string{"нет связи с БД", "диск заполнен"},
+		Seed:        &seed,
+	}
+	a, b := newSimulator(p), newSimulator(p)
+	failed, hung := 0, 0
+	const n = 5000
+	for i := 0; i < n; i++ {
+		pa, pb := a.plan(), b.plan()
+		if pa != pb {
+			t.Fatalf("исходы %d-й задачи различаются: %+v и %+v", i, pa, pb)
+		}
+		switch {
+		case pa.hang:
+			hung++
+		case pa.fail != "":
+			failed++
+			if pa.fail != p.Errors[0] && pa.fail != p.Errors[1] {
+				t.Errorf("сообщение не из списка: %q", pa.fail)
+			}
+		}
+	}
+	if r := float64(failed) / n; math.Abs(r-0.2) > 0.03 {
+		t.Errorf("доля сбоев %.3f, ожидалось около 0.2", r)
+	}
+	if r := float64(hung) / n; math.Abs(r-0.1) > 0.03 {
+		t.Errorf("доля зависаний %.3f, ожидалось около 0.1", r)
+	}
+}
+
+// TestSimulateWork_PayloadProfile проверяет профиль из payload: проверку при создании,
+// сообщения о ходе обработки и сбой с сообщением из списка
+func TestSimulateWork_PayloadProfile(t *testing.T) {
+	t.Parallel()
+	p, clk := newTestProcessor(t, 1, nil)
+	for _, bad := range []string{
+		`{"simulation":{"duration":{"dist":"poisson"}}}`,
+		`{"simulation":{"duration":{"dist":"uniform","min":"2s","max":"1s"}}}`,
+		`{"simulation":{"duration":{"dist":"fixed"},"failure_rate":0.7,"hang_rate":0.5}}`,
+		`{"simulation":{"duration":{"dist":"fixed","value":5}}}`,
+		`{"simulation":{"duration":{"dist":"lognormal","median":"1s","sigma":1000}}}`,
+	} {
+		if err := p.ValidateTask(&model.Task{Payload: json.RawMessage(bad)}); err == nil {
+			t.Errorf("профиль %s должен быть отклонён", bad)
+		}
+	}
+
+	payload := `{"simulation":{"duration":{"dist":"fixed","value":"30ms"},"failure_rate":1,"errors":["нет связи с БД"],"progress_steps":3,"seed":1}}`
+	task := &model.Task{ID: uuid.New(), Status: model.StatusPending, Payload: json.RawMessage(payload)}
+	if err := p.ValidateTask(task); err != nil {
+		t.Fatalf("ValidateTask: %v", err)
+	}
+	p.StartProcessing(task)
+	// Каждый шаг симулятора ждёт 10ms по часам обработчика
+	for i := 0; i < 3; i++ {
+		clk.BlockUntil(1)
+		clk.Advance(10 * time.Millisecond)
+	}
+	waitDone(t, p, task)
+	if task.Status != model.StatusFailed || task.Error != "нет связи с БД" {
+		t.Errorf("ожидалась ошибка из профиля, получили %q", task.Error)
+	}
+	var progress []string
+	for _, e := range p.TaskHistory(task.ID) {
+		if e.Type == model.EventProgress {
+			progress = append(progress, e.Message)
+		}
+	}
+	if got := strings.Join(progress, "; "); got != "Ожидание 30ms; Шаг 1 из 3; Шаг 2 из 3; Шаг 3 из 3" {
+		t.Errorf("неверные сообщения о ходе обработки: %s", got)
+	}
+}
@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"github.com/google/uuid"
+	"workmateTestProject/internal/model"
+)
+
+// liveStore скрывает мягко удалённые задачи
+type liveStore struct {
+	store   TaskStore
+	deleted func(task *model.Task) bool
+}
+
+// WithoutDeleted возвращает представление store, в котором не видны мягко удалённые задачи.
+// deleted сообщает, удалена ли задача; его задают, когда признак удаления меняется
+// под блокировкой обработчика задач. nil - task.Deleted().
+func WithoutDeleted(store TaskStore, deleted func(task *model.Task) bool) TaskStore {
+	if deleted == nil {
+		deleted = (*model.Task).Deleted
+	}
+	return &liveStore{store: store, deleted: deleted}
+}
+
+// Create добавляет задачу в хранилище
+func (s *liveStore) Create(task *model.Task) {
+	s.store.Create(task)
+}
+
+// Get возвращает задачу, если она не удалена
+func (s *liveStore) Get(id uuid.UUID) (*model.Task, bool) {
+	task, ok := s.store.Get(id)
+	if !ok || s.deleted(task) {
+		return nil, false
+	}
+	return task, true
+}
+
+// Delete удаляет задачу, если она не удалена мягко
+func (s *liveStore) Delete(id uuid.UUID) {
+	if _, ok := s.Get(id); ok {
+		s.store.Delete(id)
+	}
+}
+
+// List возвращает неудалённые задачи
+func (s *liveStore) List() []*model.Task {
+	all := s.store.List()
+	list := make([]*model.Task, 0, len(all))
+	for _, task := range all {
+		if !s.deleted(task) {
+			list = append(list, task)
+		}
+	}
+	return list
+}
+
+// Cancel отменяет задачу, если она не удалена
+func (s *liveStore) Cancel(id uuid.UUID) bool {
+	if _, ok := s.Get(id); !ok {
+		return false
+	}
+	return s.store.Cancel(id)
+}
+
+// Range обходит неудалённые задачи
+func (s *liveStore) Range(fn func(task *model.Task) bool) {
+	s.store.Range(func(task *model.Task) bool {
+		if s.deleted(task) {
+			return true
+		}
+		return fn(task)
+	})
+}
@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/google/uuid"
+	"workmateTestProject/internal/model"
+)
+
+// History хранит историю событий задач в памяти
+type History struct {
+	mu     sync.RWMutex
+	events map[uuid.UUID][]model.Event
+}
+
+// NewHistory создаёт пустую историю
+func NewHistory() *History {
+	return &History{events: make(map[uuid.UUID][]model.Event)}
+}
+
+// Append дописывает событие в историю задачи, присваивая ему очередной номер, и возвращает его
+func (h *History) Append(id uuid.UUID, e model.Event) model.Event {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	events := h.events[id]
+	e.Seq = int64(len(events)) + 1
+	if e.Task != nil {
+		e.Task = e.Task.Clone()
+	}
+	h.events[id] = append(events, e)
+	return e
+}
+
+// Events возвращает копию истории задачи в порядке добавления
+func (h *History) Events(id uuid.UUID) []model.Event {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return append([]model.Event(nil), h.events[id]...)
+}
+
+// Delete удаляет историю задачи
+func (h *History) Delete(id uuid.UUID) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.events, id)
+}
+
+// Save сохраняет историю всех задач в JSON-файл; запись атомарна, как и у SaveSnapshot
+func (h *History) Save(path string) error {
+	h.mu.RLock()
+	data, err := json.Marshal(h.events)
+	h.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Load загружает историю из JSON-файла, заменяя истории задач с теми же ID.
+// Отсутствие файла не считается ошибкой.
+func (h *History) Load(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var events map[uuid.UUID][]model.Event
+	if err := json.Unmarshal(data, &events); err != nil {
+		return err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for id, list := range events {
+		h.events[id] = list
+	}
+	return nil
+}
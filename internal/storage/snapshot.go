@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+
+	"workmateTestProject/internal/model"
+)
+
+// SaveSnapshot сохраняет все задачи хранилища в JSON-файл.
+// Запись атомарна: данные пишутся во временный файл, который затем переименовывается.
+func SaveSnapshot(store TaskStore, path string) error {
+	data, err := json.Marshal(store.List())
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// LoadSnapshot загружает задачи из JSON-файла в хранилище и возвращает их.
+// Отсутствие файла не считается ошибкой.
+func LoadSnapshot(store TaskStore, path string) ([]*model.Task, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var tasks []*model.Task
+	if err := json.Unmarshal(data, &tasks); err != nil {
+		return nil, err
+	}
+	for _, task := range tasks {
+		store.Create(task)
+	}
+	return tasks, nil
+}
@@ -19,6 +19,9 @@ type TaskStore interface {
 	List() []*model.Task
 	// Cancel устанавливает статус задачи Canceled, возвращает true если задача найдена
 	Cancel(id uuid.UUID) bool
+	// Range вызывает fn для каждой задачи, пока fn возвращает true.
+	// fn вызывается без блокировки хранилища и может выполнять долгие операции (например, запись в сеть).
+	Range(fn func(task *model.Task) bool)
 }
 
 // InMemoryTaskStore - реализация TaskStore в памяти
@@ -78,3 +81,19 @@ func (s *InMemoryTaskStore) Cancel(id uuid.UUID) bool {
 	task.Status = model.StatusCanceled
 	return true
 }
+
+// Range обходит задачи. Под блокировкой копируются только указатели,
+// поэтому медленный потребитель не задерживает запись в хранилище.
+func (s *InMemoryTaskStore) Range(fn func(task *model.Task) bool) {
+	s.mu.RLock()
+	ptrs := make([]*model.Task, 0, len(s.tasks))
+	for _, task := range s.tasks {
+		ptrs = append(ptrs, task)
+	}
+	s.mu.RUnlock()
+	for _, task := range ptrs {
+		if !fn(task) {
+			return
+		}
+	}
+}
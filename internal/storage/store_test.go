@@ -1,6 +1,9 @@
 package storage
 
 import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -82,3 +85,106 @@ func TestInMemoryTaskStore_Cancel(t *testing.T) {
 		t.Errorf("Cancel вернул true для несуществующего ID")
 	}
 }
+
+// TestSnapshot_SaveLoad проверяет, что задачи, сохранённые в файл состояния, восстанавливаются в новом хранилище.
+func TestSnapshot_SaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	// Отсутствующий файл не является ошибкой
+	if tasks, err := LoadSnapshot(NewInMemoryTaskStore(), path); err != nil || len(tasks) != 0 {
+		t.Fatalf("ожидалась пустая загрузка без ошибки, получили %d задач, err=%v", len(tasks), err)
+	}
+
+	s := NewInMemoryTaskStore()
+	id := uuid.New()
+	s.Create(&model.Task{ID: id, Status: model.StatusInterrupted, CreatedAt: time.Now()})
+	if err := SaveSnapshot(s, path); err != nil {
+		t.Fatalf("SaveSnapshot вернул ошибку: %v", err)
+	}
+
+	restored := NewInMemoryTaskStore()
+	tasks, err := LoadSnapshot(restored, path)
+	if err != nil {
+		t.Fatalf("LoadSnapshot вернул ошибку: %v", err)
+	}
+	if len(tasks) != 1 {
+		t.Fatalf("ожидалась 1 задача, получили %d", len(tasks))
+	}
+	task, ok := restored.Get(id)
+	if !ok || task.Status != model.StatusInterrupted {
+		t.Errorf("задача не восстановлена или статус неверен: %+v", task)
+	}
+}
+
+// TestForTenant_Isolation проверяет, что представление арендатора не видит и не может удалить чужие задачи.
+func TestForTenant_Isolation(t *testing.T) {
+	s := NewInMemoryTaskStore()
+	a, b := ForTenant(s, "team-a"), ForTenant(s, "team-b")
+
+	task := &model.Task{ID: uuid.New(), Status: model.StatusPending, CreatedAt: time.Now()}
+	a.Create(task)
+	if task.Tenant != "team-a" {
+		t.Fatalf("ожидалась привязка к team-a, получили %q", task.Tenant)
+	}
+
+	if _, ok := b.Get(task.ID); ok {
+		t.Errorf("team-b не должен видеть задачу team-a")
+	}
+	if len(b.List()) != 0 || len(a.List()) != 1 {
+		t.Errorf("неверное разделение списков: a=%d, b=%d", len(a.List()), len(b.List()))
+	}
+	b.Delete(task.ID)
+	if b.Cancel(task.ID) {
+		t.Errorf("team-b не должен отменять задачу team-a")
+	}
+	if _, ok := a.Get(task.ID); !ok {
+		t.Errorf("задача team-a удалена из представления team-b")
+	}
+}
+
+// TestTransfer_RoundTrip проверяет, что выгрузка в NDJSON и CSV и обратная загрузка сохраняют поля задач.
+func TestTransfer_RoundTrip(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	parent := uuid.New()
+	orig := []*model.Task{
+		{ID: uuid.New(), Tenant: "a", Type: "report", Priority: 5, Status: model.StatusCompleted,
+			CreatedAt: now, StartedAt: &now, FinishedAt: &now, Result: "строка, с \"кавычками\"\nи переводом"},
+		{ID: uuid.New(), Tenant: "a", ParentID: &parent, Status: model.StatusPending, CreatedAt: now, RunAt: &now,
+			Labels: map[string]string{"env": "prod"}, Timeout: model.Duration(time.Minute), Payload: json.RawMessage(`{"n":1}`)},
+	}
+	for _, format := range []Format{FormatNDJSON, FormatCSV} {
+		var buf bytes.Buffer
+		tw := NewTaskWriter(&buf, format)
+		for _, task := range orig {
+			if err := tw.Write(task); err != nil {
+				t.Fatalf("%s: Write: %v", format, err)
+			}
+		}
+		if err := tw.Flush(); err != nil {
+			t.Fatalf("%s: Flush: %v", format, err)
+		}
+
+		var got []*model.Task
+		err := ReadTasks(&buf, format, func(line int, task *model.Task) error {
+			got = append(got, task)
+			return nil
+		})
+		if err != nil || len(got) != len(orig) {
+			t.Fatalf("%s: ожидалось %d задачи, получили %d (%v)", format, len(orig), len(got), err)
+		}
+		for i := range orig {
+			a, b := orig[i], got[i]
+			if a.ID != b.ID || a.Type != b.Type || a.Priority != b.Priority || a.Status != b.Status ||
+				!a.CreatedAt.Equal(b.CreatedAt) || a.Result != b.Result || (a.FinishedAt == nil) != (b.FinishedAt == nil) ||
+				(a.ParentID == nil) != (b.ParentID == nil) || (a.RunAt == nil) != (b.RunAt == nil) ||
+				a.Labels["env"] != b.Labels["env"] || a.Timeout != b.Timeout || string(a.Payload) != string(b.Payload) {
+				t.Errorf("%s: задача %d изменилась: %+v -> %+v", format, i, a, b)
+			}
+		}
+	}
+
+	err := ReadTasks(bytes.NewBufferString("id,status\nnot-a-uuid,Pending\n"), FormatCSV, func(int, *model.Task) error { return nil })
+	if err == nil {
+		t.Errorf("ожидалась ошибка для неверного id")
+	}
+}
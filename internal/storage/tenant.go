@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"github.com/google/uuid"
+	"workmateTestProject/internal/model"
+)
+
+// tenantStore ограничивает операции хранилища задачами одного арендатора
+type tenantStore struct {
+	store  TaskStore
+	tenant string
+}
+
+// ForTenant возвращает представление store, в котором видны только задачи арендатора tenant.
+// Создаваемые через него задачи автоматически привязываются к арендатору.
+func ForTenant(store TaskStore, tenant string) TaskStore {
+	return &tenantStore{store: store, tenant: tenant}
+}
+
+// Create привязывает задачу к арендатору и добавляет её в хранилище
+func (s *tenantStore) Create(task *model.Task) {
+	task.Tenant = s.tenant
+	s.store.Create(task)
+}
+
+// Get возвращает задачу, только если она принадлежит арендатору
+func (s *tenantStore) Get(id uuid.UUID) (*model.Task, bool) {
+	task, ok := s.store.Get(id)
+	if !ok || task.Tenant != s.tenant {
+		return nil, false
+	}
+	return task, true
+}
+
+// Delete удаляет задачу, только если она принадлежит арендатору
+func (s *tenantStore) Delete(id uuid.UUID) {
+	if _, ok := s.Get(id); ok {
+		s.store.Delete(id)
+	}
+}
+
+// List возвращает задачи арендатора
+func (s *tenantStore) List() []*model.Task {
+	all := s.store.List()
+	list := make([]*model.Task, 0, len(all))
+	for _, task := range all {
+		if task.Tenant == s.tenant {
+			list = append(list, task)
+		}
+	}
+	return list
+}
+
+// Cancel отменяет задачу, только если она принадлежит арендатору
+func (s *tenantStore) Cancel(id uuid.UUID) bool {
+	if _, ok := s.Get(id); !ok {
+		return false
+	}
+	return s.store.Cancel(id)
+}
+
+// Range обходит задачи арендатора
+func (s *tenantStore) Range(fn func(task *model.Task) bool) {
+	s.store.Range(func(task *model.Task) bool {
+		if task.Tenant != s.tenant {
+			return true
+		}
+		return fn(task)
+	})
+}
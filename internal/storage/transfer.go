@@ -0,0 +1,271 @@
+package storage
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"workmateTestProject/internal/model"
+)
+
+// Format - формат выгрузки и загрузки задач
+type Format string
+
+const (
+	// FormatNDJSON - по JSON-объекту задачи на строку; переносит все поля задачи
+	FormatNDJSON Format = "ndjson"
+	// FormatCSV - таблица с полями задачи, кроме артефактов; метки и payload записываются в JSON
+	FormatCSV Format = "csv"
+)
+
+// ParseFormat разбирает название формата; пустая строка означает NDJSON
+func ParseFormat(s string) (Format, error) {
+	switch Format(s) {
+	case "", FormatNDJSON:
+		return FormatNDJSON, nil
+	case FormatCSV:
+		return FormatCSV, nil
+	}
+	return "", fmt.Errorf("неизвестный формат %q: ожидался ndjson или csv", s)
+}
+
+// ContentType возвращает MIME-тип формата
+func (f Format) ContentType() string {
+	if f == FormatCSV {
+		return "text/csv; charset=utf-8"
+	}
+	return "application/x-ndjson"
+}
+
+// csvColumns - столбцы CSV в порядке выгрузки
+var csvColumns = []string{"id", "tenant", "created_by", "parent_id", "type", "priority", "labels", "run_at", "timeout",
+	"payload", "status", "version", "created_at", "started_at", "finished_at", "result", "error"}
+
+// TaskWriter построчно записывает задачи в выбранном формате
+type TaskWriter struct {
+	format Format
+	json   *json.Encoder
+	csv    *csv.Writer
+	header bool
+}
+
+// NewTaskWriter создаёт запись задач в w
+func NewTaskWriter(w io.Writer, f Format) *TaskWriter {
+	tw := &TaskWriter{format: f}
+	if f == FormatCSV {
+		tw.csv = csv.NewWriter(w)
+	} else {
+		tw.json = json.NewEncoder(w)
+	}
+	return tw
+}
+
+// Write записывает одну задачу
+func (tw *TaskWriter) Write(task *model.Task) error {
+	if tw.format != FormatCSV {
+		return tw.json.Encode(task)
+	}
+	if err := tw.writeHeader(); err != nil {
+		return err
+	}
+	var parentID, labels, timeout string
+	if task.ParentID != nil {
+		parentID = task.ParentID.String()
+	}
+	if len(task.Labels) > 0 {
+		b, err := json.Marshal(task.Labels)
+		if err != nil {
+			return err
+		}
+		labels = string(b)
+	}
+	if task.Timeout != 0 {
+		timeout = time.Duration(task.Timeout).String()
+	}
+	return tw.csv.Write([]string{
+		task.ID.String(), task.Tenant, task.CreatedBy, parentID, task.Type, strconv.Itoa(task.Priority), labels,
+		formatTime(task.RunAt), timeout, string(task.Payload), string(task.Status), strconv.FormatInt(task.Version, 10),
+		formatTime(&task.CreatedAt), formatTime(task.StartedAt), formatTime(task.FinishedAt), task.Result, task.Error,
+	})
+}
+
+// Flush дописывает буферизованные данные; пустая CSV-выгрузка содержит только заголовок
+func (tw *TaskWriter) Flush() error {
+	if tw.format != FormatCSV {
+		return nil
+	}
+	if err := tw.writeHeader(); err != nil {
+		return err
+	}
+	tw.csv.Flush()
+	return tw.csv.Error()
+}
+
+func (tw *TaskWriter) writeHeader() error {
+	if tw.header {
+		return nil
+	}
+	tw.header = true
+	return tw.csv.Write(csvColumns)
+}
+
+// ReadTasks читает задачи из r и вызывает fn для каждой; line - номер строки (для CSV - записи) во входных данных.
+// Ошибка разбора или ошибка fn прерывает чтение.
+func ReadTasks(r io.Reader, f Format, fn func(line int, task *model.Task) error) error {
+	if f == FormatCSV {
+		return readCSV(r, fn)
+	}
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 16<<20)
+	line := 0
+	for sc.Scan() {
+		line++
+		if strings.TrimSpace(sc.Text()) == "" {
+			continue
+		}
+		var task model.Task
+		if err := json.Unmarshal(sc.Bytes(), &task); err != nil {
+			return fmt.Errorf("строка %d: %w", line, err)
+		}
+		if err := fn(line, &task); err != nil {
+			return err
+		}
+	}
+	return sc.Err()
+}
+
+func readCSV(r io.Reader, fn func(line int, task *model.Task) error) error {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("заголовок: %w", err)
+	}
+	cols := make(map[string]int, len(header))
+	for i, name := range header {
+		cols[strings.TrimSpace(name)] = i
+	}
+	if _, ok := cols["id"]; !ok {
+		return errors.New("заголовок: нет столбца id")
+	}
+
+	for line := 2; ; line++ {
+		rec, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		get := func(name string) string {
+			if i, ok := cols[name]; ok && i < len(rec) {
+				return rec[i]
+			}
+			return ""
+		}
+		task, err := taskFromCSV(get)
+		if err != nil {
+			return fmt.Errorf("строка %d: %w", line, err)
+		}
+		if err := fn(line, task); err != nil {
+			return err
+		}
+	}
+}
+
+// taskFromCSV собирает задачу из значений столбцов
+func taskFromCSV(get func(name string) string) (*model.Task, error) {
+	id, err := uuid.Parse(get("id"))
+	if err != nil {
+		return nil, fmt.Errorf("id: %w", err)
+	}
+	task := &model.Task{
+		ID:        id,
+		Tenant:    get("tenant"),
+		CreatedBy: get("created_by"),
+		Type:      get("type"),
+		Status:    model.TaskStatus(get("status")),
+		Result:    get("result"),
+		Error:     get("error"),
+	}
+	if v := get("priority"); v != "" {
+		if task.Priority, err = strconv.Atoi(v); err != nil {
+			return nil, fmt.Errorf("priority: %w", err)
+		}
+	}
+	if v := get("parent_id"); v != "" {
+		parentID, err := uuid.Parse(v)
+		if err != nil {
+			return nil, fmt.Errorf("parent_id: %w", err)
+		}
+		task.ParentID = &parentID
+	}
+	if v := get("labels"); v != "" {
+		if err := json.Unmarshal([]byte(v), &task.Labels); err != nil {
+			return nil, fmt.Errorf("labels: %w", err)
+		}
+	}
+	if task.RunAt, err = parseTime(get("run_at")); err != nil {
+		return nil, fmt.Errorf("run_at: %w", err)
+	}
+	if v := get("timeout"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("timeout: %w", err)
+		}
+		task.Timeout = model.Duration(d)
+	}
+	if v := get("payload"); v != "" {
+		if !json.Valid([]byte(v)) {
+			return nil, errors.New("payload: некорректный JSON")
+		}
+		task.Payload = json.RawMessage(v)
+	}
+	if v := get("version"); v != "" {
+		if task.Version, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return nil, fmt.Errorf("version: %w", err)
+		}
+	}
+	created, err := parseTime(get("created_at"))
+	if err != nil {
+		return nil, fmt.Errorf("created_at: %w", err)
+	}
+	if created != nil {
+		task.CreatedAt = *created
+	}
+	if task.StartedAt, err = parseTime(get("started_at")); err != nil {
+		return nil, fmt.Errorf("started_at: %w", err)
+	}
+	if task.FinishedAt, err = parseTime(get("finished_at")); err != nil {
+		return nil, fmt.Errorf("finished_at: %w", err)
+	}
+	return task, nil
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339Nano)
+}
+
+func parseTime(s string) (*time.Time, error) {
+	if s == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
@@ -0,0 +1,283 @@
+package tasklog
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Line - одна строка журнала задачи
+type Line struct {
+	// Seq - порядковый номер строки, начиная с 1; служит курсором для since
+	Seq     int64     `json:"seq"`
+	Time    time.Time `json:"time"`
+	Message string    `json:"message"`
+}
+
+// Buffer хранит последние строки журнала одной задачи в кольцевом буфере.
+// Если задан файл, все строки дополнительно дописываются в него в формате NDJSON,
+// и более старые строки читаются оттуда.
+type Buffer struct {
+	mu sync.Mutex
+	// ring растёт по мере записи до capacity строк, затем start указывает на самую старую
+	ring     []Line
+	start    int
+	capacity int
+	next     int64
+	path     string
+	file     *os.File
+	// spillFailed - не все строки записаны в файл, поэтому буфер нельзя выгрузить из памяти
+	spillFailed bool
+	done        bool
+	// notify закрывается и пересоздаётся при каждой записи и при завершении
+	notify chan struct{}
+	// release вызывается после Finish, если журнал целиком сохранён в файле
+	release func(*Buffer)
+}
+
+func newBuffer(capacity int, path string) *Buffer {
+	return &Buffer{capacity: capacity, next: 1, path: path, notify: make(chan struct{})}
+}
+
+// Append добавляет в журнал строку msg, записанную в момент at
+func (b *Buffer) Append(at time.Time, msg string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	line := Line{Seq: b.next, Time: at, Message: msg}
+	b.next++
+	b.push(line)
+	if b.path != "" {
+		b.spill(line)
+	}
+	b.wake()
+}
+
+// push кладёт строку в кольцо, вытесняя самую старую; вызывается под mu
+func (b *Buffer) push(line Line) {
+	if b.capacity <= 0 {
+		return
+	}
+	if len(b.ring) < b.capacity {
+		b.ring = append(b.ring, line)
+		return
+	}
+	b.ring[b.start] = line
+	b.start = (b.start + 1) % len(b.ring)
+}
+
+// spill дописывает строку в файл; ошибки записи не должны прерывать обработку задачи
+func (b *Buffer) spill(line Line) {
+	if b.file == nil {
+		f, err := os.OpenFile(b.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			b.spillFailed = true
+			return
+		}
+		b.file = f
+	}
+	data, _ := json.Marshal(line)
+	if _, err := b.file.Write(append(data, '\n')); err != nil {
+		b.spillFailed = true
+	}
+}
+
+// wake будит ожидающих новые строки; вызывается под mu
+func (b *Buffer) wake() {
+	close(b.notify)
+	b.notify = make(chan struct{})
+}
+
+// Finish отмечает, что задача больше не пишет в журнал (до следующего Reopen).
+// Строки журнала, целиком сохранённого в файле, освобождаются из памяти:
+// дальше они читаются из файла.
+func (b *Buffer) Finish() {
+	b.mu.Lock()
+	b.done = true
+	if b.file != nil {
+		b.file.Close()
+		b.file = nil
+	}
+	release := b.release != nil && b.path != "" && !b.spillFailed
+	if release {
+		b.ring, b.start = nil, 0
+	}
+	b.wake()
+	b.mu.Unlock()
+	if release {
+		b.release(b)
+	}
+}
+
+// reopen снимает отметку о завершении перед повторным запуском задачи
+func (b *Buffer) reopen() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.done = false
+}
+
+// Lines возвращает строки с номером больше since; tail > 0 оставляет только последние tail строк
+func (b *Buffer) Lines(since int64, tail int) ([]Line, error) {
+	lines, _, _, err := b.Next(since)
+	if tail > 0 && len(lines) > tail {
+		lines = lines[len(lines)-tail:]
+	}
+	return lines, err
+}
+
+// Next возвращает строки с номером больше since, канал, закрывающийся при появлении новых строк,
+// и признак того, что задача завершила запись в журнал
+func (b *Buffer) Next(since int64) ([]Line, <-chan struct{}, bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if since < 0 {
+		since = 0
+	}
+	var lines []Line
+	var err error
+	oldest := b.next - int64(len(b.ring))
+	// Запрошенные строки уже вытеснены из кольца - читаем их из файла
+	if since+1 < oldest && b.path != "" {
+		lines, err = readFile(b.path, since, oldest)
+	}
+	for i := 0; i < len(b.ring); i++ {
+		if line := b.ring[(b.start+i)%len(b.ring)]; line.Seq > since {
+			lines = append(lines, line)
+		}
+	}
+	return lines, b.notify, b.done, err
+}
+
+// readFile читает из файла строки с номерами в (since, before)
+func readFile(path string, since, before int64) ([]Line, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var lines []Line
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 1<<20)
+	for sc.Scan() {
+		var line Line
+		if json.Unmarshal(sc.Bytes(), &line) != nil {
+			continue
+		}
+		if line.Seq > since && line.Seq < before {
+			lines = append(lines, line)
+		}
+	}
+	return lines, sc.Err()
+}
+
+// Store хранит журналы задач
+type Store struct {
+	mu       sync.Mutex
+	capacity int
+	dir      string
+	bufs     map[uuid.UUID]*Buffer
+}
+
+// NewStore создаёт хранилище журналов, держащее в памяти до capacity последних строк каждой задачи.
+// Непустой dir включает сохранение журналов на диск, что позволяет читать их после перезапуска;
+// журналы завершённых задач тогда не занимают память и читаются с диска.
+func NewStore(capacity int, dir string) (*Store, error) {
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, err
+		}
+	}
+	return &Store{capacity: capacity, dir: dir, bufs: make(map[uuid.UUID]*Buffer)}, nil
+}
+
+func (s *Store) path(id uuid.UUID) string {
+	if s.dir == "" {
+		return ""
+	}
+	return filepath.Join(s.dir, id.String()+".log")
+}
+
+// Open возвращает журнал задачи для записи, создавая его при необходимости
+func (s *Store) Open(id uuid.UUID) *Buffer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.bufs[id]
+	if !ok {
+		b = s.loadLocked(id)
+		s.bufs[id] = b
+	}
+	b.reopen()
+	return b
+}
+
+// Get возвращает журнал задачи. Журнал, выгруженный из памяти или сохранённый до перезапуска,
+// читается с диска и в памяти не остаётся.
+func (s *Store) Get(id uuid.UUID) (*Buffer, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if b, ok := s.bufs[id]; ok {
+		return b, true
+	}
+	if s.dir == "" {
+		return nil, false
+	}
+	if _, err := os.Stat(s.path(id)); err != nil {
+		return nil, false
+	}
+	return s.loadLocked(id), true
+}
+
+// loadLocked создаёт завершённый буфер сохранённого журнала, нумерация которого продолжает
+// строки на диске; сами строки читаются из файла по запросу. Вызывается под mu.
+func (s *Store) loadLocked(id uuid.UUID) *Buffer {
+	b := newBuffer(s.capacity, s.path(id))
+	b.done = true
+	if b.path == "" {
+		return b
+	}
+	b.release = func(b *Buffer) { s.release(id, b) }
+	lines, _ := readFile(b.path, 0, 1<<62)
+	if n := len(lines); n > 0 {
+		b.next = lines[n-1].Seq + 1
+	}
+	return b
+}
+
+// release выгружает из памяти завершённый журнал, сохранённый на диске
+func (s *Store) release(id uuid.UUID, b *Buffer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b.mu.Lock()
+	done := b.done
+	b.mu.Unlock()
+	// Буфер мог быть снова открыт для следующей попытки или удалён
+	if done && s.bufs[id] == b {
+		delete(s.bufs, id)
+	}
+}
+
+// Delete удаляет журнал задачи из памяти и с диска
+func (s *Store) Delete(id uuid.UUID) error {
+	s.mu.Lock()
+	b, ok := s.bufs[id]
+	delete(s.bufs, id)
+	s.mu.Unlock()
+	if ok {
+		b.Finish()
+	}
+	if s.dir == "" {
+		return nil
+	}
+	if err := os.Remove(s.path(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package tasklog
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TestBuffer_RingAndSpill проверяет вытеснение старых строк из памяти, чтение вытесненных строк
+// с диска, выгрузку завершённого журнала из памяти и загрузку журнала после перезапуска.
+func TestBuffer_RingAndSpill(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewStore(3, dir)
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	id := uuid.New()
+	start := time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)
+	b := s.Open(id)
+	if len(b.ring) != 0 {
+		t.Errorf("кольцо должно расти по мере записи, выделено %d строк", len(b.ring))
+	}
+	for i := 1; i <= 5; i++ {
+		b.Append(start.Add(time.Duration(i)*time.Second), fmt.Sprintf("строка %d", i))
+	}
+
+	lines, err := b.Lines(0, 0)
+	if err != nil || len(lines) != 5 || lines[0].Seq != 1 || lines[4].Seq != 5 {
+		t.Fatalf("ожидались строки 1..5 с учётом диска, получили %+v (%v)", lines, err)
+	}
+	if !lines[0].Time.Equal(start.Add(time.Second)) {
+		t.Errorf("время строки должно быть переданным, получили %s", lines[0].Time)
+	}
+	if lines, _ := b.Lines(3, 0); len(lines) != 2 || lines[0].Message != "строка 4" {
+		t.Errorf("since=3: ожидались строки 4 и 5, получили %+v", lines)
+	}
+	if lines, _ := b.Lines(0, 2); len(lines) != 2 || lines[1].Seq != 5 {
+		t.Errorf("tail=2: ожидались строки 4 и 5, получили %+v", lines)
+	}
+
+	_, wait, done, _ := b.Next(5)
+	if done {
+		t.Errorf("журнал не должен быть завершён до Finish")
+	}
+	b.Finish()
+	select {
+	case <-wait:
+	default:
+		t.Errorf("Finish должен будить ожидающих")
+	}
+	if got, ok := s.Get(id); !ok || got == b || len(b.ring) != 0 {
+		t.Errorf("завершённый журнал должен быть выгружен из памяти")
+	}
+	if lines, _ := b.Lines(0, 0); len(lines) != 5 {
+		t.Errorf("после выгрузки ожидалось 5 строк с диска, получили %d", len(lines))
+	}
+
+	// Без каталога журнал остаётся в памяти
+	mem, _ := NewStore(3, "")
+	mb := mem.Open(id)
+	mb.Append(start, "в памяти")
+	mb.Finish()
+	if got, ok := mem.Get(id); !ok || got != mb {
+		t.Errorf("журнал без диска должен остаться в памяти")
+	}
+
+	// Новое хранилище поверх того же каталога видит журнал
+	s2, _ := NewStore(3, dir)
+	restored, ok := s2.Get(id)
+	if !ok {
+		t.Fatalf("журнал не найден после перезапуска")
+	}
+	if lines, _ := restored.Lines(0, 0); len(lines) != 5 {
+		t.Errorf("после перезапуска ожидалось 5 строк, получили %d", len(lines))
+	}
+	restored = s2.Open(id)
+	restored.Append(start, "после перезапуска")
+	if lines, _ := restored.Lines(5, 0); len(lines) != 1 || lines[0].Seq != 6 {
+		t.Errorf("нумерация должна продолжаться, получили %+v", lines)
+	}
+
+	if err := s2.Delete(id); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, ok := s2.Get(id); ok {
+		t.Errorf("журнал должен быть удалён")
+	}
+}
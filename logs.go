@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+
+	"workmateTestProject/internal/service"
+	"workmateTestProject/internal/storage"
+	"workmateTestProject/internal/tasklog"
+)
+
+// logPollInterval - как часто потоковый запрос проверяет, не запустилась ли ожидающая задача
+const logPollInterval = 500 * time.Millisecond
+
+// getLogsHandler отдаёт журнал задачи в формате NDJSON.
+// Параметры: since - номер строки, после которой начинать; tail - только последние N строк;
+// follow=true - держать соединение открытым и передавать новые строки до завершения задачи.
+func getLogsHandler(proc *service.Processor, store storage.TaskStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(mux.Vars(r)["id"])
+		if err != nil {
+			errorResponse(w, http.StatusBadRequest, "Неверный UUID")
+			return
+		}
+		if _, ok := tenantStore(r, proc, store).Get(id); !ok {
+			errorResponse(w, http.StatusNotFound, "Задача не найдена")
+			return
+		}
+		q := r.URL.Query()
+		var since int64
+		var tail int
+		if v := q.Get("since"); v != "" {
+			if since, err = strconv.ParseInt(v, 10, 64); err != nil || since < 0 {
+				errorResponse(w, http.StatusBadRequest, "since должен быть неотрицательным номером строки")
+				return
+			}
+		}
+		if v := q.Get("tail"); v != "" {
+			if tail, err = strconv.Atoi(v); err != nil || tail < 0 {
+				errorResponse(w, http.StatusBadRequest, "tail должен быть неотрицательным числом")
+				return
+			}
+		}
+		follow := q.Get("follow") == "true"
+
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		enc := json.NewEncoder(w)
+		buf, ok := proc.TaskLogs(id)
+		if !follow {
+			if !ok {
+				return
+			}
+			lines, err := buf.Lines(since, tail)
+			if err != nil {
+				errorResponse(w, http.StatusInternalServerError, "Ошибка чтения журнала")
+				return
+			}
+			for _, line := range lines {
+				enc.Encode(line)
+			}
+			return
+		}
+
+		// Задача ещё не запускалась - ждём появления журнала
+		flusher, _ := w.(http.Flusher)
+		w.WriteHeader(http.StatusOK)
+		if flusher != nil {
+			flusher.Flush()
+		}
+		for !ok {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(logPollInterval):
+			}
+			if _, exists := tenantStore(r, proc, store).Get(id); !exists {
+				return
+			}
+			buf, ok = proc.TaskLogs(id)
+		}
+		followLogs(r, enc, flusher, buf, since, tail)
+	}
+}
+
+// followLogs передаёт строки журнала по мере появления, пока задача не завершится или клиент не отключится
+func followLogs(r *http.Request, enc *json.Encoder, flusher http.Flusher, buf *tasklog.Buffer, since int64, tail int) {
+	first := true
+	for {
+		lines, wait, done, err := buf.Next(since)
+		if err != nil {
+			return
+		}
+		// tail применяется только к уже накопленным строкам
+		if first && tail > 0 && len(lines) > tail {
+			lines = lines[len(lines)-tail:]
+		}
+		first = false
+		for _, line := range lines {
+			if enc.Encode(line) != nil {
+				return
+			}
+			since = line.Seq
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		if done {
+			return
+		}
+		select {
+		case <-wait:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
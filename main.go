@@ -1,19 +1,29 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"flag"
+	"io"
 	"log"
+	"mime"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 
+	"workmateTestProject/internal/archive"
+	"workmateTestProject/internal/audit"
+	"workmateTestProject/internal/auth"
 	"workmateTestProject/internal/model"
+	"workmateTestProject/internal/retention"
 	"workmateTestProject/internal/service"
 	"workmateTestProject/internal/storage"
 )
@@ -41,21 +51,254 @@ func loggingMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// envDuration читает длительность из переменной окружения, возвращая def при отсутствии или ошибке
+func envDuration(name string, def time.Duration) time.Duration {
+	if d, err := time.ParseDuration(os.Getenv(name)); err == nil && d >= 0 {
+		return d
+	}
+	return def
+}
+
+// loadKeys создаёт хранилище API-ключей из API_KEYS_FILE и ADMIN_API_KEY.
+// Возвращает nil, если ни одна из переменных не задана.
+func loadKeys() (*auth.KeyStore, error) {
+	path := os.Getenv("API_KEYS_FILE")
+	adminKey := os.Getenv("ADMIN_API_KEY")
+	if path == "" && adminKey == "" {
+		return nil, nil
+	}
+	keys := auth.NewKeyStore()
+	if path != "" {
+		var err error
+		if keys, err = auth.LoadKeyFile(path); err != nil {
+			return nil, err
+		}
+	}
+	if adminKey != "" {
+		keys.AddStatic("bootstrap-admin", auth.DefaultTenant, adminKey, []auth.Scope{auth.ScopeTasksAdmin})
+	}
+	return keys, nil
+}
+
+// loadJWT создаёт валидатор JWT из JWT_JWKS_URL или JWT_JWKS_FILE.
+// Возвращает nil, если ни одна из переменных не задана.
+func loadJWT() (*auth.JWTValidator, error) {
+	refresh := envDuration("JWT_JWKS_REFRESH", 10*time.Minute)
+	var jwks *auth.JWKS
+	switch {
+	case os.Getenv("JWT_JWKS_URL") != "":
+		jwks = auth.NewRemoteJWKS(os.Getenv("JWT_JWKS_URL"), refresh, nil)
+	case os.Getenv("JWT_JWKS_FILE") != "":
+		jwks = auth.NewFileJWKS(os.Getenv("JWT_JWKS_FILE"), refresh)
+	default:
+		return nil, nil
+	}
+	// Загружаем ключи сразу, чтобы ошибка конфигурации была видна при старте
+	if err := jwks.Refresh(context.Background()); err != nil {
+		return nil, err
+	}
+	roleScopes, err := auth.ParseRoleScopes(os.Getenv("JWT_ROLE_SCOPES"))
+	if err != nil {
+		return nil, err
+	}
+	return auth.NewJWTValidator(jwks, auth.JWTConfig{
+		Issuer:      os.Getenv("JWT_ISSUER"),
+		Audience:    os.Getenv("JWT_AUDIENCE"),
+		TenantClaim: os.Getenv("JWT_TENANT_CLAIM"),
+		RolesClaim:  os.Getenv("JWT_ROLES_CLAIM"),
+		RoleScopes:  roleScopes,
+		Leeway:      envDuration("JWT_LEEWAY", 30*time.Second),
+	}), nil
+}
+
+// apiDeps - зависимости HTTP API
+type apiDeps struct {
+	store storage.TaskStore
+	// proc - обработчик задач; nil - обработчик по умолчанию (service.Default)
+	proc *service.Processor
+	// keys - API-ключи; nil отключает управление ключами
+	keys *auth.KeyStore
+	// jwt - валидатор JWT; nil отключает проверку JWT
+	jwt *auth.JWTValidator
+	// limiter - ограничитель частоты запросов; nil отключает ограничение
+	limiter *rateLimiter
+	// janitor - сборщик устаревших и удалённых задач; nil отключает просмотр его состояния
+	janitor *retention.Janitor
+	// archive - архив устаревших задач; nil отключает архив
+	archive *archive.Archive
+	// audit - журнал аудита изменяющих запросов; nil отключает аудит
+	audit *audit.Log
+	// poolSettingsFile - файл, в котором сохраняются настройки пула; пустая строка отключает сохранение
+	poolSettingsFile string
+}
+
+// authenticator собирает цепочку аутентификации; nil означает, что аутентификация отключена
+func (d apiDeps) authenticator() auth.Authenticator {
+	var chain auth.Chain
+	if d.keys != nil {
+		chain = append(chain, d.keys)
+	}
+	if d.jwt != nil {
+		chain = append(chain, d.jwt)
+	}
+	if len(chain) == 0 {
+		return nil
+	}
+	return chain
+}
+
+// newRouter регистрирует все маршруты API
+func newRouter(d apiDeps) *mux.Router {
+	store, keys, proc := d.store, d.keys, d.proc
+	if proc == nil {
+		proc = service.Default()
+	}
+	r := mux.NewRouter()
+	r.Use(requestIDMiddleware)
+	r.Use(auditMiddleware(d.audit))
+	r.Use(ipRateLimitMiddleware(d.limiter))
+	r.Use(authMiddleware(d.authenticator()))
+	r.Use(rateLimitMiddleware(d.limiter))
+
+	// Роуты для работы с задачами
+	r.HandleFunc("/tasks", requireScope(auth.ScopeTasksWrite, createTaskHandler(proc, store))).Methods(http.MethodPost)
+	r.HandleFunc("/tasks", requireScope(auth.ScopeTasksRead, listTasksHandler(proc, store))).Methods(http.MethodGet)
+	r.HandleFunc("/tasks:export", requireScope(auth.ScopeTasksRead, exportTasksHandler(proc, store))).Methods(http.MethodGet)
+	r.HandleFunc("/tasks:import", requireScope(auth.ScopeTasksAdmin, importTasksHandler(proc, store))).Methods(http.MethodPost)
+	r.HandleFunc("/tasks/{id}", requireScope(auth.ScopeTasksRead, getTaskHandler(proc, store))).Methods(http.MethodGet)
+	r.HandleFunc("/tasks/{id}", requireScope(auth.ScopeTasksWrite, patchTaskHandler(proc, store))).Methods(http.MethodPatch)
+	r.HandleFunc("/tasks/{id}", requireScope(auth.ScopeTasksWrite, deleteTaskHandler(proc, store))).Methods(http.MethodDelete)
+	r.HandleFunc("/tasks/{id}/rerun", requireScope(auth.ScopeTasksWrite, copyTaskHandler(proc, store, true))).Methods(http.MethodPost)
+	r.HandleFunc("/tasks/{id}/clone", requireScope(auth.ScopeTasksWrite, copyTaskHandler(proc, store, false))).Methods(http.MethodPost)
+	r.HandleFunc("/tasks/{id}/lineage", requireScope(auth.ScopeTasksRead, lineageHandler(proc, store))).Methods(http.MethodGet)
+	r.HandleFunc("/tasks/{id}/cancel", requireScope(auth.ScopeTasksWrite, cancelTaskHandler(proc, store))).Methods(http.MethodPost)
+	r.HandleFunc("/tasks/{id}/restore", requireScope(auth.ScopeTasksWrite, undeleteTaskHandler(proc, store))).Methods(http.MethodPost)
+	r.HandleFunc("/tasks/{id}/history", requireScope(auth.ScopeTasksRead, getHistoryHandler(proc, store))).Methods(http.MethodGet)
+	r.HandleFunc("/tasks/{id}/logs", requireScope(auth.ScopeTasksRead, getLogsHandler(proc, store))).Methods(http.MethodGet)
+	r.HandleFunc("/tasks/{id}/artifacts/{name}", requireScope(auth.ScopeTasksRead, getArtifactHandler(proc, store))).Methods(http.MethodGet, http.MethodHead)
+	r.HandleFunc("/queue", requireScope(auth.ScopeTasksRead, queueStatsHandler(proc))).Methods(http.MethodGet)
+
+	// Управление пулом обработчиков
+	r.HandleFunc("/admin/pool", requireScope(auth.ScopeTasksAdmin, getPoolHandler(proc))).Methods(http.MethodGet)
+	r.HandleFunc("/admin/pool/concurrency", requireScope(auth.ScopeTasksAdmin, setConcurrencyHandler(proc, d.poolSettingsFile))).Methods(http.MethodPut)
+	r.HandleFunc("/admin/pool/pause", requireScope(auth.ScopeTasksAdmin, pauseHandler(proc, d.poolSettingsFile, true))).Methods(http.MethodPost)
+	r.HandleFunc("/admin/pool/resume", requireScope(auth.ScopeTasksAdmin, pauseHandler(proc, d.poolSettingsFile, false))).Methods(http.MethodPost)
+
+	if d.archive != nil {
+		r.HandleFunc("/archive/tasks", requireScope(auth.ScopeTasksRead, searchArchiveHandler(d.archive))).Methods(http.MethodGet)
+		r.HandleFunc("/archive/tasks/{id}/restore", requireScope(auth.ScopeTasksWrite, restoreArchivedHandler(proc, d.archive, store))).Methods(http.MethodPost)
+	}
+	if d.audit != nil {
+		r.HandleFunc("/audit", requireScope(auth.ScopeTasksAdmin, auditHandler(d.audit))).Methods(http.MethodGet)
+		r.HandleFunc("/audit/verify", requireScope(auth.ScopeTasksAdmin, auditVerifyHandler(d.audit))).Methods(http.MethodGet)
+	}
+	if d.janitor != nil {
+		r.HandleFunc("/admin/retention", requireScope(auth.ScopeTasksAdmin, retentionHandler(d.janitor))).Methods(http.MethodGet)
+	}
+
+	// Управление API-ключами доступно только при включённой аутентификации
+	if keys != nil {
+		r.HandleFunc("/admin/keys", requireScope(auth.ScopeTasksAdmin, listKeysHandler(keys))).Methods(http.MethodGet)
+		r.HandleFunc("/admin/keys", requireScope(auth.ScopeTasksAdmin, createKeyHandler(keys))).Methods(http.MethodPost)
+		r.HandleFunc("/admin/keys/{id}", requireScope(auth.ScopeTasksAdmin, revokeKeyHandler(keys))).Methods(http.MethodDelete)
+	}
+	return r
+}
+
 func main() {
+	// Подкоманда bench запускает нагрузочное испытание вместо сервера
+	if len(os.Args) > 1 && os.Args[1] == "bench" {
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		err := runBench(ctx, os.Args[2:], os.Stdout)
+		stop()
+		if err != nil && !errors.Is(err, flag.ErrHelp) {
+			log.Fatalf("bench: %v", err)
+		}
+		return
+	}
+
 	// Создаём in-memory хранилище задач
 	store := storage.NewInMemoryTaskStore()
+	proc := service.Default()
 
-	// Настраиваем маршрутизатор
-	r := mux.NewRouter()
+	// Применяем сохранённые настройки пула до восстановления задач, чтобы пауза действовала сразу
+	poolSettingsFile := os.Getenv("POOL_SETTINGS_FILE")
+	if poolSettingsFile != "" {
+		if err := proc.LoadSettings(poolSettingsFile); err != nil {
+			log.Printf("Ошибка загрузки настроек пула из %s: %v", poolSettingsFile, err)
+		}
+	}
 
-	// подмешиваем логирование
-	h := loggingMiddleware(r)
+	// Хранилище артефактов нужно обработчикам до восстановления задач
+	artifacts, err := loadArtifactStore()
+	if err != nil {
+		log.Fatalf("Ошибка настройки хранилища артефактов: %v", err)
+	}
+	proc.SetArtifactStore(artifacts)
 
-	// Роуты для работы с задачами
-	r.HandleFunc("/tasks", createTaskHandler(store)).Methods(http.MethodPost)
-	r.HandleFunc("/tasks", listTasksHandler(store)).Methods(http.MethodGet)
-	r.HandleFunc("/tasks/{id}", getTaskHandler(store)).Methods(http.MethodGet)
-	r.HandleFunc("/tasks/{id}", deleteTaskHandler(store)).Methods(http.MethodDelete)
+	// Исполнители назначаются до восстановления задач, которые сразу встают в очередь
+	stopExecutors, err := loadExecutors(proc)
+	if err != nil {
+		log.Fatalf("Ошибка настройки исполнителей: %v", err)
+	}
+
+	// История задач загружается до восстановления задач, чтобы продолжить её, а не начать заново
+	history := storage.NewHistory()
+	historyFile := os.Getenv("HISTORY_FILE")
+	if historyFile != "" {
+		if err := history.Load(historyFile); err != nil {
+			log.Printf("Ошибка загрузки истории задач из %s: %v", historyFile, err)
+		}
+	}
+	proc.SetHistory(history)
+
+	// Если задан файл состояния, восстанавливаем задачи, оставшиеся от предыдущего запуска
+	stateFile := os.Getenv("STATE_FILE")
+	if stateFile != "" {
+		restoreTasks(proc, store, stateFile)
+	}
+
+	// Загружаем API-ключи и настройки JWT; без них аутентификация отключена
+	keys, err := loadKeys()
+	if err != nil {
+		log.Fatalf("Ошибка загрузки API-ключей: %v", err)
+	}
+	jwt, err := loadJWT()
+	if err != nil {
+		log.Fatalf("Ошибка настройки проверки JWT: %v", err)
+	}
+	limiter, err := loadRateLimiter()
+	if err != nil {
+		log.Fatalf("Ошибка настройки ограничения частоты: %v", err)
+	}
+	var arch *archive.Archive
+	if dir := os.Getenv("ARCHIVE_DIR"); dir != "" {
+		if arch, err = archive.New(dir); err != nil {
+			log.Fatalf("Ошибка открытия архива %s: %v", dir, err)
+		}
+	}
+	janitor, err := loadJanitor(proc, store, arch)
+	if err != nil {
+		log.Fatalf("Ошибка настройки политики хранения: %v", err)
+	}
+	janitorCtx, stopJanitor := context.WithCancel(context.Background())
+	defer stopJanitor()
+	go janitor.Run(janitorCtx, envDuration("RETENTION_INTERVAL", time.Minute))
+	auditLog, err := audit.Open(os.Getenv("AUDIT_LOG_FILE"))
+	if err != nil {
+		log.Fatalf("Ошибка открытия журнала аудита: %v", err)
+	}
+	defer auditLog.Close()
+	deps := apiDeps{
+		proc: proc, store: store, keys: keys, jwt: jwt, limiter: limiter, janitor: janitor, archive: arch,
+		audit: auditLog, poolSettingsFile: poolSettingsFile,
+	}
+	if deps.authenticator() == nil {
+		log.Println("ВНИМАНИЕ: аутентификация отключена, задайте API_KEYS_FILE, ADMIN_API_KEY или JWT_JWKS_URL")
+	}
+
+	// Настраиваем маршрутизатор и подмешиваем логирование
+	h := loggingMiddleware(newRouter(deps))
 
 	// Определяем порт из переменной окружения
 	port := os.Getenv("PORT")
@@ -82,40 +325,189 @@ func main() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 	log.Println("Получен сигнал завершения, выключаем сервер...")
+	stopJanitor()
 
 	// Пытаемся корректно завершить с таймаутом
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	if err := srv.Shutdown(ctx); err != nil {
-		log.Fatalf("Сервер не завершился корректно: %v", err)
+		log.Printf("Сервер не завершился корректно: %v", err)
+	}
+
+	// Даём запущенным задачам время завершиться, остальные прерываем
+	drain := envDuration("SHUTDOWN_DRAIN_TIMEOUT", 30*time.Second)
+	log.Printf("Ожидаем завершения задач (до %s)...", drain)
+	drainCtx, drainCancel := context.WithTimeout(context.Background(), drain)
+	defer drainCancel()
+	if err := proc.Shutdown(drainCtx); err != nil {
+		log.Printf("Не все задачи успели завершиться, незавершённые прерваны: %v", err)
+	}
+	stopExecutors()
+
+	if stateFile != "" {
+		if err := storage.SaveSnapshot(store, stateFile); err != nil {
+			log.Printf("Ошибка сохранения состояния в %s: %v", stateFile, err)
+		} else {
+			log.Printf("Состояние сохранено в %s", stateFile)
+		}
+	}
+	if historyFile != "" {
+		if err := history.Save(historyFile); err != nil {
+			log.Printf("Ошибка сохранения истории задач в %s: %v", historyFile, err)
+		}
 	}
 	log.Println("Сервер завершён")
 }
 
-// createTaskHandler обрабатывает создание новой задачи
-func createTaskHandler(store storage.TaskStore) http.HandlerFunc {
+// restoreTasks загружает задачи из файла состояния и повторно запускает незавершённые
+func restoreTasks(proc *service.Processor, store storage.TaskStore, path string) {
+	tasks, err := storage.LoadSnapshot(store, path)
+	if err != nil {
+		log.Printf("Ошибка загрузки состояния из %s: %v", path, err)
+		return
+	}
+	requeued := 0
+	for _, task := range tasks {
+		// Задачи, сохранённые до появления арендаторов, относим к арендатору по умолчанию
+		if task.Tenant == "" {
+			task.Tenant = auth.DefaultTenant
+		}
+		// История могла не сохраниться (HISTORY_FILE не задан) - начинаем её со снимка
+		if len(proc.TaskHistory(task.ID)) == 0 {
+			proc.RecordSnapshot(task, model.EventCreated, model.ActorSystem, "восстановлена из файла состояния")
+		}
+		if !task.Status.Requeueable() {
+			continue
+		}
+		proc.RecordEvent(task, model.Event{Type: model.EventRetried, Message: "перезапуск после остановки сервиса"})
+		if err := proc.StartProcessing(task); err != nil {
+			log.Printf("Не удалось перезапустить задачу %s: %v", task.ID, err)
+			continue
+		}
+		requeued++
+	}
+	log.Printf("Восстановлено задач: %d, повторно поставлено в очередь: %d", len(tasks), requeued)
+}
+
+// shedRetryAfter - через сколько секунд клиенту предлагается повторить запрос при перегрузке очереди
+var shedRetryAfter = envDuration("SHED_RETRY_AFTER", 5*time.Second)
+
+// setQueueHeaders сообщает клиенту текущую загрузку очереди, чтобы он мог снизить темп
+func setQueueHeaders(w http.ResponseWriter, proc *service.Processor) {
+	st := proc.Stats()
+	w.Header().Set("X-Queue-Depth", strconv.Itoa(st.Queued))
+	w.Header().Set("X-Queue-Pressure", strconv.FormatFloat(st.Pressure, 'f', 2, 64))
+}
+
+// createTaskHandler обрабатывает создание новой задачи. Тело запроса необязательно:
+// {"type": "...", "priority": 0, "labels": {...}, "run_at": "...", "timeout": "5m", "payload": {...}}.
+func createTaskHandler(proc *service.Processor, store storage.TaskStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// Создаём новую задачу
-		id := uuid.New()
+		var req struct {
+			Type     string            `json:"type"`
+			Priority int               `json:"priority"`
+			Labels   map[string]string `json:"labels"`
+			RunAt    *time.Time        `json:"run_at"`
+			Timeout  model.Duration    `json:"timeout"`
+			Payload  json.RawMessage   `json:"payload"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			errorResponse(w, http.StatusBadRequest, "Неверный JSON")
+			return
+		}
+
+		// Создаём новую задачу в пространстве арендатора
 		task := &model.Task{
-			ID:        id,
+			ID:        uuid.New(),
 			Status:    model.StatusPending,
-			CreatedAt: time.Now(),
+			CreatedAt: proc.Now(),
+			CreatedBy: principal(r).Name,
+			Type:      req.Type,
+			Priority:  req.Priority,
+			Labels:    req.Labels,
+			RunAt:     req.RunAt,
+			Timeout:   req.Timeout,
+			Payload:   req.Payload,
+		}
+		if err := proc.ValidateTask(task); err != nil {
+			errorResponse(w, http.StatusBadRequest, err.Error())
+			return
 		}
-		store.Create(task)
-		// Запускаем обработку задачи
-		service.StartProcessing(task)
+		submitTask(w, r, proc, tenantStore(r, proc, store), task, "")
+	}
+}
+
+// queueErrorResponse отвечает на отказ обработчика принять задачу в очередь
+func queueErrorResponse(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrQueueFull):
+		errorResponse(w, http.StatusTooManyRequests, "Превышен лимит задач в очереди")
+	case errors.Is(err, service.ErrOverloaded), errors.Is(err, service.ErrTypeQueueFull):
+		w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(shedRetryAfter.Seconds())))
+		errorResponse(w, http.StatusServiceUnavailable, "Очередь переполнена, повторите позже")
+	default:
+		errorResponse(w, http.StatusServiceUnavailable, "Сервис завершает работу, попробуйте позже")
+	}
+}
+
+// submitTask сохраняет новую задачу, начинает её историю снимком с пояснением message
+// и ставит задачу в очередь. При отказе очереди задача удаляется, и клиенту возвращается
+// 429 или 503; при успехе - 201 с задачей.
+func submitTask(w http.ResponseWriter, r *http.Request, proc *service.Processor, store storage.TaskStore, task *model.Task, message string) {
+	id := task.ID
+	// Снимок записывается в задачу до того, как она станет видна в хранилище
+	proc.RecordSnapshot(task, model.EventCreated, task.CreatedBy, message)
+	store.Create(task)
+	setAuditTarget(r, id.String())
+	// Запускаем обработку задачи
+	err := proc.StartProcessing(task)
+	setQueueHeaders(w, proc)
+	if err != nil {
+		store.Delete(id)
+		proc.DeleteHistory(id)
+		queueErrorResponse(w, err)
+		return
+	}
+
+	// Задачу уже может изменять горутина обработки, поэтому отвечаем её согласованной копией
+	task = proc.Snapshot(task)
+	setTaskETag(w, task)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(task); err != nil {
+		errorResponse(w, http.StatusInternalServerError, "Ошибка кодирования ответа")
+	}
+}
 
+// queueStatsHandler возвращает текущую загрузку очереди
+func queueStatsHandler(proc *service.Processor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusCreated)
-		if err := json.NewEncoder(w).Encode(task); err != nil {
+		if err := json.NewEncoder(w).Encode(proc.Stats()); err != nil {
 			errorResponse(w, http.StatusInternalServerError, "Ошибка кодирования ответа")
 		}
 	}
 }
 
-// getTaskHandler возвращает информацию о задаче по ID
-func getTaskHandler(store storage.TaskStore) http.HandlerFunc {
+// taskResponse - представление задачи в ответах API с вычисленной длительностью обработки
+type taskResponse struct {
+	*model.Task
+	Duration *string `json:"duration,omitempty"`
+}
+
+// newTaskResponse подготавливает ответ с вычислением длительности
+func newTaskResponse(task *model.Task) taskResponse {
+	resp := taskResponse{Task: task}
+	if task.StartedAt != nil && task.FinishedAt != nil {
+		d := task.FinishedAt.Sub(*task.StartedAt).String()
+		resp.Duration = &d
+	}
+	return resp
+}
+
+// getTaskHandler возвращает информацию о задаче по ID.
+// Ответ содержит ETag с версией задачи; при совпадении If-None-Match возвращается 304 без тела.
+func getTaskHandler(proc *service.Processor, store storage.TaskStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
 		id, err := uuid.Parse(vars["id"])
@@ -123,76 +515,58 @@ func getTaskHandler(store storage.TaskStore) http.HandlerFunc {
 			errorResponse(w, http.StatusBadRequest, "Неверный UUID")
 			return
 		}
-		task, ok := store.Get(id)
+		task, ok := tenantStore(r, proc, store).Get(id)
 		if !ok {
 			errorResponse(w, http.StatusNotFound, "Задача не найдена")
 			return
 		}
+		task = proc.Snapshot(task)
 
-		// Подготавливаем ответ с вычислением длительности
-		type respT struct {
-			ID         uuid.UUID        `json:"id"`
-			Status     model.TaskStatus `json:"status"`
-			CreatedAt  time.Time        `json:"created_at"`
-			StartedAt  *time.Time       `json:"started_at,omitempty"`
-			FinishedAt *time.Time       `json:"finished_at,omitempty"`
-			Duration   *string          `json:"duration,omitempty"`
-			Result     string           `json:"result,omitempty"`
-			Error      string           `json:"error,omitempty"`
+		if notModified(w, r, task) {
+			return
 		}
-		resp := respT{
-			ID:         task.ID,
-			Status:     task.Status,
-			CreatedAt:  task.CreatedAt,
-			StartedAt:  task.StartedAt,
-			FinishedAt: task.FinishedAt,
-			Result:     task.Result,
-			Error:      task.Error,
+		resp := newTaskResponse(task)
+
+		setTaskETag(w, task)
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			errorResponse(w, http.StatusInternalServerError, "Ошибка кодирования ответа")
+		}
+	}
+}
+
+// getHistoryHandler возвращает историю событий задачи в хронологическом порядке
+func getHistoryHandler(proc *service.Processor, store storage.TaskStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(mux.Vars(r)["id"])
+		if err != nil {
+			errorResponse(w, http.StatusBadRequest, "Неверный UUID")
+			return
 		}
-		if task.StartedAt != nil && task.FinishedAt != nil {
-			d := task.FinishedAt.Sub(*task.StartedAt).String()
-			resp.Duration = &d
+		if _, ok := tenantStore(r, proc, store).Get(id); !ok {
+			errorResponse(w, http.StatusNotFound, "Задача не найдена")
+			return
+		}
+		events := proc.TaskHistory(id)
+		if events == nil {
+			events = []model.Event{}
 		}
 
 		w.Header().Set("Content-Type", "application/json")
-		if err := json.NewEncoder(w).Encode(resp); err != nil {
+		if err := json.NewEncoder(w).Encode(events); err != nil {
 			errorResponse(w, http.StatusInternalServerError, "Ошибка кодирования ответа")
 		}
 	}
 }
 
 // listTasksHandler возвращает список всех задач
-func listTasksHandler(store storage.TaskStore) http.HandlerFunc {
+func listTasksHandler(proc *service.Processor, store storage.TaskStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		tasks := store.List()
-
-		// Формируем ответ по аналогии с getTaskHandler
-		type respT struct {
-			ID         uuid.UUID        `json:"id"`
-			Status     model.TaskStatus `json:"status"`
-			CreatedAt  time.Time        `json:"created_at"`
-			StartedAt  *time.Time       `json:"started_at,omitempty"`
-			FinishedAt *time.Time       `json:"finished_at,omitempty"`
-			Duration   *string          `json:"duration,omitempty"`
-			Result     string           `json:"result,omitempty"`
-			Error      string           `json:"error,omitempty"`
-		}
-		responses := make([]respT, 0, len(tasks))
+		tasks := tenantStore(r, proc, store).List()
+
+		responses := make([]taskResponse, 0, len(tasks))
 		for _, task := range tasks {
-			r := respT{
-				ID:         task.ID,
-				Status:     task.Status,
-				CreatedAt:  task.CreatedAt,
-				StartedAt:  task.StartedAt,
-				FinishedAt: task.FinishedAt,
-				Result:     task.Result,
-				Error:      task.Error,
-			}
-			if task.StartedAt != nil && task.FinishedAt != nil {
-				d := task.FinishedAt.Sub(*task.StartedAt).String()
-				r.Duration = &d
-			}
-			responses = append(responses, r)
+			responses = append(responses, newTaskResponse(proc.Snapshot(task)))
 		}
 
 		w.Header().Set("Content-Type", "application/json")
@@ -202,8 +576,17 @@ func listTasksHandler(store storage.TaskStore) http.HandlerFunc {
 	}
 }
 
-// deleteTaskHandler удаляет задачу по ID
-func deleteTaskHandler(store storage.TaskStore) http.HandlerFunc {
+// cancelTimeout - сколько DELETE ждёт остановки выполняющейся задачи
+var cancelTimeout = envDuration("CANCEL_TIMEOUT", 10*time.Second)
+
+// deleteGrace - в течение какого времени мягко удалённую задачу можно восстановить
+var deleteGrace = envDuration("DELETE_GRACE_PERIOD", 24*time.Hour)
+
+// deleteTaskHandler удаляет задачу по ID. Ожидающая или выполняющаяся задача сначала отменяется.
+// По умолчанию удаление мягкое: задача скрывается и может быть восстановлена в течение deleteGrace.
+// С purge=true задача (в том числе уже мягко удалённая) удаляется сразу вместе с артефактами и журналом.
+// If-Match ограничивает удаление версией задачи, известной клиенту.
+func deleteTaskHandler(proc *service.Processor, store storage.TaskStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
 		id, err := uuid.Parse(vars["id"])
@@ -211,7 +594,181 @@ func deleteTaskHandler(store storage.TaskStore) http.HandlerFunc {
 			errorResponse(w, http.StatusBadRequest, "Неверный UUID")
 			return
 		}
+		purge := r.URL.Query().Get("purge") == "true"
+		store := storage.ForTenant(store, principal(r).Tenant)
+		defer lockTask(id)()
+		task, ok := store.Get(id)
+		if !ok {
+			errorResponse(w, http.StatusNotFound, "Задача не найдена")
+			return
+		}
+		// Выполняющуюся задачу изменяет горутина обработки, поэтому условия проверяются по её копии
+		snap := proc.Snapshot(task)
+		switch {
+		case snap.Deleted() && !purge:
+			errorResponse(w, http.StatusNotFound, "Задача не найдена")
+			return
+		case !checkIfMatch(w, r, snap):
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), cancelTimeout)
+		defer cancel()
+		if err := proc.Cancel(ctx, id, principal(r).Name); err != nil {
+			errorResponse(w, http.StatusConflict, "Задача не остановилась вовремя, повторите удаление позже")
+			return
+		}
+
+		if !purge {
+			proc.RecordEvent(task, model.Event{Type: model.EventDeleted, Actor: principal(r).Name})
+			// Версия нужна клиенту для условного восстановления
+			setTaskETag(w, proc.Snapshot(task))
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
 		store.Delete(id)
+		purgeTaskData(proc)(r.Context(), []*model.Task{task})
 		w.WriteHeader(http.StatusNoContent)
 	}
 }
+
+// undeleteTaskHandler восстанавливает мягко удалённую задачу, если не истёк срок восстановления
+func undeleteTaskHandler(proc *service.Processor, store storage.TaskStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(mux.Vars(r)["id"])
+		if err != nil {
+			errorResponse(w, http.StatusBadRequest, "Неверный UUID")
+			return
+		}
+		defer lockTask(id)()
+		task, ok := storage.ForTenant(store, principal(r).Tenant).Get(id)
+		if !ok {
+			errorResponse(w, http.StatusNotFound, "Задача не найдена")
+			return
+		}
+		// Неудалённая задача может выполняться, поэтому условия проверяются по её копии
+		snap := proc.Snapshot(task)
+		switch {
+		case !checkIfMatch(w, r, snap):
+			return
+		case !snap.Deleted():
+			errorResponse(w, http.StatusConflict, "Задача не удалена")
+			return
+		case proc.Now().Sub(*snap.DeletedAt) > deleteGrace:
+			errorResponse(w, http.StatusGone, "Срок восстановления задачи истёк")
+			return
+		}
+		proc.RecordEvent(task, model.Event{Type: model.EventRestored, Actor: principal(r).Name})
+
+		task = proc.Snapshot(task)
+		setTaskETag(w, task)
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(newTaskResponse(task)); err != nil {
+			errorResponse(w, http.StatusInternalServerError, "Ошибка кодирования ответа")
+		}
+	}
+}
+
+// cancelTaskHandler отменяет ожидающую или выполняющуюся задачу, не удаляя её.
+// Ответ возвращается после остановки задачи; If-Match ограничивает отмену известной клиенту версией.
+func cancelTaskHandler(proc *service.Processor, store storage.TaskStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(mux.Vars(r)["id"])
+		if err != nil {
+			errorResponse(w, http.StatusBadRequest, "Неверный UUID")
+			return
+		}
+		defer lockTask(id)()
+		task, ok := tenantStore(r, proc, store).Get(id)
+		switch {
+		case !ok:
+			errorResponse(w, http.StatusNotFound, "Задача не найдена")
+			return
+		case !checkIfMatch(w, r, proc.Snapshot(task)):
+			return
+		case !proc.Active(id):
+			errorResponse(w, http.StatusConflict, "Задача не ожидает в очереди и не выполняется")
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), cancelTimeout)
+		defer cancel()
+		if err := proc.Cancel(ctx, id, principal(r).Name); err != nil {
+			errorResponse(w, http.StatusConflict, "Задача не остановилась вовремя, проверьте её статус позже")
+			return
+		}
+
+		task = proc.Snapshot(task)
+		setTaskETag(w, task)
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(newTaskResponse(task)); err != nil {
+			errorResponse(w, http.StatusInternalServerError, "Ошибка кодирования ответа")
+		}
+	}
+}
+
+// patchTaskHandler изменяет параметры задачи, обработка которой ещё не начата (Scheduled или Pending),
+// по JSON Merge Patch: priority, labels, run_at, timeout и payload. Значения проверяются так же,
+// как при создании. If-Match ограничивает изменение известной клиенту версией.
+func patchTaskHandler(proc *service.Processor, store storage.TaskStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(mux.Vars(r)["id"])
+		if err != nil {
+			errorResponse(w, http.StatusBadRequest, "Неверный UUID")
+			return
+		}
+		if ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); ct != "" && ct != "application/merge-patch+json" && ct != "application/json" {
+			errorResponse(w, http.StatusUnsupportedMediaType, "Ожидался Content-Type application/merge-patch+json")
+			return
+		}
+		patch, err := io.ReadAll(io.LimitReader(r.Body, model.MaxPayloadSize+4096))
+		if err != nil {
+			errorResponse(w, http.StatusBadRequest, "Ошибка чтения тела запроса")
+			return
+		}
+
+		defer lockTask(id)()
+		task, ok := tenantStore(r, proc, store).Get(id)
+		if !ok {
+			errorResponse(w, http.StatusNotFound, "Задача не найдена")
+			return
+		}
+		// Задача может выполняться, поэтому условия проверяются по её копии; на этой же копии
+		// проверяется патч, чтобы ошибка не затронула задачу
+		preview := proc.Snapshot(task)
+		switch {
+		case !checkIfMatch(w, r, preview):
+			return
+		case !preview.Status.Editable():
+			errorResponse(w, http.StatusConflict, "Изменять можно только задачи, обработка которых ещё не начата")
+			return
+		}
+		if err := preview.ApplyMergePatch(patch); err != nil {
+			errorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err := proc.ValidateTask(preview); err != nil {
+			errorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		// В историю патч записывается без лишних пробелов; после разбора он заведомо корректен
+		var compact bytes.Buffer
+		json.Compact(&compact, patch)
+		if err := proc.Update(task, principal(r).Name, compact.Bytes()); err != nil {
+			if errors.Is(err, service.ErrNotEditable) {
+				errorResponse(w, http.StatusConflict, "Изменять можно только задачи, обработка которых ещё не начата")
+			} else {
+				setQueueHeaders(w, proc)
+				queueErrorResponse(w, err)
+			}
+			return
+		}
+
+		task = proc.Snapshot(task)
+		setTaskETag(w, task)
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(newTaskResponse(task)); err != nil {
+			errorResponse(w, http.StatusInternalServerError, "Ошибка кодирования ответа")
+		}
+	}
+}
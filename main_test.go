@@ -1,39 +1,83 @@
 package main
 
 import (
+	"bufio"
+	"context"
 	"encoding/json"
+	"errors"
 	"github.com/google/uuid"
-	"github.com/gorilla/mux"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+	"workmateTestProject/internal/archive"
+	"workmateTestProject/internal/audit"
+	"workmateTestProject/internal/auth"
+	"workmateTestProject/internal/bench"
+	"workmateTestProject/internal/blob"
+	"workmateTestProject/internal/clock"
 	"workmateTestProject/internal/model"
+	"workmateTestProject/internal/ratelimit"
+	"workmateTestProject/internal/retention"
 	"workmateTestProject/internal/service"
 	"workmateTestProject/internal/storage"
 )
 
-// setupRouter создаёт новый роутер с зарегистрированными хендлерами,
-// используется для тестирования HTTP API без запуска реального сервера.
-func setupRouter() http.Handler {
-	store := storage.NewInMemoryTaskStore()
-	// Подменяем симулятор на мгновенный для ускорения тестов
-	service.SimulateWorkFunc = func() (string, error) {
-		return "fast-result", nil
+// newTestProcessor создаёт для теста собственный обработчик задач с поддельными часами, чтобы тесты
+// не делили состояние и выполнялись параллельно. Задачи выполняет work, без него - мгновенная заглушка.
+func newTestProcessor(t *testing.T, work service.WorkFunc) (*service.Processor, *clock.Fake) {
+	t.Helper()
+	if work == nil {
+		work = func(ctx context.Context) (string, error) { return "fast-result", nil }
 	}
+	clk := clock.NewFake(time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC))
+	proc := service.NewProcessor(service.Options{Clock: clk, Simulate: work})
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		proc.Shutdown(ctx)
+	})
+	return proc, clk
+}
+
+// setupRouter создаёт роутер с собственными хранилищем и обработчиком задач,
+// используется для тестирования HTTP API без запуска реального сервера.
+func setupRouter(t *testing.T, work service.WorkFunc) (http.Handler, *service.Processor) {
+	t.Helper()
+	proc, _ := newTestProcessor(t, work)
+	// Аутентификация отключена, логирование не требуется в тестах, возвращаем роутер напрямую
+	return newRouter(apiDeps{store: storage.NewInMemoryTaskStore(), proc: proc}), proc
+}
 
-	r := mux.NewRouter()
-	r.HandleFunc("/tasks", createTaskHandler(store)).Methods(http.MethodPost)
-	r.HandleFunc("/tasks", listTasksHandler(store)).Methods(http.MethodGet)
-	r.HandleFunc("/tasks/{id}", getTaskHandler(store)).Methods(http.MethodGet)
-	r.HandleFunc("/tasks/{id}", deleteTaskHandler(store)).Methods(http.MethodDelete)
+// busyWork выполняется до отмены и всё это время записывает события progress, изменяя задачу
+func busyWork(ctx context.Context) (string, error) {
+	for ctx.Err() == nil {
+		service.Progress(ctx, "шаг")
+		time.Sleep(time.Millisecond)
+	}
+	return "", ctx.Err()
+}
 
-	// Логирование не требуется в тестах, возвращаем роутер напрямую
-	return r
+// waitTask ждёт, пока задача не покинет обработчик
+func waitTask(t *testing.T, proc *service.Processor, id uuid.UUID) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := proc.Wait(ctx, id); err != nil {
+		t.Fatalf("задача %s не завершилась: %v", id, err)
+	}
 }
 
 // TestCreateAndGetAndDelete проверяет сценарий создания, получения и удаления задачи через HTTP API.
 func TestCreateAndGetAndDelete(t *testing.T) {
-	h := setupRouter()
+	t.Parallel()
+	h, proc := setupRouter(t, nil)
 
 	// 1. Создаём задачу через POST /tasks
 	rec := httptest.NewRecorder()
@@ -58,17 +102,18 @@ func TestCreateAndGetAndDelete(t *testing.T) {
 		t.Errorf("ожидался статус Pending, получили %s", created.Status)
 	}
 
-	// 2. Сразу запрашиваем GET /tasks/{id}, ожидаем Pending (обработка мгновенная)
-	rec = httptest.NewRecorder()
+	// 2. Дожидаемся завершения (мгновенной) обработки и запрашиваем GET /tasks/{id}
+	waitTask(t, proc, created.ID)
 	getReq := httptest.NewRequest(http.MethodGet, "/tasks/"+created.ID.String(), nil)
-	h.ServeHTTP(rec, getReq)
-	if rec.Code != http.StatusOK {
-		t.Fatalf("ожидался код 200 OK, получили %d", rec.Code)
-	}
 	var fetched struct {
 		ID     uuid.UUID        `json:"id"`
 		Status model.TaskStatus `json:"status"`
 	}
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, getReq)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("ожидался код 200 OK, получили %d", rec.Code)
+	}
 	if err := json.NewDecoder(rec.Body).Decode(&fetched); err != nil {
 		t.Fatalf("ошибка декодирования GET response: %v", err)
 	}
@@ -91,3 +136,1089 @@ func TestCreateAndGetAndDelete(t *testing.T) {
 		t.Errorf("ожидался код 404 Not Found после удаления, получили %d", rec.Code)
 	}
 }
+
+// TestAuth_Scopes проверяет, что при включённой аутентификации запросы без ключа получают 401,
+// а запросы с ключом без нужного права - 403.
+func TestAuth_Scopes(t *testing.T) {
+	t.Parallel()
+	proc, _ := newTestProcessor(t, nil)
+	keys := auth.NewKeyStore()
+	keys.AddStatic("reader", auth.DefaultTenant, "read-key", []auth.Scope{auth.ScopeTasksRead})
+	h := newRouter(apiDeps{store: storage.NewInMemoryTaskStore(), proc: proc, keys: keys})
+
+	cases := []struct {
+		method, key string
+		want        int
+	}{
+		{http.MethodGet, "", http.StatusUnauthorized},
+		{http.MethodGet, "wrong-key", http.StatusUnauthorized},
+		{http.MethodGet, "read-key", http.StatusOK},
+		{http.MethodPost, "read-key", http.StatusForbidden},
+	}
+	for _, c := range cases {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(c.method, "/tasks", nil)
+		if c.key != "" {
+			req.Header.Set("Authorization", "Bearer "+c.key)
+		}
+		h.ServeHTTP(rec, req)
+		if rec.Code != c.want {
+			t.Errorf("%s /tasks с ключом %q: ожидался код %d, получили %d", c.method, c.key, c.want, rec.Code)
+		}
+	}
+}
+
+// TestTenantIsolation проверяет, что арендаторы не видят задачи друг друга через HTTP API.
+func TestTenantIsolation(t *testing.T) {
+	t.Parallel()
+	proc, _ := newTestProcessor(t, nil)
+	keys := auth.NewKeyStore()
+	keys.AddStatic("a", "team-a", "key-a", []auth.Scope{auth.ScopeTasksWrite, auth.ScopeTasksRead})
+	keys.AddStatic("b", "team-b", "key-b", []auth.Scope{auth.ScopeTasksWrite, auth.ScopeTasksRead})
+	h := newRouter(apiDeps{store: storage.NewInMemoryTaskStore(), proc: proc, keys: keys})
+
+	do := func(method, path, key string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+key)
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do(http.MethodPost, "/tasks", "key-a")
+	var created model.Task
+	if err := json.NewDecoder(rec.Body).Decode(&created); err != nil {
+		t.Fatalf("не удалось распарсить JSON: %v", err)
+	}
+	if created.Tenant != "team-a" || created.CreatedBy != "a" {
+		t.Errorf("ожидались арендатор team-a и автор a, получили %q и %q", created.Tenant, created.CreatedBy)
+	}
+
+	if rec := do(http.MethodGet, "/tasks/"+created.ID.String(), "key-b"); rec.Code != http.StatusNotFound {
+		t.Errorf("team-b получил чужую задачу, код %d", rec.Code)
+	}
+	var list []model.Task
+	if err := json.NewDecoder(do(http.MethodGet, "/tasks", "key-b").Body).Decode(&list); err != nil {
+		t.Fatalf("не удалось распарсить список: %v", err)
+	}
+	if len(list) != 0 {
+		t.Errorf("team-b видит %d чужих задач", len(list))
+	}
+}
+
+// TestRateLimit_Write проверяет, что при исчерпании лимита записи клиент получает 429
+// с заголовками RateLimit-* и Retry-After, а лимит чтения при этом не затронут.
+func TestRateLimit_Write(t *testing.T) {
+	t.Parallel()
+	proc, _ := newTestProcessor(t, nil)
+	limiter := &rateLimiter{
+		backend: ratelimit.NewMemoryBackend(),
+		read:    ratelimit.Limit{Rate: 10, Burst: 10},
+		write:   ratelimit.Limit{Rate: 0.1, Burst: 1},
+		keyBy:   "ip",
+	}
+	auditLog, _ := audit.Open("")
+	h := newRouter(apiDeps{store: storage.NewInMemoryTaskStore(), proc: proc, limiter: limiter, audit: auditLog})
+
+	do := func(method string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(method, "/tasks", nil))
+		return rec
+	}
+	if rec := do(http.MethodPost); rec.Code != http.StatusCreated || rec.Header().Get("RateLimit-Remaining") != "0" {
+		t.Fatalf("первый POST: код %d, RateLimit-Remaining=%q", rec.Code, rec.Header().Get("RateLimit-Remaining"))
+	}
+	rec := do(http.MethodPost)
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("ожидался 429, получили %d", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") != "10" || rec.Header().Get("RateLimit-Limit") != "1" {
+		t.Errorf("неверные заголовки: Retry-After=%q RateLimit-Limit=%q",
+			rec.Header().Get("Retry-After"), rec.Header().Get("RateLimit-Limit"))
+	}
+	if rec := do(http.MethodGet); rec.Code != http.StatusOK {
+		t.Errorf("лимит записи не должен влиять на чтение, получили %d", rec.Code)
+	}
+	// Отклонённый по лимиту запрос попадает в журнал аудита
+	entries, err := auditLog.Query(audit.Query{Tenants: []string{auth.DefaultTenant}})
+	if err != nil || len(entries) != 2 || entries[1].Status != http.StatusTooManyRequests || entries[1].Outcome != audit.OutcomeDenied {
+		t.Errorf("журнал аудита: %+v, %v", entries, err)
+	}
+}
+
+// TestRateLimit_Unauthenticated проверяет, что запросы с неверным ключом расходуют лимит по IP
+// и после его исчерпания получают 429 вместо 401, а лимит субъекта считается отдельно от IP.
+func TestRateLimit_Unauthenticated(t *testing.T) {
+	t.Parallel()
+	proc, _ := newTestProcessor(t, nil)
+	keys := auth.NewKeyStore()
+	keys.AddStatic("reader", auth.DefaultTenant, "read-key", []auth.Scope{auth.ScopeTasksRead})
+	limiter := &rateLimiter{
+		backend: ratelimit.NewMemoryBackend(),
+		read:    ratelimit.Limit{Rate: 0.1, Burst: 2},
+		keyBy:   "principal",
+	}
+	h := newRouter(apiDeps{store: storage.NewInMemoryTaskStore(), proc: proc, keys: keys, limiter: limiter})
+
+	do := func(key, ip string) int {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/tasks", nil)
+		req.RemoteAddr = ip + ":1234"
+		req.Header.Set("Authorization", "Bearer "+key)
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	for i, want := range []int{http.StatusUnauthorized, http.StatusUnauthorized, http.StatusTooManyRequests} {
+		if got := do("wrong-key", "192.0.2.1"); got != want {
+			t.Fatalf("запрос %d с неверным ключом: ожидался %d, получили %d", i+1, want, got)
+		}
+	}
+	if got := do("read-key", "192.0.2.1"); got != http.StatusTooManyRequests {
+		t.Errorf("лимит IP должен действовать и на аутентифицированные запросы, получили %d", got)
+	}
+	// С других адресов субъект расходует свою корзину: отклонённый по IP запрос её не затронул
+	for i, want := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		if got := do("read-key", "192.0.2."+strconv.Itoa(i+2)); got != want {
+			t.Errorf("запрос субъекта %d: ожидался %d, получили %d", i+1, want, got)
+		}
+	}
+}
+
+// TestRateLimit_ClientIP проверяет выбор IP клиента из X-Forwarded-For: учитываются только записи,
+// дописанные доверенными прокси, а подставленные клиентом в начало заголовка - нет
+func TestRateLimit_ClientIP(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		hops int
+		fwd  []string
+		want string
+	}{
+		{0, []string{"203.0.113.7"}, "192.0.2.1"},
+		{1, nil, "192.0.2.1"},
+		{1, []string{"203.0.113.7"}, "203.0.113.7"},
+		{1, []string{"1.1.1.1, 203.0.113.7"}, "203.0.113.7"},
+		{1, []string{"1.1.1.1", "203.0.113.7"}, "203.0.113.7"},
+		{2, []string{"1.1.1.1, 203.0.113.7, 10.0.0.2"}, "203.0.113.7"},
+		{2, []string{"203.0.113.7"}, "203.0.113.7"},
+		{1, []string{"1.1.1.1, не-адрес"}, "192.0.2.1"},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest(http.MethodGet, "/tasks", nil)
+		r.RemoteAddr = "192.0.2.1:1234"
+		for _, v := range c.fwd {
+			r.Header.Add("X-Forwarded-For", v)
+		}
+		if got := (&rateLimiter{proxyHops: c.hops}).clientIP(r); got != c.want {
+			t.Errorf("прокси %d, X-Forwarded-For %q: ожидался %s, получили %s", c.hops, c.fwd, c.want, got)
+		}
+	}
+}
+
+// TestCreateTask_Backpressure проверяет валидацию полей задачи, заголовки загрузки очереди
+// и ответ 503 с Retry-After при переполненной очереди.
+func TestCreateTask_Backpressure(t *testing.T) {
+	t.Parallel()
+	release := make(chan struct{})
+	defer close(release)
+	h, proc := setupRouter(t, func(ctx context.Context) (string, error) {
+		<-release
+		return "ok", nil
+	})
+	// Один слот у арендатора и одно место в общей очереди
+	proc.SetTenantLimits(service.TenantLimits{MaxConcurrent: 1}, nil)
+	proc.SetAdmissionPolicy(service.AdmissionPolicy{MaxQueued: 1, Shed: service.ShedReject})
+
+	post := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/tasks", strings.NewReader(body)))
+		return rec
+	}
+	if rec := post(`{"priority": 1000}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("ожидался 400 для приоритета вне диапазона, получили %d", rec.Code)
+	}
+	for i := 0; i < 2; i++ {
+		if rec := post(`{"type": "report", "priority": 5}`); rec.Code != http.StatusCreated {
+			t.Fatalf("задача %d: ожидался 201, получили %d", i+1, rec.Code)
+		}
+	}
+	rec := post(`{"type": "report"}`)
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("ожидался 503 с Retry-After, получили %d, Retry-After=%q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	if rec.Header().Get("X-Queue-Depth") != "1" || rec.Header().Get("X-Queue-Pressure") != "1.00" {
+		t.Errorf("неверные заголовки загрузки: depth=%q pressure=%q",
+			rec.Header().Get("X-Queue-Depth"), rec.Header().Get("X-Queue-Pressure"))
+	}
+}
+
+// TestAdminPool проверяет изменение размера пула и паузу через административные эндпоинты
+// с сохранением настроек в файл.
+func TestAdminPool(t *testing.T) {
+	t.Parallel()
+	proc, _ := newTestProcessor(t, nil)
+	path := filepath.Join(t.TempDir(), "pool.json")
+	h := newRouter(apiDeps{store: storage.NewInMemoryTaskStore(), proc: proc, poolSettingsFile: path})
+
+	do := func(method, url, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(method, url, strings.NewReader(body)))
+		return rec
+	}
+	if rec := do(http.MethodPut, "/admin/pool/concurrency", `{"max_concurrent": 0}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("ожидался 400 для нулевого размера пула, получили %d", rec.Code)
+	}
+	if rec := do(http.MethodPut, "/admin/pool/concurrency", `{"max_concurrent": 4}`); rec.Code != http.StatusOK {
+		t.Fatalf("ожидался 200, получили %d", rec.Code)
+	}
+	rec := do(http.MethodPost, "/admin/pool/pause", `{"type": "report"}`)
+	var pool struct {
+		MaxConcurrent int      `json:"max_concurrent"`
+		PausedTypes   []string `json:"paused_types"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&pool); err != nil {
+		t.Fatalf("не удалось распарсить ответ: %v", err)
+	}
+	if pool.MaxConcurrent != 4 || len(pool.PausedTypes) != 1 {
+		t.Errorf("неверное состояние пула: %+v", pool)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("настройки пула не сохранены: %v", err)
+	}
+}
+
+// TestArtifacts проверяет выгрузку артефакта задачи целиком и по диапазону,
+// заголовки с контрольной суммой и удаление артефактов при окончательном удалении задачи.
+func TestArtifacts(t *testing.T) {
+	t.Parallel()
+	h, proc := setupRouter(t, func(ctx context.Context) (string, error) {
+		return "ok", service.PutArtifact(ctx, "data.txt", "text/plain", strings.NewReader("hello, artifacts"))
+	})
+	blobs, err := blob.NewFSStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFSStore: %v", err)
+	}
+	proc.SetArtifactStore(blobs)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/tasks", nil))
+	var created model.Task
+	if err := json.NewDecoder(rec.Body).Decode(&created); err != nil {
+		t.Fatalf("не удалось распарсить JSON: %v", err)
+	}
+	waitTask(t, proc, created.ID)
+	url := "/tasks/" + created.ID.String() + "/artifacts/data.txt"
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, url, nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "hello, artifacts" {
+		t.Fatalf("ожидался 200 с содержимым артефакта, получили %d %q", rec.Code, rec.Body.String())
+	}
+	if rec.Header().Get("ETag") == "" || !strings.HasPrefix(rec.Header().Get("Repr-Digest"), "sha-256=:") {
+		t.Errorf("нет заголовков контрольной суммы: ETag=%q Repr-Digest=%q", rec.Header().Get("ETag"), rec.Header().Get("Repr-Digest"))
+	}
+
+	req := httptest.NewRequest(http.MethodGet, url, nil)
+	req.Header.Set("Range", "bytes=7-15")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusPartialContent || rec.Body.String() != "artifacts" {
+		t.Errorf("ожидался 206 с фрагментом, получили %d %q", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/tasks/"+created.ID.String()+"/artifacts/missing.txt", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("ожидался 404 для неизвестного артефакта, получили %d", rec.Code)
+	}
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodDelete, "/tasks/"+created.ID.String()+"?purge=true", nil))
+	obj, err := blobs.Open(context.Background(), created.ID.String()+"/data.txt")
+	if err == nil {
+		data, _ := io.ReadAll(obj)
+		obj.Close()
+		t.Errorf("артефакт не удалён вместе с задачей: %q", data)
+	}
+}
+
+// TestArtifacts_RunningTask проверяет, что артефакт можно запрашивать, пока обработчик задачи
+// добавляет новые: список артефактов читается согласованно с событиями (проверяется с -race).
+func TestArtifacts_RunningTask(t *testing.T) {
+	t.Parallel()
+	h, proc := setupRouter(t, func(ctx context.Context) (string, error) {
+		for i := 0; ctx.Err() == nil; i++ {
+			if err := service.PutArtifact(ctx, "part-"+strconv.Itoa(i%8)+".txt", "text/plain", strings.NewReader("x")); err != nil {
+				return "", err
+			}
+			time.Sleep(time.Millisecond)
+		}
+		return "", ctx.Err()
+	})
+	blobs, err := blob.NewFSStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFSStore: %v", err)
+	}
+	proc.SetArtifactStore(blobs)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/tasks", nil))
+	var created model.Task
+	if err := json.NewDecoder(rec.Body).Decode(&created); err != nil {
+		t.Fatalf("не удалось распарсить JSON: %v", err)
+	}
+	url := "/tasks/" + created.ID.String() + "/artifacts/part-0.txt"
+	for range 50 {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, url, nil))
+		if rec.Code != http.StatusOK && rec.Code != http.StatusNotFound {
+			t.Fatalf("ожидался 200 или 404, получили %d", rec.Code)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodDelete, "/tasks/"+created.ID.String(), nil))
+	waitTask(t, proc, created.ID)
+}
+
+// TestTaskLogs проверяет потоковое чтение журнала задачи с follow=true и выборку tail после завершения.
+func TestTaskLogs(t *testing.T) {
+	t.Parallel()
+	release := make(chan struct{})
+	h, _ := setupRouter(t, func(ctx context.Context) (string, error) {
+		service.Logf(ctx, "шаг %d", 1)
+		<-release
+		service.Logf(ctx, "шаг %d", 2)
+		return "ok", nil
+	})
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/tasks", "application/json", nil)
+	if err != nil {
+		t.Fatalf("POST /tasks: %v", err)
+	}
+	var created model.Task
+	json.NewDecoder(resp.Body).Decode(&created)
+	resp.Body.Close()
+
+	resp, err = http.Get(srv.URL + "/tasks/" + created.ID.String() + "/logs?follow=true")
+	if err != nil {
+		t.Fatalf("GET logs: %v", err)
+	}
+	defer resp.Body.Close()
+	sc := bufio.NewScanner(resp.Body)
+	var messages []string
+	for sc.Scan() {
+		var line struct {
+			Message string `json:"message"`
+		}
+		json.Unmarshal(sc.Bytes(), &line)
+		messages = append(messages, line.Message)
+		// Отпускаем задачу, когда клиент увидел первую строку от обработчика
+		if line.Message == "шаг 1" {
+			close(release)
+		}
+	}
+	want := []string{"Обработка начата", "шаг 1", "шаг 2", "Обработка завершена со статусом Completed"}
+	if strings.Join(messages, "|") != strings.Join(want, "|") {
+		t.Fatalf("ожидались строки %q, получили %q", want, messages)
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/tasks/"+created.ID.String()+"/logs?tail=1", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "Completed") || strings.Count(rec.Body.String(), "\n") != 1 {
+		t.Errorf("tail=1: ожидалась одна итоговая строка, получили %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+// TestArchive_SearchAndRestore проверяет перенос устаревшей задачи в архив сборщиком,
+// поиск в архиве и восстановление задачи в хранилище.
+func TestArchive_SearchAndRestore(t *testing.T) {
+	t.Parallel()
+	proc, clk := newTestProcessor(t, nil)
+	store := storage.NewInMemoryTaskStore()
+	arch, err := archive.New(t.TempDir())
+	if err != nil {
+		t.Fatalf("archive.New: %v", err)
+	}
+	policy, _ := retention.ParsePolicy("Completed=1h")
+	janitor := retention.NewJanitor(store, policy, 0)
+	janitor.Clock = clk
+	janitor.OnPurge = archiveTaskData(proc, arch)
+	h := newRouter(apiDeps{store: store, proc: proc, archive: arch, janitor: janitor})
+
+	finished := clk.Now().Add(-2 * time.Hour)
+	task := &model.Task{ID: uuid.New(), Tenant: auth.DefaultTenant, Status: model.StatusCompleted, CreatedAt: finished, FinishedAt: &finished}
+	store.Create(task)
+	if n, err := janitor.Sweep(context.Background()); err != nil || n != 1 {
+		t.Fatalf("ожидался перенос одной задачи в архив, получили %d (%v)", n, err)
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/archive/tasks?id="+task.ID.String(), nil))
+	var found []archive.Record
+	if err := json.NewDecoder(rec.Body).Decode(&found); err != nil || len(found) != 1 {
+		t.Fatalf("ожидалась одна запись архива, получили %d %q", rec.Code, rec.Body.String())
+	}
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/archive/tasks?from=bad", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("ожидался 400 для неверного from, получили %d", rec.Code)
+	}
+
+	// Из одновременных восстановлений успешно только одно, остальные получают 409
+	restore := httptest.NewRequest(http.MethodPost, "/archive/tasks/"+task.ID.String()+"/restore", nil)
+	codes := make([]int, 8)
+	var wg sync.WaitGroup
+	for i := range codes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, restore.Clone(context.Background()))
+			codes[i] = rec.Code
+		}()
+	}
+	wg.Wait()
+	created := 0
+	for _, code := range codes {
+		if code == http.StatusCreated {
+			created++
+		}
+	}
+	if created != 1 {
+		t.Fatalf("ожидался ровно один 201 при одновременном восстановлении, получили %v", codes)
+	}
+	if _, ok := store.Get(task.ID); !ok {
+		t.Errorf("задача не восстановлена в хранилище")
+	}
+	if n, _ := janitor.Sweep(context.Background()); n != 0 {
+		t.Errorf("восстановленная задача не должна сразу снова уходить в архив")
+	}
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, restore)
+	if rec.Code != http.StatusConflict {
+		t.Errorf("ожидался 409 при повторном восстановлении, получили %d", rec.Code)
+	}
+}
+
+// TestExportImport проверяет перенос задач между экземплярами через CSV
+// и режимы обработки конфликтов при импорте.
+func TestExportImport(t *testing.T) {
+	t.Parallel()
+	proc, clk := newTestProcessor(t, nil)
+	src := storage.NewInMemoryTaskStore()
+	now := clk.Now()
+	done := &model.Task{ID: uuid.New(), Tenant: auth.DefaultTenant, Type: "report", Status: model.StatusCompleted, CreatedAt: now, FinishedAt: &now}
+	running := &model.Task{ID: uuid.New(), Tenant: auth.DefaultTenant, Status: model.StatusInProgress, CreatedAt: now, StartedAt: &now}
+	src.Create(done)
+	src.Create(running)
+	src.Create(&model.Task{ID: uuid.New(), Tenant: "other", Status: model.StatusCompleted, CreatedAt: now})
+
+	rec := httptest.NewRecorder()
+	newRouter(apiDeps{store: src, proc: proc}).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/tasks:export?format=csv", nil))
+	if rec.Code != http.StatusOK || strings.Count(rec.Body.String(), "\n") != 3 {
+		t.Fatalf("ожидались заголовок и 2 задачи арендатора, получили %d %q", rec.Code, rec.Body.String())
+	}
+	export := rec.Body.String()
+
+	dst := storage.NewInMemoryTaskStore()
+	h := newRouter(apiDeps{store: dst, proc: proc})
+	doImport := func(query string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/tasks:import"+query, strings.NewReader(export))
+		req.Header.Set("Content-Type", "text/csv")
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+	var res importResult
+	rec = doImport("")
+	if err := json.NewDecoder(rec.Body).Decode(&res); err != nil || res.Imported != 2 {
+		t.Fatalf("ожидался импорт 2 задач, получили %d %+v", rec.Code, res)
+	}
+	if task, _ := dst.Get(running.ID); task.Status != model.StatusInterrupted {
+		t.Errorf("незавершённая задача без requeue должна стать Interrupted, получили %s", task.Status)
+	}
+
+	if rec := doImport(""); rec.Code != http.StatusConflict {
+		t.Errorf("ожидался 409 при повторном импорте в режиме fail, получили %d", rec.Code)
+	}
+	rec = doImport("?conflict=skip")
+	res = importResult{}
+	json.NewDecoder(rec.Body).Decode(&res)
+	if res.Skipped != 2 || res.Imported != 0 {
+		t.Errorf("режим skip: %+v", res)
+	}
+	rec = doImport("?conflict=overwrite&requeue=true")
+	res = importResult{}
+	json.NewDecoder(rec.Body).Decode(&res)
+	if res.Overwritten != 2 || res.Requeued != 1 {
+		t.Errorf("режим overwrite: %+v", res)
+	}
+	waitTask(t, proc, running.ID)
+	if _, ok := proc.TaskLogs(running.ID); !ok {
+		t.Fatal("у обработанной задачи должен быть журнал")
+	}
+	// Замена удаляет журнал прежней задачи, как окончательное удаление
+	doImport("?conflict=overwrite")
+	if _, ok := proc.TaskLogs(running.ID); ok {
+		t.Errorf("журнал заменённой задачи должен быть удалён")
+	}
+
+	// ID задач другого арендатора не раскрываются в ответе 409
+	foreign := storage.NewInMemoryTaskStore()
+	foreign.Create(&model.Task{ID: done.ID, Tenant: "other", Status: model.StatusCompleted, CreatedAt: now})
+	h = newRouter(apiDeps{store: foreign, proc: proc})
+	rec = doImport("")
+	res = importResult{}
+	json.NewDecoder(rec.Body).Decode(&res)
+	if rec.Code != http.StatusOK || res.Imported != 1 || len(res.Errors) != 1 || res.Errors[0].ID != done.ID ||
+		res.Errors[0].Error != errImportFailed.Error() {
+		t.Errorf("конфликт с задачей другого арендатора должен быть общей ошибкой задачи, получили %d %+v", rec.Code, res)
+	}
+	// В режиме skip чужая задача тоже не считается пропущенной
+	rec = doImport("?conflict=skip")
+	res = importResult{}
+	json.NewDecoder(rec.Body).Decode(&res)
+	if res.Skipped != 1 || len(res.Errors) != 1 || res.Errors[0].Error != errImportFailed.Error() {
+		t.Errorf("режим skip с задачей другого арендатора: %+v", res)
+	}
+	if task, _ := foreign.Get(done.ID); task.Tenant != "other" {
+		t.Errorf("задача другого арендатора не должна заменяться")
+	}
+
+	// Задачу, которая ставится в очередь, проверяет обработчик: неверный профиль симуляции
+	// отклоняет загрузку целиком, а без requeue задача загружается как прерванная
+	bad := `{"id":"` + uuid.NewString() + `","status":"Pending","payload":{"simulation":"x"}}` + "\n"
+	h = newRouter(apiDeps{store: storage.NewInMemoryTaskStore(), proc: proc})
+	for query, want := range map[string]int{"?requeue=true": http.StatusBadRequest, "": http.StatusOK} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/tasks:import"+query, strings.NewReader(bad)))
+		if rec.Code != want {
+			t.Errorf("импорт%s задачи с неверным payload: ожидался %d, получили %d %s", query, want, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+// TestAudit проверяет запись изменяющих запросов в журнал аудита, включая отклонённые,
+// и выборку записей через GET /audit.
+func TestAudit(t *testing.T) {
+	t.Parallel()
+	proc, _ := newTestProcessor(t, nil)
+	keys := auth.NewKeyStore()
+	keys.AddStatic("admin", auth.DefaultTenant, "admin-key", []auth.Scope{auth.ScopeTasksAdmin})
+	keys.AddStatic("reader", auth.DefaultTenant, "reader-key", []auth.Scope{auth.ScopeTasksRead})
+	auditLog, _ := audit.Open("")
+	h := newRouter(apiDeps{store: storage.NewInMemoryTaskStore(), proc: proc, keys: keys, audit: auditLog})
+
+	do := func(method, url, key string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(method, url, nil)
+		if key != "" {
+			req.Header.Set("Authorization", "Bearer "+key)
+		}
+		req.Header.Set("X-Request-ID", "req-"+method)
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+	rec := do(http.MethodPost, "/tasks", "admin-key")
+	var created model.Task
+	json.NewDecoder(rec.Body).Decode(&created)
+	// Запрос без ключа записывается как отклонённый без субъекта
+	if rec := do(http.MethodDelete, "/tasks/"+created.ID.String(), ""); rec.Header().Get("X-Request-ID") == "" {
+		t.Errorf("ответ без X-Request-ID")
+	}
+	do(http.MethodDelete, "/tasks/"+created.ID.String(), "reader-key")
+	do(http.MethodDelete, "/tasks/"+created.ID.String(), "admin-key")
+
+	rec = do(http.MethodGet, "/audit?target="+created.ID.String(), "admin-key")
+	var entries []audit.Entry
+	if err := json.NewDecoder(rec.Body).Decode(&entries); err != nil {
+		t.Fatalf("не удалось распарсить ответ: %v", err)
+	}
+	want := []struct{ action, principal, outcome string }{
+		{"POST /tasks", "admin", audit.OutcomeSuccess},
+		{"DELETE /tasks/{id}", "", audit.OutcomeDenied},
+		{"DELETE /tasks/{id}", "reader", audit.OutcomeDenied},
+		{"DELETE /tasks/{id}", "admin", audit.OutcomeSuccess},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("ожидалось %d записей, получили %+v", len(want), entries)
+	}
+	for i, w := range want {
+		e := entries[i]
+		if e.Action != w.action || e.Principal != w.principal || e.Outcome != w.outcome || e.RequestID == "" || e.SourceIP == "" {
+			t.Errorf("запись %d: ожидалось %+v, получили %+v", i, w, e)
+		}
+	}
+	if n, err := auditLog.Verify(); err != nil || n != 4 {
+		t.Errorf("Verify: %d, %v", n, err)
+	}
+}
+
+// TestDelete_ConcurrentReads проверяет, что список и чтение задачи согласованы с её удалением
+// и восстановлением в другой горутине: признак удаления читается через обработчик (проверяется с -race).
+func TestDelete_ConcurrentReads(t *testing.T) {
+	t.Parallel()
+	h, proc := setupRouter(t, nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/tasks", nil))
+	var created model.Task
+	if err := json.NewDecoder(rec.Body).Decode(&created); err != nil {
+		t.Fatalf("не удалось распарсить JSON: %v", err)
+	}
+	waitTask(t, proc, created.ID)
+	url := "/tasks/" + created.ID.String()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range 20 {
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodDelete, url, nil))
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, url+"/restore", nil))
+		}
+	}()
+	for i := 0; ; i++ {
+		select {
+		case <-done:
+			return
+		default:
+		}
+		rec := httptest.NewRecorder()
+		target := url
+		if i%2 == 0 {
+			target = "/tasks"
+		}
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code != http.StatusOK && rec.Code != http.StatusNotFound {
+			t.Fatalf("GET %s: ожидался 200 или 404, получили %d", target, rec.Code)
+		}
+	}
+}
+
+// TestDelete_CancelSoftDeleteRestore проверяет отмену выполняющейся задачи при удалении,
+// 404 для неизвестных задач, восстановление мягко удалённой задачи и окончательное удаление.
+func TestDelete_CancelSoftDeleteRestore(t *testing.T) {
+	t.Parallel()
+	started := make(chan struct{})
+	h, proc := setupRouter(t, func(ctx context.Context) (string, error) {
+		close(started)
+		<-ctx.Done()
+		return "", ctx.Err()
+	})
+	do := func(method, url string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(method, url, nil))
+		return rec
+	}
+
+	if rec := do(http.MethodDelete, "/tasks/"+uuid.NewString()); rec.Code != http.StatusNotFound {
+		t.Errorf("ожидался 404 для неизвестной задачи, получили %d", rec.Code)
+	}
+	var created model.Task
+	json.NewDecoder(do(http.MethodPost, "/tasks").Body).Decode(&created)
+	<-started
+	url := "/tasks/" + created.ID.String()
+
+	if rec := do(http.MethodDelete, url); rec.Code != http.StatusNoContent {
+		t.Fatalf("ожидался 204, получили %d", rec.Code)
+	}
+	if proc.Active(created.ID) {
+		t.Errorf("задача должна быть остановлена до ответа на DELETE")
+	}
+	if rec := do(http.MethodGet, url); rec.Code != http.StatusNotFound {
+		t.Errorf("мягко удалённая задача должна быть скрыта, получили %d", rec.Code)
+	}
+	if rec := do(http.MethodDelete, url); rec.Code != http.StatusNotFound {
+		t.Errorf("повторное мягкое удаление: ожидался 404, получили %d", rec.Code)
+	}
+
+	rec := do(http.MethodPost, url+"/restore")
+	var restored model.Task
+	json.NewDecoder(rec.Body).Decode(&restored)
+	if rec.Code != http.StatusOK || restored.Status != model.StatusCanceled || restored.DeletedAt != nil {
+		t.Fatalf("ожидалась восстановленная отменённая задача, получили %d %+v", rec.Code, restored)
+	}
+	if rec := do(http.MethodPost, url+"/restore"); rec.Code != http.StatusConflict {
+		t.Errorf("восстановление неудалённой задачи: ожидался 409, получили %d", rec.Code)
+	}
+
+	do(http.MethodDelete, url)
+	if rec := do(http.MethodDelete, url+"?purge=true"); rec.Code != http.StatusNoContent {
+		t.Errorf("окончательное удаление мягко удалённой задачи: ожидался 204, получили %d", rec.Code)
+	}
+	if rec := do(http.MethodPost, url+"/restore"); rec.Code != http.StatusNotFound {
+		t.Errorf("после purge задача не должна восстанавливаться, получили %d", rec.Code)
+	}
+}
+
+// TestTaskHistory проверяет запись событий жизненного цикла задачи, их выдачу через API
+// и совпадение свёртки истории с текущим состоянием задачи.
+func TestTaskHistory(t *testing.T) {
+	t.Parallel()
+	h, proc := setupRouter(t, func(ctx context.Context) (string, error) {
+		service.Progress(ctx, "шаг %d", 1)
+		return "готово", nil
+	})
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/tasks", strings.NewReader(`{"type":"report"}`)))
+	var created model.Task
+	json.NewDecoder(rec.Body).Decode(&created)
+	waitTask(t, proc, created.ID)
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/tasks/"+created.ID.String()+"/history", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("ожидался 200, получили %d", rec.Code)
+	}
+	var events []model.Event
+	if err := json.NewDecoder(rec.Body).Decode(&events); err != nil {
+		t.Fatalf("не удалось распарсить историю: %v", err)
+	}
+	want := []model.EventType{model.EventCreated, model.EventQueued, model.EventStarted, model.EventProgress, model.EventCompleted}
+	if len(events) != len(want) {
+		t.Fatalf("ожидались события %v, получили %+v", want, events)
+	}
+	for i, e := range events {
+		if e.Type != want[i] || e.Seq != int64(i+1) || e.Time.IsZero() || e.Actor == "" {
+			t.Errorf("событие %d: ожидался %s с номером, временем и инициатором, получили %+v", i+1, want[i], e)
+		}
+	}
+	if events[3].Message != "шаг 1" || events[4].Actor != model.ActorSystem {
+		t.Errorf("неверные данные событий: %+v", events)
+	}
+
+	folded, err := model.Fold(events)
+	if err != nil {
+		t.Fatalf("Fold: %v", err)
+	}
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/tasks/"+created.ID.String(), nil))
+	var current model.Task
+	json.NewDecoder(rec.Body).Decode(&current)
+	if folded.Status != current.Status || folded.Result != current.Result || folded.Type != current.Type ||
+		!folded.StartedAt.Equal(*current.StartedAt) || !folded.FinishedAt.Equal(*current.FinishedAt) {
+		t.Errorf("свёртка истории %+v не совпадает с задачей %+v", folded, current)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/tasks/"+uuid.NewString()+"/history", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("для неизвестной задачи ожидался 404, получили %d", rec.Code)
+	}
+}
+
+// TestConditionalRequests проверяет ETag с версией задачи, 304 по If-None-Match,
+// отказ 412 по устаревшему If-Match и отмену задачи без удаления.
+func TestConditionalRequests(t *testing.T) {
+	t.Parallel()
+	started := make(chan struct{})
+	h, proc := setupRouter(t, func(ctx context.Context) (string, error) {
+		close(started)
+		<-ctx.Done()
+		return "", ctx.Err()
+	})
+	do := func(method, url string, header map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, url, nil)
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	var created model.Task
+	json.NewDecoder(do(http.MethodPost, "/tasks", nil).Body).Decode(&created)
+	<-started
+	url := "/tasks/" + created.ID.String()
+
+	rec := do(http.MethodGet, url, nil)
+	etag := rec.Header().Get("ETag")
+	var fetched model.Task
+	json.NewDecoder(rec.Body).Decode(&fetched)
+	if etag != `"`+strconv.FormatInt(fetched.Version, 10)+`"` || fetched.Version == 0 {
+		t.Fatalf("ETag %q не соответствует версии %d", etag, fetched.Version)
+	}
+	if rec := do(http.MethodGet, url, map[string]string{"If-None-Match": etag}); rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+		t.Errorf("ожидался 304 без тела, получили %d", rec.Code)
+	}
+
+	stale := `"` + strconv.FormatInt(fetched.Version-1, 10) + `"`
+	if rec := do(http.MethodDelete, url, map[string]string{"If-Match": stale}); rec.Code != http.StatusPreconditionFailed || rec.Header().Get("ETag") != etag {
+		t.Errorf("удаление по устаревшей версии: ожидался 412 с текущим ETag, получили %d %q", rec.Code, rec.Header().Get("ETag"))
+	}
+	if rec := do(http.MethodPost, url+"/cancel", map[string]string{"If-Match": stale}); rec.Code != http.StatusPreconditionFailed {
+		t.Errorf("отмена по устаревшей версии: ожидался 412, получили %d", rec.Code)
+	}
+	if !proc.Active(created.ID) {
+		t.Fatalf("отклонённые запросы не должны останавливать задачу")
+	}
+
+	rec = do(http.MethodPost, url+"/cancel", map[string]string{"If-Match": etag})
+	var canceled model.Task
+	json.NewDecoder(rec.Body).Decode(&canceled)
+	if rec.Code != http.StatusOK || canceled.Status != model.StatusCanceled || canceled.Version <= fetched.Version {
+		t.Fatalf("ожидалась отменённая задача с новой версией, получили %d %+v", rec.Code, canceled)
+	}
+	if rec := do(http.MethodPost, url+"/cancel", nil); rec.Code != http.StatusConflict {
+		t.Errorf("повторная отмена: ожидался 409, получили %d", rec.Code)
+	}
+	if rec := do(http.MethodGet, url, map[string]string{"If-None-Match": etag}); rec.Code != http.StatusOK {
+		t.Errorf("после изменения ожидался 200, получили %d", rec.Code)
+	}
+
+	newETag := `"` + strconv.FormatInt(canceled.Version, 10) + `"`
+	rec = do(http.MethodDelete, url, map[string]string{"If-Match": newETag})
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("удаление по актуальной версии: ожидался 204, получили %d", rec.Code)
+	}
+	if rec := do(http.MethodPost, url+"/restore", map[string]string{"If-Match": newETag}); rec.Code != http.StatusPreconditionFailed {
+		t.Errorf("восстановление по версии до удаления: ожидался 412, получили %d", rec.Code)
+	}
+	if rec := do(http.MethodPost, url+"/restore", map[string]string{"If-Match": rec.Header().Get("ETag")}); rec.Code != http.StatusOK {
+		t.Errorf("восстановление по ETag из ответа DELETE: ожидался 200, получили %d", rec.Code)
+	}
+}
+
+// TestPatchTask проверяет изменение отложенной задачи, проверку значений,
+// запуск по снятию run_at и запрет изменения завершённой задачи.
+func TestPatchTask(t *testing.T) {
+	t.Parallel()
+	h, proc := setupRouter(t, nil)
+	do := func(method, url, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/merge-patch+json")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	runAt := proc.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	rec := do(http.MethodPost, "/tasks", `{"type":"report","run_at":"`+runAt+`","labels":{"team":"a"},"payload":{"n":1}}`)
+	var created model.Task
+	json.NewDecoder(rec.Body).Decode(&created)
+	if rec.Code != http.StatusCreated || created.Status != model.StatusScheduled {
+		t.Fatalf("ожидалась отложенная задача, получили %d %+v", rec.Code, created)
+	}
+	url := "/tasks/" + created.ID.String()
+
+	rec = do(http.MethodPatch, url, `{"priority":7,"labels":{"env":"prod"},"payload":{"m":2},"timeout":"5m"}`)
+	var patched model.Task
+	json.NewDecoder(rec.Body).Decode(&patched)
+	if rec.Code != http.StatusOK || patched.Priority != 7 || patched.Labels["team"] != "a" || patched.Labels["env"] != "prod" ||
+		string(patched.Payload) != `{"m":2,"n":1}` || time.Duration(patched.Timeout) != 5*time.Minute {
+		t.Fatalf("изменения не применены: %d %+v", rec.Code, patched)
+	}
+	if rec.Header().Get("ETag") != `"`+strconv.FormatInt(patched.Version, 10)+`"` {
+		t.Errorf("ответ должен содержать ETag новой версии")
+	}
+
+	for _, body := range []string{`{"priority":1000}`, `{"labels":{"Bad Key":"x"}}`, `{"status":"Completed"}`, `{"timeout":"-1s"}`, `не json`} {
+		if rec := do(http.MethodPatch, url, body); rec.Code != http.StatusBadRequest {
+			t.Errorf("патч %s: ожидался 400, получили %d", body, rec.Code)
+		}
+	}
+	req := httptest.NewRequest(http.MethodPatch, url, strings.NewReader(`{"priority":1}`))
+	req.Header.Set("If-Match", `"1"`)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusPreconditionFailed {
+		t.Errorf("патч по устаревшей версии: ожидался 412, получили %d", rec.Code)
+	}
+
+	// Снятие run_at ставит задачу в очередь, и она выполняется
+	if rec := do(http.MethodPatch, url, `{"run_at":null}`); rec.Code != http.StatusOK {
+		t.Fatalf("снятие run_at: ожидался 200, получили %d", rec.Code)
+	}
+	waitTask(t, proc, created.ID)
+	if rec := do(http.MethodPatch, url, `{"priority":1}`); rec.Code != http.StatusConflict {
+		t.Errorf("изменение завершённой задачи: ожидался 409, получили %d", rec.Code)
+	}
+}
+
+// TestRerunAndLineage проверяет повтор завершённой задачи с переопределениями,
+// запрет повтора незавершённой, копирование и дерево повторов.
+func TestRerunAndLineage(t *testing.T) {
+	t.Parallel()
+	h, proc := setupRouter(t, func(ctx context.Context) (string, error) {
+		return "", errors.New("сбой")
+	})
+	do := func(method, url, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(method, url, strings.NewReader(body)))
+		return rec
+	}
+	decode := func(rec *httptest.ResponseRecorder) model.Task {
+		var task model.Task
+		json.NewDecoder(rec.Body).Decode(&task)
+		return task
+	}
+
+	original := decode(do(http.MethodPost, "/tasks", `{"type":"report","priority":3,"labels":{"team":"a"},"payload":{"n":1}}`))
+	waitTask(t, proc, original.ID)
+
+	// Потомки в дереве упорядочены по времени создания, поэтому часы сдвигаются между созданием задач
+	clk := proc.Clock().(*clock.Fake)
+	clk.Advance(time.Second)
+	rec := do(http.MethodPost, "/tasks/"+original.ID.String()+"/rerun", `{"priority":9,"labels":{"attempt":"2"}}`)
+	rerun := decode(rec)
+	if rec.Code != http.StatusCreated || rerun.ParentID == nil || *rerun.ParentID != original.ID {
+		t.Fatalf("ожидалась новая задача со ссылкой на исходную, получили %d %+v", rec.Code, rerun)
+	}
+	if rerun.Type != "report" || rerun.Priority != 9 || rerun.Labels["team"] != "a" || rerun.Labels["attempt"] != "2" ||
+		string(rerun.Payload) != `{"n":1}` {
+		t.Errorf("параметры не скопированы или не переопределены: %+v", rerun)
+	}
+	if rec := do(http.MethodPost, "/tasks/"+original.ID.String()+"/rerun", `{"status":"Pending"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("переопределение недопустимого поля: ожидался 400, получили %d", rec.Code)
+	}
+	waitTask(t, proc, rerun.ID)
+	second := decode(do(http.MethodPost, "/tasks/"+rerun.ID.String()+"/rerun", ""))
+	waitTask(t, proc, second.ID)
+	clk.Advance(time.Second)
+
+	// Отложенную задачу повторить нельзя, но можно скопировать
+	runAt := proc.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	scheduled := decode(do(http.MethodPost, "/tasks/"+original.ID.String()+"/clone", `{"run_at":"`+runAt+`"}`))
+	if scheduled.Status != model.StatusScheduled || *scheduled.ParentID != original.ID {
+		t.Fatalf("ожидалась отложенная копия, получили %+v", scheduled)
+	}
+	if rec := do(http.MethodPost, "/tasks/"+scheduled.ID.String()+"/rerun", ""); rec.Code != http.StatusConflict {
+		t.Errorf("повтор незавершённой задачи: ожидался 409, получили %d", rec.Code)
+	}
+
+	rec = do(http.MethodGet, "/tasks/"+second.ID.String()+"/lineage", "")
+	var lineage struct {
+		Root  uuid.UUID `json:"root"`
+		Tasks []struct {
+			ID    uuid.UUID `json:"id"`
+			Depth int       `json:"depth"`
+		} `json:"tasks"`
+	}
+	json.NewDecoder(rec.Body).Decode(&lineage)
+	if rec.Code != http.StatusOK || lineage.Root != original.ID || len(lineage.Tasks) != 4 {
+		t.Fatalf("ожидалось дерево из 4 задач от исходной, получили %d %+v", rec.Code, lineage)
+	}
+	want := []struct {
+		id    uuid.UUID
+		depth int
+	}{{original.ID, 0}, {rerun.ID, 1}, {second.ID, 2}, {scheduled.ID, 1}}
+	for i, w := range want {
+		if lineage.Tasks[i].ID != w.id || lineage.Tasks[i].Depth != w.depth {
+			t.Errorf("позиция %d: ожидалась %s на глубине %d, получили %+v", i, w.id, w.depth, lineage.Tasks[i])
+		}
+	}
+	do(http.MethodDelete, "/tasks/"+scheduled.ID.String()+"?purge=true", "")
+}
+
+// TestBench_InProcess проверяет подкоманду bench на сервере в процессе: отчёт в JSON
+// и отказ для неизвестного хранилища
+func TestBench_InProcess(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bench.json")
+	var out strings.Builder
+	if err := runBench(context.Background(), []string{"-rate", "200", "-duration", "100ms", "-drain", "5s", "-out", path}, &out); err != nil {
+		t.Fatalf("runBench: %v", err)
+	}
+	reports, err := bench.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if len(reports) != 1 || reports[0].Store != "memory" || reports[0].Requests == 0 || reports[0].Errors != 0 {
+		t.Fatalf("неверный отчёт: %+v", reports)
+	}
+	if st := reports[0].Scheduler; st == nil || !st.Drained {
+		t.Errorf("обработчик должен разобрать очередь: %+v", st)
+	}
+	if !strings.Contains(out.String(), "in-process (memory)") {
+		t.Errorf("нет текстового отчёта:\n%s", out.String())
+	}
+	if err := runBench(context.Background(), []string{"-store", "nosuch"}, io.Discard); err == nil {
+		t.Errorf("ожидалась ошибка для неизвестного хранилища")
+	}
+
+	// Прерванное испытание возвращает ошибку, но выводит и сохраняет неполный отчёт
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	out.Reset()
+	err = runBench(ctx, []string{"-rate", "200", "-duration", "10s", "-drain", "0", "-out", path}, &out)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("ожидалась ошибка отмены, получили %v", err)
+	}
+	if reports, _ := bench.ReadFile(path); len(reports) != 1 || reports[0].Requests == 0 {
+		t.Errorf("неполный отчёт не сохранён: %+v", reports)
+	}
+	if !strings.Contains(out.String(), "отчёт неполный") || !strings.Contains(out.String(), "in-process (memory)") {
+		t.Errorf("неполный отчёт не выведен:\n%s", out.String())
+	}
+}
+
+// TestCloneRunningTask проверяет копирование и дерево повторов задачи, которую в это время
+// изменяет горутина обработки (с -race - отсутствие гонок)
+func TestCloneRunningTask(t *testing.T) {
+	t.Parallel()
+	h, proc := setupRouter(t, busyWork)
+	do := func(method, url, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(method, url, strings.NewReader(body)))
+		return rec
+	}
+	var running model.Task
+	json.NewDecoder(do(http.MethodPost, "/tasks", `{"type":"report"}`).Body).Decode(&running)
+	url := "/tasks/" + running.ID.String()
+
+	// Копии откладываются, чтобы не запускать их обработку
+	runAt := proc.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	for i := 0; i < 20; i++ {
+		if rec := do(http.MethodPost, url+"/clone", `{"run_at":"`+runAt+`"}`); rec.Code != http.StatusCreated {
+			t.Fatalf("копирование выполняющейся задачи: ожидался 201, получили %d", rec.Code)
+		}
+		if rec := do(http.MethodGet, url+"/lineage", ""); rec.Code != http.StatusOK {
+			t.Fatalf("дерево повторов: ожидался 200, получили %d", rec.Code)
+		}
+	}
+	if err := proc.Cancel(context.Background(), running.ID, "test"); err != nil {
+		t.Fatalf("Cancel: %v", err)
+	}
+}
+
+// TestConditionalRequests_RunningTask проверяет условия DELETE, PATCH и восстановления для задачи,
+// которую в это время изменяет горутина обработки (с -race - отсутствие гонок)
+func TestConditionalRequests_RunningTask(t *testing.T) {
+	t.Parallel()
+	h, proc := setupRouter(t, busyWork)
+	do := func(method, url string, header map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, url, nil)
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+	var running model.Task
+	json.NewDecoder(do(http.MethodPost, "/tasks", nil).Body).Decode(&running)
+	url := "/tasks/" + running.ID.String()
+
+	for i := 0; i < 20; i++ {
+		// Пауза даёт обработке записать события между запросами
+		time.Sleep(2 * time.Millisecond)
+		if rec := do(http.MethodDelete, url, map[string]string{"If-Match": `"1"`}); rec.Code != http.StatusPreconditionFailed {
+			t.Fatalf("удаление по устаревшей версии: ожидался 412, получили %d", rec.Code)
+		}
+		if rec := do(http.MethodPost, url+"/restore", nil); rec.Code != http.StatusConflict {
+			t.Fatalf("восстановление неудалённой задачи: ожидался 409, получили %d", rec.Code)
+		}
+		if rec := do(http.MethodPatch, url, map[string]string{"If-Match": `"1"`}); rec.Code != http.StatusPreconditionFailed {
+			t.Fatalf("изменение по устаревшей версии: ожидался 412, получили %d", rec.Code)
+		}
+		if rec := do(http.MethodPatch, url, nil); rec.Code != http.StatusConflict {
+			t.Fatalf("изменение выполняющейся задачи: ожидался 409, получили %d", rec.Code)
+		}
+	}
+	if !proc.Active(running.ID) {
+		t.Fatalf("отклонённые запросы не должны останавливать задачу")
+	}
+	if rec := do(http.MethodDelete, url, nil); rec.Code != http.StatusNoContent {
+		t.Errorf("удаление выполняющейся задачи: ожидался 204, получили %d", rec.Code)
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"workmateTestProject/internal/auth"
+	"workmateTestProject/internal/ratelimit"
+)
+
+// rateLimiter описывает, как ограничивать частоту запросов клиентов
+type rateLimiter struct {
+	backend ratelimit.Backend
+	read    ratelimit.Limit
+	write   ratelimit.Limit
+	// keyBy - признак клиента: "principal", "tenant" или "ip"
+	keyBy string
+	// proxyHops - сколько доверенных прокси перед сервисом дописывают X-Forwarded-For;
+	// 0 - заголовок не учитывается
+	proxyHops int
+}
+
+// loadRateLimiter настраивает ограничение частоты из RATE_LIMIT_* переменных.
+// Возвращает nil, если не задан ни RATE_LIMIT_READ, ни RATE_LIMIT_WRITE.
+func loadRateLimiter() (*rateLimiter, error) {
+	readSpec, writeSpec := os.Getenv("RATE_LIMIT_READ"), os.Getenv("RATE_LIMIT_WRITE")
+	if readSpec == "" && writeSpec == "" {
+		return nil, nil
+	}
+	rl := &rateLimiter{keyBy: os.Getenv("RATE_LIMIT_KEY")}
+	switch rl.keyBy {
+	case "":
+		rl.keyBy = "principal"
+	case "principal", "tenant", "ip":
+	default:
+		return nil, fmt.Errorf("RATE_LIMIT_KEY: ожидалось principal, tenant или ip, получили %q", rl.keyBy)
+	}
+	switch v := os.Getenv("RATE_LIMIT_TRUST_PROXY"); v {
+	case "", "false":
+	case "true":
+		rl.proxyHops = 1
+	default:
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("RATE_LIMIT_TRUST_PROXY: ожидалось true, false или число прокси, получили %q", v)
+		}
+		rl.proxyHops = n
+	}
+	var err error
+	if readSpec != "" {
+		if rl.read, err = ratelimit.ParseLimit(readSpec); err != nil {
+			return nil, fmt.Errorf("RATE_LIMIT_READ: %w", err)
+		}
+	}
+	if writeSpec != "" {
+		if rl.write, err = ratelimit.ParseLimit(writeSpec); err != nil {
+			return nil, fmt.Errorf("RATE_LIMIT_WRITE: %w", err)
+		}
+	}
+	if addr := os.Getenv("RATE_LIMIT_REDIS_ADDR"); addr != "" {
+		rl.backend = ratelimit.NewRedisBackend(addr, os.Getenv("RATE_LIMIT_REDIS_PASSWORD"))
+	} else {
+		rl.backend = ratelimit.NewMemoryBackend()
+	}
+	return rl, nil
+}
+
+// principalKey возвращает корзину аутентифицированного субъекта запроса; ok=false, если
+// клиент анонимен или лимит считается только по IP
+func (rl *rateLimiter) principalKey(r *http.Request) (key string, ok bool) {
+	p, authenticated := auth.FromContext(r.Context())
+	if !authenticated || p == auth.Anonymous {
+		return "", false
+	}
+	switch rl.keyBy {
+	case "principal":
+		return "principal:" + p.Tenant + "/" + p.Name, true
+	case "tenant":
+		return "tenant:" + p.Tenant, true
+	}
+	return "", false
+}
+
+// clientIP возвращает IP клиента с учётом доверенных прокси. Каждый прокси дописывает в конец
+// X-Forwarded-For адрес, с которого к нему пришёл запрос, а начало заголовка задаёт сам клиент.
+// Поэтому адрес клиента - proxyHops-я запись с конца: её дописал первый доверенный прокси.
+func (rl *rateLimiter) clientIP(r *http.Request) string {
+	if rl.proxyHops > 0 {
+		var entries []string
+		for _, v := range r.Header.Values("X-Forwarded-For") {
+			entries = append(entries, strings.Split(v, ",")...)
+		}
+		if len(entries) > 0 {
+			ip := strings.TrimSpace(entries[max(len(entries)-rl.proxyHops, 0)])
+			if net.ParseIP(ip) != nil {
+				return ip
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// ipRateLimitMiddleware ограничивает частоту запросов с одного IP. Выполняется до authMiddleware,
+// чтобы перебор ключей и запросы, отклонённые с 401, тоже расходовали лимит.
+func ipRateLimitMiddleware(rl *rateLimiter) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		if rl == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if rl.allow(w, r, "ip:"+rl.clientIP(r)) {
+				next.ServeHTTP(w, r)
+			}
+		})
+	}
+}
+
+// rateLimitMiddleware ограничивает частоту запросов субъекта или арендатора (RATE_LIMIT_KEY).
+// Должен выполняться после authMiddleware, чтобы знать субъекта запроса; анонимные запросы
+// ограничиваются только по IP в ipRateLimitMiddleware.
+func rateLimitMiddleware(rl *rateLimiter) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		if rl == nil || rl.keyBy == "ip" {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key, ok := rl.principalKey(r)
+			if !ok || rl.allow(w, r, key) {
+				next.ServeHTTP(w, r)
+			}
+		})
+	}
+}
+
+// allow расходует токен из корзины key отдельно для чтения и записи и выставляет заголовки RateLimit-*.
+// При исчерпании лимита отвечает 429 и возвращает false.
+func (rl *rateLimiter) allow(w http.ResponseWriter, r *http.Request, key string) bool {
+	limit, kind := rl.write, "write"
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		limit, kind = rl.read, "read"
+	}
+	if limit.Rate == 0 {
+		return true
+	}
+
+	res, err := rl.backend.Take(r.Context(), kind+":"+key, limit)
+	if err != nil {
+		// Недоступность хранилища лимитов не должна останавливать API
+		log.Printf("Ошибка ограничителя частоты, запрос пропущен: %v", err)
+		return true
+	}
+	w.Header().Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+	w.Header().Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset.Seconds())))
+	if !res.Allowed {
+		w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter.Seconds())))
+		errorResponse(w, http.StatusTooManyRequests, "Слишком много запросов, повторите позже")
+		return false
+	}
+	return true
+}
+
+// ceilSeconds округляет секунды вверх, чтобы клиент не повторил запрос слишком рано
+func ceilSeconds(s float64) int {
+	return int(math.Ceil(s))
+}
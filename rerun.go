@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+
+	"workmateTestProject/internal/model"
+	"workmateTestProject/internal/service"
+	"workmateTestProject/internal/storage"
+)
+
+// copyTaskHandler создаёт новую задачу с типом, приоритетом, метками, timeout и payload задачи {id},
+// связанную с ней через parent_id. Тело запроса необязательно - JSON Merge Patch с переопределениями
+// тех же полей и run_at, как в PATCH /tasks/{id}. С finishedOnly (rerun) копировать можно только
+// задачу, обработка которой закончена; clone копирует задачу в любом статусе.
+func copyTaskHandler(proc *service.Processor, store storage.TaskStore, finishedOnly bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(mux.Vars(r)["id"])
+		if err != nil {
+			errorResponse(w, http.StatusBadRequest, "Неверный UUID")
+			return
+		}
+		overrides, err := io.ReadAll(io.LimitReader(r.Body, model.MaxPayloadSize+4096))
+		if err != nil {
+			errorResponse(w, http.StatusBadRequest, "Ошибка чтения тела запроса")
+			return
+		}
+		store := tenantStore(r, proc, store)
+		parent, ok := store.Get(id)
+		if !ok {
+			errorResponse(w, http.StatusNotFound, "Задача не найдена")
+			return
+		}
+		// Клонировать можно и выполняющуюся задачу, поэтому поля читаются из согласованной копии
+		src := proc.Snapshot(parent)
+		if finishedOnly && !src.Status.Terminal() && src.Status != model.StatusInterrupted {
+			errorResponse(w, http.StatusConflict, "Повторить можно только задачу, обработка которой закончена")
+			return
+		}
+
+		task := &model.Task{
+			ID:        uuid.New(),
+			ParentID:  &src.ID,
+			Status:    model.StatusPending,
+			CreatedAt: proc.Now(),
+			CreatedBy: principal(r).Name,
+			Type:      src.Type,
+			Priority:  src.Priority,
+			Labels:    src.Labels,
+			Timeout:   src.Timeout,
+			Payload:   src.Payload,
+		}
+		if len(overrides) > 0 {
+			if err := task.ApplyMergePatch(overrides); err != nil {
+				errorResponse(w, http.StatusBadRequest, err.Error())
+				return
+			}
+		}
+		if err := proc.ValidateTask(task); err != nil {
+			errorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		message := "копия задачи " + src.ID.String()
+		if finishedOnly {
+			message = "повторный запуск задачи " + src.ID.String()
+		}
+		submitTask(w, r, proc, store, task, message)
+	}
+}
+
+// lineageEntry - задача в дереве повторов
+type lineageEntry struct {
+	ID         uuid.UUID        `json:"id"`
+	ParentID   *uuid.UUID       `json:"parent_id,omitempty"`
+	Depth      int              `json:"depth"`
+	Status     model.TaskStatus `json:"status"`
+	CreatedBy  string           `json:"created_by,omitempty"`
+	CreatedAt  time.Time        `json:"created_at"`
+	FinishedAt *time.Time       `json:"finished_at,omitempty"`
+}
+
+// lineageHandler возвращает дерево повторов и копий, в которое входит задача:
+// от самого раннего доступного предка до всех потомков, в порядке обхода в глубину
+// (потомки одного родителя - по времени создания). Удалённые задачи в дерево не входят.
+func lineageHandler(proc *service.Processor, store storage.TaskStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(mux.Vars(r)["id"])
+		if err != nil {
+			errorResponse(w, http.StatusBadRequest, "Неверный UUID")
+			return
+		}
+		store := tenantStore(r, proc, store)
+		// Дерево строится по согласованным копиям: задачи в нём могут выполняться
+		get := func(id uuid.UUID) (*model.Task, bool) {
+			t, ok := store.Get(id)
+			if !ok {
+				return nil, false
+			}
+			return proc.Snapshot(t), true
+		}
+		task, ok := get(id)
+		if !ok {
+			errorResponse(w, http.StatusNotFound, "Задача не найдена")
+			return
+		}
+
+		// Поднимаемся к корню; visited защищает от циклов в загруженных извне данных
+		root := task
+		visited := map[uuid.UUID]bool{root.ID: true}
+		for root.ParentID != nil && !visited[*root.ParentID] {
+			parent, ok := get(*root.ParentID)
+			if !ok {
+				break
+			}
+			root = parent
+			visited[root.ID] = true
+		}
+
+		children := make(map[uuid.UUID][]*model.Task)
+		store.Range(func(t *model.Task) bool {
+			if t = proc.Snapshot(t); t.ParentID != nil {
+				children[*t.ParentID] = append(children[*t.ParentID], t)
+			}
+			return true
+		})
+
+		resp := struct {
+			Root  uuid.UUID      `json:"root"`
+			Tasks []lineageEntry `json:"tasks"`
+		}{Root: root.ID}
+		seen := make(map[uuid.UUID]bool)
+		var walk func(t *model.Task, depth int)
+		walk = func(t *model.Task, depth int) {
+			if seen[t.ID] {
+				return
+			}
+			seen[t.ID] = true
+			resp.Tasks = append(resp.Tasks, lineageEntry{
+				ID: t.ID, ParentID: t.ParentID, Depth: depth, Status: t.Status,
+				CreatedBy: t.CreatedBy, CreatedAt: t.CreatedAt, FinishedAt: t.FinishedAt,
+			})
+			kids := children[t.ID]
+			sort.Slice(kids, func(i, j int) bool { return kids[i].CreatedAt.Before(kids[j].CreatedAt) })
+			for _, kid := range kids {
+				walk(kid, depth+1)
+			}
+		}
+		walk(root, 0)
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			errorResponse(w, http.StatusInternalServerError, "Ошибка кодирования ответа")
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+
+	"workmateTestProject/internal/archive"
+	"workmateTestProject/internal/model"
+	"workmateTestProject/internal/retention"
+	"workmateTestProject/internal/service"
+	"workmateTestProject/internal/storage"
+)
+
+// loadJanitor настраивает сборщик задач из RETENTION и RETENTION_BATCH. Без RETENTION сборщик
+// удаляет только мягко удалённые задачи с истёкшим сроком восстановления.
+// Если задан архив, задачи переносятся в него.
+func loadJanitor(proc *service.Processor, store storage.TaskStore, arch *archive.Archive) (*retention.Janitor, error) {
+	policy, err := retention.ParsePolicy(os.Getenv("RETENTION"))
+	if err != nil {
+		return nil, fmt.Errorf("RETENTION: %w", err)
+	}
+	batch, _ := strconv.Atoi(os.Getenv("RETENTION_BATCH"))
+	j := retention.NewJanitor(store, policy, batch)
+	j.Clock = proc.Clock()
+	j.Lock = lockTasks
+	j.Snapshot = proc.Snapshot
+	j.DeletedGrace = deleteGrace
+	if arch != nil {
+		j.OnPurge = archiveTaskData(proc, arch)
+	} else {
+		j.AfterPurge = purgeTaskData(proc)
+	}
+	return j, nil
+}
+
+// purgeTaskData удаляет артефакты, журналы и историю задач, уже удалённых из хранилища или заменённых.
+// Очистка выполняется по возможности: ошибки записываются в лог, а оставшиеся объекты ни к одной
+// задаче не относятся.
+func purgeTaskData(proc *service.Processor) func(ctx context.Context, tasks []*model.Task) {
+	return func(ctx context.Context, tasks []*model.Task) {
+		for _, task := range tasks {
+			if err := proc.DeleteArtifacts(ctx, task); err != nil {
+				log.Printf("Ошибка удаления артефактов задачи %s: %v", task.ID, err)
+			}
+			if err := proc.DeleteLogs(task.ID); err != nil {
+				log.Printf("Ошибка удаления журнала задачи %s: %v", task.ID, err)
+			}
+			proc.DeleteHistory(task.ID)
+		}
+	}
+}
+
+// retentionHandler возвращает политику хранения и статистику сборщика
+func retentionHandler(j *retention.Janitor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resp := struct {
+			Policy       map[string]string `json:"policy"`
+			DeletedGrace string            `json:"deleted_grace"`
+			Stats        retention.Stats   `json:"stats"`
+		}{Policy: make(map[string]string), DeletedGrace: j.DeletedGrace.String(), Stats: j.Stats()}
+		for key, rule := range j.Policy() {
+			resp.Policy[key] = rule.String()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			errorResponse(w, http.StatusInternalServerError, "Ошибка кодирования ответа")
+		}
+	}
+}
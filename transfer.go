@@ -0,0 +1,241 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"workmateTestProject/internal/model"
+	"workmateTestProject/internal/service"
+	"workmateTestProject/internal/storage"
+)
+
+// importMaxBytes ограничивает размер тела запроса импорта
+const importMaxBytes = 256 << 20
+
+// errImportFailed сообщает о задаче, которую не удалось загрузить. Тем же сообщением отвечает
+// совпадение ID с задачей другого арендатора, чтобы не раскрывать существование чужих задач.
+var errImportFailed = errors.New("задачу не удалось загрузить")
+
+// Политики обработки задач, ID которых уже есть в хранилище
+const (
+	conflictFail      = "fail"
+	conflictSkip      = "skip"
+	conflictOverwrite = "overwrite"
+)
+
+// exportTasksHandler выгружает задачи арендатора потоком, не собирая ответ в памяти.
+// Параметры: format (ndjson или csv), status, type, from и to (RFC 3339, по времени создания).
+func exportTasksHandler(proc *service.Processor, store storage.TaskStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		format, err := storage.ParseFormat(q.Get("format"))
+		if err != nil {
+			errorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		var from, to time.Time
+		for name, dst := range map[string]*time.Time{"from": &from, "to": &to} {
+			if v := q.Get(name); v != "" {
+				if *dst, err = time.Parse(time.RFC3339, v); err != nil {
+					errorResponse(w, http.StatusBadRequest, name+" должен быть в формате RFC 3339")
+					return
+				}
+			}
+		}
+		status, typ := model.TaskStatus(q.Get("status")), q.Get("type")
+
+		w.Header().Set("Content-Type", format.ContentType())
+		w.Header().Set("Content-Disposition", `attachment; filename="tasks.`+string(format)+`"`)
+		tw := storage.NewTaskWriter(w, format)
+		tenantStore(r, proc, store).Range(func(task *model.Task) bool {
+			task = proc.Snapshot(task)
+			if (status != "" && task.Status != status) || (typ != "" && task.Type != typ) ||
+				(!from.IsZero() && task.CreatedAt.Before(from)) || (!to.IsZero() && !task.CreatedAt.Before(to)) {
+				return true
+			}
+			// Ошибка записи означает, что клиент отключился
+			return tw.Write(task) == nil && r.Context().Err() == nil
+		})
+		if err := tw.Flush(); err != nil {
+			log.Printf("Ошибка выгрузки задач: %v", err)
+		}
+	}
+}
+
+// importError описывает задачу, которую не удалось импортировать
+type importError struct {
+	ID    uuid.UUID `json:"id"`
+	Error string    `json:"error"`
+}
+
+// importResult - итог импорта
+type importResult struct {
+	Imported    int           `json:"imported"`
+	Overwritten int           `json:"overwritten"`
+	Skipped     int           `json:"skipped"`
+	Requeued    int           `json:"requeued"`
+	Errors      []importError `json:"errors,omitempty"`
+}
+
+// importTasksHandler загружает задачи в пространство арендатора.
+// Параметры: format (ndjson или csv; по умолчанию по Content-Type), conflict (fail, skip или overwrite)
+// и requeue=true - поставить незавершённые задачи в очередь. Входные данные сначала проверяются целиком,
+// поэтому при ошибке разбора или конфликте в режиме fail хранилище не меняется.
+func importTasksHandler(proc *service.Processor, store storage.TaskStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		formatName := q.Get("format")
+		if formatName == "" && strings.HasPrefix(r.Header.Get("Content-Type"), "text/csv") {
+			formatName = string(storage.FormatCSV)
+		}
+		format, err := storage.ParseFormat(formatName)
+		if err != nil {
+			errorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		conflict := q.Get("conflict")
+		switch conflict {
+		case "":
+			conflict = conflictFail
+		case conflictFail, conflictSkip, conflictOverwrite:
+		default:
+			errorResponse(w, http.StatusBadRequest, "conflict должен быть fail, skip или overwrite")
+			return
+		}
+		requeue := q.Get("requeue") == "true"
+		tenant := principal(r).Tenant
+
+		// Разбираем и проверяем всё до изменения хранилища
+		var tasks []*model.Task
+		seen := make(map[uuid.UUID]bool)
+		err = storage.ReadTasks(http.MaxBytesReader(w, r.Body, importMaxBytes), format, func(line int, task *model.Task) error {
+			if seen[task.ID] {
+				return fmt.Errorf("строка %d: задача %s встречается повторно", line, task.ID)
+			}
+			seen[task.ID] = true
+			if err := prepareImported(proc, task, tenant, requeue); err != nil {
+				return fmt.Errorf("строка %d: %w", line, err)
+			}
+			tasks = append(tasks, task)
+			return nil
+		})
+		if err != nil {
+			errorResponse(w, http.StatusBadRequest, "Ошибка разбора: "+err.Error())
+			return
+		}
+		if conflict == conflictFail {
+			// Перечисляем только свои задачи: ID задач других арендаторов не должны раскрываться,
+			// такие конфликты попадают в errors как при overwrite
+			var ids []string
+			for _, task := range tasks {
+				if existing, exists := store.Get(task.ID); exists && existing.Tenant == tenant {
+					ids = append(ids, task.ID.String())
+				}
+			}
+			if len(ids) > 0 {
+				errorResponse(w, http.StatusConflict, "Задачи уже существуют: "+strings.Join(ids, ", "))
+				return
+			}
+		}
+
+		var res importResult
+		for _, task := range tasks {
+			if err := importTask(r, proc, store, task, conflict, requeue, &res); err != nil {
+				res.Errors = append(res.Errors, importError{ID: task.ID, Error: err.Error()})
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(res); err != nil {
+			errorResponse(w, http.StatusInternalServerError, "Ошибка кодирования ответа")
+		}
+	}
+}
+
+// importTask сохраняет одну проверенную задачу под её блокировкой и учитывает исход в res.
+// Ошибка описывает причину, по которой задача не загружена или не поставлена в очередь.
+func importTask(r *http.Request, proc *service.Processor, store storage.TaskStore, task *model.Task, conflict string, requeue bool, res *importResult) error {
+	defer lockTask(task.ID)()
+	existing, exists := store.Get(task.ID)
+	if exists {
+		existing = proc.Snapshot(existing)
+	}
+	if exists && existing.Tenant != task.Tenant {
+		// Чужая задача не считается конфликтом ни в одном режиме, иначе skip и fail
+		// выдали бы её существование
+		return errImportFailed
+	}
+	if exists {
+		switch {
+		case conflict == conflictSkip:
+			res.Skipped++
+			return nil
+		case conflict == conflictFail:
+			// Задача появилась после проверки конфликтов
+			return errors.New("задача уже существует")
+		case proc.Active(existing.ID):
+			return errors.New("нельзя заменить задачу, которая ожидает в очереди или выполняется")
+		}
+		// Артефакты, журнал и история заменённой задачи к новой не относятся и удаляются,
+		// как при окончательном удалении. Версия не должна уменьшаться, иначе ETag новой задачи
+		// может совпасть с ETag, сохранённым клиентом для старой.
+		purgeTaskData(proc)(r.Context(), []*model.Task{existing})
+		task.Version = max(task.Version, existing.Version)
+		res.Overwritten++
+	} else {
+		res.Imported++
+	}
+	start := !task.Status.Terminal() && requeue
+	switch {
+	case start:
+		task.ResetForRequeue()
+	case task.Status == model.StatusInProgress:
+		// Обработка в исходном экземпляре не завершена и здесь не продолжится
+		task.Status = model.StatusInterrupted
+	}
+	proc.RecordSnapshot(task, model.EventCreated, principal(r).Name, "загружена из выгрузки")
+	store.Create(task)
+	if !start {
+		return nil
+	}
+	if err := proc.StartProcessing(task); err != nil {
+		proc.RecordEvent(task, model.Event{Type: model.EventInterrupted, Error: "не поставлена в очередь: " + err.Error()})
+		return fmt.Errorf("не поставлена в очередь: %w", err)
+	}
+	res.Requeued++
+	return nil
+}
+
+// prepareImported проверяет загружаемую задачу и приводит её к пространству арендатора
+func prepareImported(proc *service.Processor, task *model.Task, tenant string, requeue bool) error {
+	if task.ID == uuid.Nil {
+		return errors.New("не указан id")
+	}
+	if task.Status == "" {
+		task.Status = model.StatusPending
+	}
+	if !task.Status.Valid() {
+		return fmt.Errorf("неизвестный статус %q", task.Status)
+	}
+	if err := task.Validate(); err != nil {
+		return err
+	}
+	// Задачу, которая будет поставлена в очередь, проверяет и её обработчик, как при создании
+	if requeue && !task.Status.Terminal() {
+		if err := proc.ValidateTask(task); err != nil {
+			return err
+		}
+	}
+	if task.CreatedAt.IsZero() {
+		task.CreatedAt = proc.Now()
+	}
+	task.Tenant = tenant
+	return nil
+}